@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultAnthropicURL       = "https://api.anthropic.com"
+	anthropicVersion          = "2023-06-01"
+	defaultAnthropicMaxTokens = 1024
+)
+
+// Anthropic 的 Messages API，system 消息通过单独的 system 字段传递
+type anthropic struct {
+	url         string
+	apiKey      string
+	model       string
+	temperature float64
+	client      *http.Client
+}
+
+func newAnthropic(cfg Config, client *http.Client) *anthropic {
+	baseURL := defaultAnthropicURL
+	if cfg.BaseURL != "" {
+		baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+	return &anthropic{
+		url:         strings.TrimSuffix(baseURL, "/v1/messages") + "/v1/messages",
+		apiKey:      cfg.APIKey,
+		model:       cfg.Model,
+		temperature: cfg.Temperature,
+		client:      client,
+	}
+}
+
+// Messages API 没有JSON模式，JSON 为true时只能依靠提示中的要求
+func (p *anthropic) Complete(ctx context.Context, req Request) (string, error) {
+	var system []string
+	messages := []Message{}
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		messages = append(messages, m)
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultAnthropicMaxTokens
+	}
+	body := map[string]interface{}{
+		"model":       p.model,
+		"messages":    messages,
+		"max_tokens":  maxTokens,
+		"temperature": p.temperature,
+	}
+	if len(system) > 0 {
+		body["system"] = strings.Join(system, "\n\n")
+	}
+
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := postJSON(ctx, p.client, p.url, headers, body, &result, anthropicError); err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", errors.New("provider returned an empty response")
+	}
+	return text.String(), nil
+}
+
+func anthropicError(body []byte) string {
+	var result struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &result) != nil {
+		return ""
+	}
+	return result.Error.Message
+}
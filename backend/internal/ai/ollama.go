@@ -0,0 +1,69 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const defaultOllamaURL = "http://localhost:11434"
+
+// 本地Ollama的 /api/chat，不需要API Key
+type ollama struct {
+	url         string
+	model       string
+	temperature float64
+	client      *http.Client
+}
+
+func newOllama(cfg Config, client *http.Client) *ollama {
+	baseURL := defaultOllamaURL
+	if cfg.BaseURL != "" {
+		baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+	return &ollama{
+		url:         strings.TrimSuffix(baseURL, "/api/chat") + "/api/chat",
+		model:       cfg.Model,
+		temperature: cfg.Temperature,
+		client:      client,
+	}
+}
+
+func (p *ollama) Complete(ctx context.Context, req Request) (string, error) {
+	options := map[string]interface{}{"temperature": p.temperature}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	body := map[string]interface{}{
+		"model":    p.model,
+		"messages": req.Messages,
+		"stream":   false,
+		"options":  options,
+	}
+	if req.JSON {
+		body["format"] = "json"
+	}
+
+	var result struct {
+		Message Message `json:"message"`
+	}
+	if err := postJSON(ctx, p.client, p.url, nil, body, &result, ollamaError); err != nil {
+		return "", err
+	}
+	if result.Message.Content == "" {
+		return "", errors.New("provider returned an empty response")
+	}
+	return result.Message.Content, nil
+}
+
+func ollamaError(body []byte) string {
+	var result struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &result) != nil {
+		return ""
+	}
+	return result.Error
+}
@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	defaultOpenAIURL       = "https://api.openai.com/v1/chat/completions"
+	defaultAzureAPIVersion = "2024-10-21"
+)
+
+// OpenAI 及兼容接口的 chat/completions，Azure OpenAI 使用相同的请求格式
+type openAI struct {
+	url         string
+	headers     map[string]string
+	model       string
+	temperature float64
+	client      *http.Client
+}
+
+// base_url 可以是完整的 chat/completions 地址或API根地址
+func newOpenAI(cfg Config, client *http.Client) *openAI {
+	endpoint := defaultOpenAIURL
+	if cfg.BaseURL != "" {
+		endpoint = strings.TrimSuffix(cfg.BaseURL, "/")
+		if !strings.HasSuffix(endpoint, "/chat/completions") {
+			endpoint += "/chat/completions"
+		}
+	}
+
+	headers := map[string]string{}
+	if cfg.APIKey != "" {
+		headers["Authorization"] = "Bearer " + cfg.APIKey
+	}
+	return &openAI{url: endpoint, headers: headers, model: cfg.Model, temperature: cfg.Temperature, client: client}
+}
+
+// Azure OpenAI 按部署调用，base_url 为资源地址（如 https://xxx.openai.azure.com），model 为部署名称
+func newAzure(cfg Config, client *http.Client) *openAI {
+	apiVersion := cfg.APIVersion
+	if apiVersion == "" {
+		apiVersion = defaultAzureAPIVersion
+	}
+	endpoint := strings.TrimSuffix(cfg.BaseURL, "/") + "/openai/deployments/" + url.PathEscape(cfg.Model) +
+		"/chat/completions?api-version=" + url.QueryEscape(apiVersion)
+
+	return &openAI{
+		url:         endpoint,
+		headers:     map[string]string{"api-key": cfg.APIKey},
+		model:       cfg.Model,
+		temperature: cfg.Temperature,
+		client:      client,
+	}
+}
+
+func (p *openAI) Complete(ctx context.Context, req Request) (string, error) {
+	body := map[string]interface{}{
+		"model":       p.model,
+		"messages":    req.Messages,
+		"temperature": p.temperature,
+	}
+	if req.JSON {
+		body["response_format"] = map[string]string{"type": "json_object"}
+	}
+	if req.MaxTokens > 0 {
+		body["max_tokens"] = req.MaxTokens
+	}
+
+	var result struct {
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+	}
+	if err := postJSON(ctx, p.client, p.url, p.headers, body, &result, openAIError); err != nil {
+		return "", err
+	}
+	if len(result.Choices) == 0 || result.Choices[0].Message.Content == "" {
+		return "", errors.New("provider returned an empty response")
+	}
+	return result.Choices[0].Message.Content, nil
+}
+
+func openAIError(body []byte) string {
+	var result struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &result) != nil {
+		return ""
+	}
+	return result.Error.Message
+}
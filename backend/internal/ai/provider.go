@@ -0,0 +1,161 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 支持的提供商
+const (
+	ProviderOpenAI    = "openai"
+	ProviderCustom    = "custom"
+	ProviderAzure     = "azure"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
+const defaultTimeout = 60 * time.Second
+
+// 提供商响应体的大小上限
+const maxResponseBytes = 10 << 20
+
+// Config 用户的AI设置，Model 在Azure OpenAI中为部署名称，APIVersion 只用于Azure OpenAI
+type Config struct {
+	Provider    string
+	APIKey      string
+	BaseURL     string
+	Model       string
+	APIVersion  string
+	Temperature float64
+	Timeout     time.Duration
+}
+
+// Message 对话中的一条消息，Role 为 system、user 或 assistant
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request 一次对话请求，JSON 为true时要求模型只返回JSON对象（不支持的提供商只在提示中要求）
+type Request struct {
+	Messages  []Message
+	JSON      bool
+	MaxTokens int
+}
+
+// Provider 对话模型的提供商，Complete 返回模型的回复
+type Provider interface {
+	Complete(ctx context.Context, req Request) (string, error)
+}
+
+// Validate 检查提供商需要的字段
+func Validate(cfg Config) error {
+	if cfg.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if cfg.Temperature < 0 || cfg.Temperature > 2 {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+
+	switch cfg.Provider {
+	case ProviderOpenAI:
+		if cfg.APIKey == "" {
+			return fmt.Errorf("api_key is required for OpenAI")
+		}
+	case ProviderCustom:
+		if cfg.BaseURL == "" {
+			return fmt.Errorf("base_url is required for a custom OpenAI-compatible provider")
+		}
+	case ProviderAzure:
+		if cfg.BaseURL == "" {
+			return fmt.Errorf("base_url (the Azure OpenAI resource endpoint) is required for Azure OpenAI")
+		}
+		if cfg.APIKey == "" {
+			return fmt.Errorf("api_key is required for Azure OpenAI")
+		}
+	case ProviderAnthropic:
+		if cfg.APIKey == "" {
+			return fmt.Errorf("api_key is required for Anthropic")
+		}
+		if cfg.Temperature > 1 {
+			return fmt.Errorf("temperature must be between 0 and 1 for Anthropic")
+		}
+	case ProviderOllama:
+	default:
+		return fmt.Errorf("unsupported provider %q", cfg.Provider)
+	}
+
+	if cfg.BaseURL != "" && !strings.HasPrefix(cfg.BaseURL, "http://") && !strings.HasPrefix(cfg.BaseURL, "https://") {
+		return fmt.Errorf("base_url must be an http or https URL")
+	}
+	return nil
+}
+
+// New 按 Provider 创建提供商，配置不完整时返回错误
+func New(cfg Config) (Provider, error) {
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	switch cfg.Provider {
+	case ProviderAzure:
+		return newAzure(cfg, client), nil
+	case ProviderAnthropic:
+		return newAnthropic(cfg, client), nil
+	case ProviderOllama:
+		return newOllama(cfg, client), nil
+	default:
+		return newOpenAI(cfg, client), nil
+	}
+}
+
+// 发送JSON请求并解码响应，非2xx时用 errMessage 从响应中取出错误信息，取不到时只返回状态码，不返回原始响应
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out interface{}, errMessage func([]byte) string) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(respBody) > maxResponseBytes {
+		return fmt.Errorf("provider response exceeds %d bytes", maxResponseBytes)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if message := errMessage(respBody); message != "" {
+			return fmt.Errorf("provider returned %d: %s", resp.StatusCode, message)
+		}
+		return fmt.Errorf("provider returned %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decode provider response: %w", err)
+	}
+	return nil
+}
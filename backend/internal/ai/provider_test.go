@@ -0,0 +1,234 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"openai", Config{Provider: ProviderOpenAI, APIKey: "sk", Model: "gpt-4o"}, ""},
+		{"openai without key", Config{Provider: ProviderOpenAI, Model: "gpt-4o"}, "api_key is required"},
+		{"missing model", Config{Provider: ProviderOpenAI, APIKey: "sk"}, "model is required"},
+		{"negative temperature", Config{Provider: ProviderOpenAI, APIKey: "sk", Model: "m", Temperature: -1}, "temperature"},
+		{"temperature too high", Config{Provider: ProviderOpenAI, APIKey: "sk", Model: "m", Temperature: 2.5}, "temperature"},
+		{"custom", Config{Provider: ProviderCustom, BaseURL: "http://llm.local/v1", Model: "m"}, ""},
+		{"custom without base_url", Config{Provider: ProviderCustom, Model: "m"}, "base_url is required"},
+		{"azure", Config{Provider: ProviderAzure, BaseURL: "https://x.openai.azure.com", APIKey: "k", Model: "d"}, ""},
+		{"azure without base_url", Config{Provider: ProviderAzure, APIKey: "k", Model: "d"}, "base_url"},
+		{"azure without key", Config{Provider: ProviderAzure, BaseURL: "https://x.openai.azure.com", Model: "d"}, "api_key is required"},
+		{"anthropic", Config{Provider: ProviderAnthropic, APIKey: "k", Model: "m", Temperature: 1}, ""},
+		{"anthropic without key", Config{Provider: ProviderAnthropic, Model: "m"}, "api_key is required"},
+		{"anthropic temperature", Config{Provider: ProviderAnthropic, APIKey: "k", Model: "m", Temperature: 1.5}, "between 0 and 1"},
+		{"ollama", Config{Provider: ProviderOllama, Model: "llama3"}, ""},
+		{"unsupported provider", Config{Provider: "other", Model: "m"}, "unsupported provider"},
+		{"non-http base_url", Config{Provider: ProviderOllama, Model: "m", BaseURL: "file:///etc/passwd"}, "http or https"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 记录收到的请求并返回固定响应的服务器
+type recordedRequest struct {
+	path   string
+	query  string
+	header http.Header
+	body   map[string]interface{}
+}
+
+func newTestServer(t *testing.T, status int, response string) (*httptest.Server, *recordedRequest) {
+	t.Helper()
+	recorded := &recordedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorded.path = r.URL.Path
+		recorded.query = r.URL.RawQuery
+		recorded.header = r.Header.Clone()
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &recorded.body); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, recorded
+}
+
+func complete(t *testing.T, cfg Config, req Request) (string, error) {
+	t.Helper()
+	provider, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider.Complete(context.Background(), req)
+}
+
+var testMessages = []Message{{Role: "system", Content: "be brief"}, {Role: "user", Content: "ping"}}
+
+func TestOpenAI(t *testing.T) {
+	server, recorded := newTestServer(t, http.StatusOK, `{"choices": [{"message": {"role": "assistant", "content": "pong"}}]}`)
+
+	reply, err := complete(t, Config{Provider: ProviderCustom, BaseURL: server.URL + "/v1/", APIKey: "sk-test", Model: "gpt-4o", Temperature: 0.2},
+		Request{Messages: testMessages, JSON: true, MaxTokens: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "pong" {
+		t.Errorf("reply = %q", reply)
+	}
+	if recorded.path != "/v1/chat/completions" {
+		t.Errorf("path = %q", recorded.path)
+	}
+	if got := recorded.header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if recorded.body["model"] != "gpt-4o" || recorded.body["max_tokens"] != float64(16) || recorded.body["temperature"] != 0.2 {
+		t.Errorf("unexpected body %v", recorded.body)
+	}
+	if format, _ := recorded.body["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+		t.Errorf("response_format = %v", recorded.body["response_format"])
+	}
+	if messages, _ := recorded.body["messages"].([]interface{}); len(messages) != 2 {
+		t.Errorf("messages = %v", recorded.body["messages"])
+	}
+}
+
+func TestOpenAIWithoutKey(t *testing.T) {
+	server, recorded := newTestServer(t, http.StatusOK, `{"choices": [{"message": {"content": "pong"}}]}`)
+
+	if _, err := complete(t, Config{Provider: ProviderCustom, BaseURL: server.URL + "/v1/chat/completions", Model: "m"},
+		Request{Messages: testMessages}); err != nil {
+		t.Fatal(err)
+	}
+	if recorded.path != "/v1/chat/completions" {
+		t.Errorf("path = %q", recorded.path)
+	}
+	if got := recorded.header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, want none", got)
+	}
+	if _, ok := recorded.body["response_format"]; ok {
+		t.Error("response_format sent without JSON mode")
+	}
+}
+
+func TestAzure(t *testing.T) {
+	server, recorded := newTestServer(t, http.StatusOK, `{"choices": [{"message": {"content": "pong"}}]}`)
+
+	if _, err := complete(t, Config{Provider: ProviderAzure, BaseURL: server.URL, APIKey: "azure-key", Model: "my deployment"},
+		Request{Messages: testMessages}); err != nil {
+		t.Fatal(err)
+	}
+	if recorded.path != "/openai/deployments/my deployment/chat/completions" {
+		t.Errorf("path = %q", recorded.path)
+	}
+	if recorded.query != "api-version="+defaultAzureAPIVersion {
+		t.Errorf("query = %q", recorded.query)
+	}
+	if got := recorded.header.Get("api-key"); got != "azure-key" {
+		t.Errorf("api-key = %q", got)
+	}
+}
+
+func TestAnthropic(t *testing.T) {
+	server, recorded := newTestServer(t, http.StatusOK,
+		`{"content": [{"type": "text", "text": "po"}, {"type": "tool_use"}, {"type": "text", "text": "ng"}]}`)
+
+	reply, err := complete(t, Config{Provider: ProviderAnthropic, BaseURL: server.URL, APIKey: "ant-key", Model: "claude"},
+		Request{Messages: testMessages, JSON: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "pong" {
+		t.Errorf("reply = %q", reply)
+	}
+	if recorded.path != "/v1/messages" {
+		t.Errorf("path = %q", recorded.path)
+	}
+	if recorded.header.Get("x-api-key") != "ant-key" || recorded.header.Get("anthropic-version") != anthropicVersion {
+		t.Errorf("unexpected headers %v", recorded.header)
+	}
+	// system 消息单独传递，未指定时使用默认的 max_tokens
+	if recorded.body["system"] != "be brief" || recorded.body["max_tokens"] != float64(defaultAnthropicMaxTokens) {
+		t.Errorf("unexpected body %v", recorded.body)
+	}
+	if messages, _ := recorded.body["messages"].([]interface{}); len(messages) != 1 {
+		t.Errorf("messages = %v", recorded.body["messages"])
+	}
+}
+
+func TestOllama(t *testing.T) {
+	server, recorded := newTestServer(t, http.StatusOK, `{"message": {"role": "assistant", "content": "pong"}}`)
+
+	reply, err := complete(t, Config{Provider: ProviderOllama, BaseURL: server.URL + "/api/chat", Model: "llama3"},
+		Request{Messages: testMessages, JSON: true, MaxTokens: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "pong" {
+		t.Errorf("reply = %q", reply)
+	}
+	if recorded.path != "/api/chat" {
+		t.Errorf("path = %q", recorded.path)
+	}
+	if recorded.body["format"] != "json" || recorded.body["stream"] != false {
+		t.Errorf("unexpected body %v", recorded.body)
+	}
+	if options, _ := recorded.body["options"].(map[string]interface{}); options["num_predict"] != float64(16) {
+		t.Errorf("options = %v", recorded.body["options"])
+	}
+}
+
+func TestProviderErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		status   int
+		response string
+		wantErr  string
+		notErr   string
+	}{
+		{"openai error message", ProviderCustom, http.StatusUnauthorized, `{"error": {"message": "Incorrect API key"}}`, "provider returned 401: Incorrect API key", ""},
+		{"anthropic error message", ProviderAnthropic, http.StatusBadRequest, `{"error": {"type": "invalid_request_error", "message": "bad model"}}`, "provider returned 400: bad model", ""},
+		{"ollama error message", ProviderOllama, http.StatusNotFound, `{"error": "model not found"}`, "provider returned 404: model not found", ""},
+		{"raw body not echoed", ProviderCustom, http.StatusBadGateway, `<html>internal host 10.0.0.1</html>`, "provider returned 502 Bad Gateway", "10.0.0.1"},
+		{"empty response", ProviderCustom, http.StatusOK, `{"choices": []}`, "empty response", ""},
+		{"invalid JSON", ProviderOllama, http.StatusOK, `not json`, "decode provider response", ""},
+		{"response too large", ProviderOllama, http.StatusOK, `{"message": {"content": "` + strings.Repeat("a", maxResponseBytes) + `"}}`, "exceeds", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newTestServer(t, tt.status, tt.response)
+			_, err := complete(t, Config{Provider: tt.provider, BaseURL: server.URL, APIKey: "k", Model: "m"}, Request{Messages: testMessages})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+			if tt.notErr != "" && strings.Contains(err.Error(), tt.notErr) {
+				t.Errorf("error %q contains the upstream body", err)
+			}
+		})
+	}
+}
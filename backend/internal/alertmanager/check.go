@@ -0,0 +1,25 @@
+package alertmanager
+
+import (
+	amconfig "github.com/prometheus/alertmanager/config"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+)
+
+// Check 使用Alertmanager自身的解析和校验逻辑检查配置，等同于 amtool check-config
+// 引用的密钥文件只检查路径，不读取内容
+func Check(config []byte) []prometheus.CheckError {
+	if _, err := amconfig.Load(string(config)); err != nil {
+		return prometheus.NewCheckErrors(ConfigFileName, config, err)
+	}
+	return nil
+}
+
+// CheckConfig 渲染并检查完整配置
+func CheckConfig(cfg models.AlertmanagerConfig) []prometheus.CheckError {
+	config, _, err := Render(cfg)
+	if err != nil {
+		return []prometheus.CheckError{{File: ConfigFileName, Message: err.Error()}}
+	}
+	return Check(config)
+}
@@ -0,0 +1,234 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ClientConfig 连接Alertmanager的配置，设置 BearerToken 时优先于用户名密码，CACert 为PEM格式
+type ClientConfig struct {
+	URL                string
+	Username           string
+	Password           string
+	BearerToken        string
+	InsecureSkipVerify bool
+	CACert             string
+	Timeout            time.Duration
+}
+
+// Client Alertmanager管理接口和v2 API的客户端
+type Client struct {
+	baseURL     *url.URL
+	username    string
+	password    string
+	bearerToken string
+	httpClient  *http.Client
+}
+
+// APIError Alertmanager返回的非2xx响应
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("alertmanager returned %d: %s", e.StatusCode, e.Message)
+}
+
+func NewClient(cfg ClientConfig) (*Client, error) {
+	baseURL, err := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid alertmanager url: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid alertmanager url %q: scheme must be http or https", cfg.URL)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(cfg.CACert)) {
+			return nil, fmt.Errorf("no certificates found in CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &Client{
+		baseURL:     baseURL,
+		username:    cfg.Username,
+		password:    cfg.Password,
+		bearerToken: cfg.BearerToken,
+		httpClient:  &http.Client{Transport: transport, Timeout: timeout},
+	}, nil
+}
+
+// Do 发送请求，path 为转义后的路径，body不为nil时以JSON编码，返回状态码和响应体
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body interface{}) (int, []byte, error) {
+	u := *c.baseURL
+	u.RawPath = c.baseURL.EscapedPath() + path
+	unescaped, err := url.PathUnescape(u.RawPath)
+	if err != nil {
+		return 0, nil, err
+	}
+	u.Path = unescaped
+	u.RawQuery = query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
+
+// 请求并把2xx响应解码到 out，out为nil时忽略响应体
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	status, data, err := c.Do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return &APIError{StatusCode: status, Message: strings.TrimSpace(string(data))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// Healthy 请求 /-/healthy
+func (c *Client) Healthy(ctx context.Context) error {
+	return c.call(ctx, http.MethodGet, "/-/healthy", nil, nil, nil)
+}
+
+// Reload 请求 /-/reload 重新加载配置，配置有误时Alertmanager返回500和错误信息
+func (c *Client) Reload(ctx context.Context) error {
+	return c.call(ctx, http.MethodPost, "/-/reload", nil, nil, nil)
+}
+
+// Status /api/v2/status 的结果，Config 为当前加载的配置文件原文
+type Status struct {
+	Cluster struct {
+		Status string `json:"status"`
+	} `json:"cluster"`
+	Config struct {
+		Original string `json:"original"`
+	} `json:"config"`
+	Uptime      time.Time `json:"uptime"`
+	VersionInfo struct {
+		Version string `json:"version"`
+	} `json:"versionInfo"`
+}
+
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.call(ctx, http.MethodGet, "/api/v2/status", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Silence Alertmanager v2 API 中的静默，创建时 ID 为空
+type Silence struct {
+	ID        string         `json:"id,omitempty"`
+	Matchers  []Matcher      `json:"matchers"`
+	StartsAt  time.Time      `json:"startsAt"`
+	EndsAt    time.Time      `json:"endsAt"`
+	CreatedBy string         `json:"createdBy"`
+	Comment   string         `json:"comment"`
+	Status    *SilenceStatus `json:"status,omitempty"`
+	UpdatedAt *time.Time     `json:"updatedAt,omitempty"`
+}
+
+// Matcher 静默的标签匹配条件，IsEqual 为false时表示不等于或不匹配
+type Matcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+// SilenceStatus 的 State 为 active、pending 或 expired
+type SilenceStatus struct {
+	State string `json:"state"`
+}
+
+// Silences 请求 /api/v2/silences，filter 为 name="value" 形式的matcher
+func (c *Client) Silences(ctx context.Context, filter []string) ([]Silence, error) {
+	silences := []Silence{}
+	if err := c.call(ctx, http.MethodGet, "/api/v2/silences", url.Values{"filter": filter}, nil, &silences); err != nil {
+		return nil, err
+	}
+	return silences, nil
+}
+
+func (c *Client) Silence(ctx context.Context, id string) (*Silence, error) {
+	var silence Silence
+	if err := c.call(ctx, http.MethodGet, "/api/v2/silence/"+url.PathEscape(id), nil, nil, &silence); err != nil {
+		return nil, err
+	}
+	return &silence, nil
+}
+
+// CreateSilence 创建静默并返回ID，silence.ID 不为空时更新该静默
+func (c *Client) CreateSilence(ctx context.Context, silence Silence) (string, error) {
+	var result struct {
+		SilenceID string `json:"silenceID"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/api/v2/silences", nil, silence, &result); err != nil {
+		return "", err
+	}
+	return result.SilenceID, nil
+}
+
+// ExpireSilence 让静默立即过期，Alertmanager不支持删除静默
+func (c *Client) ExpireSilence(ctx context.Context, id string) error {
+	return c.call(ctx, http.MethodDelete, "/api/v2/silence/"+url.PathEscape(id), nil, nil, nil)
+}
@@ -0,0 +1,136 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 挂在 /alertmanager 路径下的测试Alertmanager，由各测试的handler检查请求并返回响应
+func newTestClient(t *testing.T, cfg ClientConfig, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg.URL = server.URL + "/alertmanager/"
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestSilences(t *testing.T) {
+	client := newTestClient(t, ClientConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/alertmanager/api/v2/silences" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if want := []string{`alertname="HighCPU"`, `env="prod"`}; !reflect.DeepEqual(r.URL.Query()["filter"], want) {
+			t.Errorf("filter = %v, want %v", r.URL.Query()["filter"], want)
+		}
+		io.WriteString(w, `[{"id": "s1", "matchers": [{"name": "alertname", "value": "HighCPU", "isRegex": false, "isEqual": true}],
+			"startsAt": "2024-01-01T00:00:00Z", "endsAt": "2024-01-01T02:00:00Z", "createdBy": "a@example.com",
+			"comment": "maintenance", "status": {"state": "active"}}]`)
+	})
+
+	silences, err := client.Silences(context.Background(), []string{`alertname="HighCPU"`, `env="prod"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(silences) != 1 || silences[0].ID != "s1" || silences[0].Status.State != "active" ||
+		!silences[0].Matchers[0].IsEqual || silences[0].Matchers[0].Value != "HighCPU" {
+		t.Errorf("unexpected silences %+v", silences)
+	}
+}
+
+func TestCreateSilence(t *testing.T) {
+	client := newTestClient(t, ClientConfig{Username: "admin", Password: "secret"}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/alertmanager/api/v2/silences" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+		}
+
+		// 创建时不发送ID和状态
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := body["id"]; ok {
+			t.Error("id sent when creating a silence")
+		}
+		if _, ok := body["status"]; ok {
+			t.Error("status sent when creating a silence")
+		}
+		if body["startsAt"] != "2024-01-01T00:00:00Z" || body["createdBy"] != "a@example.com" {
+			t.Errorf("unexpected body %v", body)
+		}
+		io.WriteString(w, `{"silenceID": "new-id"}`)
+	})
+
+	id, err := client.CreateSilence(context.Background(), Silence{
+		Matchers:  []Matcher{{Name: "alertname", Value: "HighCPU", IsEqual: true}},
+		StartsAt:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndsAt:    time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC),
+		CreatedBy: "a@example.com",
+		Comment:   "maintenance",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "new-id" {
+		t.Errorf("id = %q", id)
+	}
+}
+
+func TestExpireSilence(t *testing.T) {
+	client := newTestClient(t, ClientConfig{BearerToken: "token", Username: "admin", Password: "secret"}, func(w http.ResponseWriter, r *http.Request) {
+		// ID中的 / 按一个路径段转义
+		if r.Method != http.MethodDelete || r.URL.EscapedPath() != "/alertmanager/api/v2/silence/a%2Fb" {
+			t.Errorf("request = %s %s", r.Method, r.URL.EscapedPath())
+		}
+		// 设置Token时优先于用户名密码
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q", got)
+		}
+	})
+
+	if err := client.ExpireSilence(context.Background(), "a/b"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	client := newTestClient(t, ClientConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "silence not found", http.StatusNotFound)
+	})
+
+	_, err := client.Silence(context.Background(), "missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "silence not found" {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+}
+
+func TestNewClientErrors(t *testing.T) {
+	tests := []ClientConfig{
+		{URL: "ftp://alertmanager:9093"},
+		{URL: "://bad"},
+		{URL: "http://alertmanager:9093", CACert: "not a certificate"},
+	}
+	for _, cfg := range tests {
+		if _, err := NewClient(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package alertmanager
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+
+	"gopkg.in/yaml.v3"
+	"promeconfig-backend/internal/models"
+)
+
+const (
+	ConfigFileName = "alertmanager.yml"
+
+	// 密钥文件所在的目录，相对于输出目录，Alertmanager按配置文件所在目录解析相对路径
+	SecretsDir = "secrets"
+
+	// 根路由未指定receiver时使用的接收者，不发送任何通知
+	DefaultReceiver = "default"
+
+	generatedHeader = "# Generated by PromeConfig, do not edit manually\n"
+)
+
+type Config struct {
+	Global        *GlobalConfig  `yaml:"global,omitempty"`
+	Route         *Route         `yaml:"route"`
+	InhibitRules  []InhibitRule  `yaml:"inhibit_rules,omitempty"`
+	Receivers     []Receiver     `yaml:"receivers"`
+	TimeIntervals []TimeInterval `yaml:"time_intervals,omitempty"`
+}
+
+type GlobalConfig struct {
+	ResolveTimeout       string `yaml:"resolve_timeout,omitempty"`
+	SMTPFrom             string `yaml:"smtp_from,omitempty"`
+	SMTPSmarthost        string `yaml:"smtp_smarthost,omitempty"`
+	SMTPHello            string `yaml:"smtp_hello,omitempty"`
+	SMTPAuthUsername     string `yaml:"smtp_auth_username,omitempty"`
+	SMTPAuthPasswordFile string `yaml:"smtp_auth_password_file,omitempty"`
+	SMTPRequireTLS       *bool  `yaml:"smtp_require_tls,omitempty"`
+	SlackAPIURLFile      string `yaml:"slack_api_url_file,omitempty"`
+	PagerDutyURL         string `yaml:"pagerduty_url,omitempty"`
+	OpsGenieAPIURL       string `yaml:"opsgenie_api_url,omitempty"`
+	OpsGenieAPIKeyFile   string `yaml:"opsgenie_api_key_file,omitempty"`
+}
+
+type Route struct {
+	Receiver            string   `yaml:"receiver,omitempty"`
+	GroupBy             []string `yaml:"group_by,omitempty"`
+	Matchers            []string `yaml:"matchers,omitempty"`
+	Continue            bool     `yaml:"continue,omitempty"`
+	GroupWait           string   `yaml:"group_wait,omitempty"`
+	GroupInterval       string   `yaml:"group_interval,omitempty"`
+	RepeatInterval      string   `yaml:"repeat_interval,omitempty"`
+	MuteTimeIntervals   []string `yaml:"mute_time_intervals,omitempty"`
+	ActiveTimeIntervals []string `yaml:"active_time_intervals,omitempty"`
+	Routes              []*Route `yaml:"routes,omitempty"`
+}
+
+type InhibitRule struct {
+	SourceMatchers []string `yaml:"source_matchers,omitempty"`
+	TargetMatchers []string `yaml:"target_matchers,omitempty"`
+	Equal          []string `yaml:"equal,omitempty"`
+}
+
+type TimeInterval struct {
+	Name          string             `yaml:"name"`
+	TimeIntervals []TimeIntervalSpec `yaml:"time_intervals"`
+}
+
+type TimeIntervalSpec struct {
+	Times       []TimeRange `yaml:"times,omitempty"`
+	Weekdays    []string    `yaml:"weekdays,omitempty"`
+	DaysOfMonth []string    `yaml:"days_of_month,omitempty"`
+	Months      []string    `yaml:"months,omitempty"`
+	Years       []string    `yaml:"years,omitempty"`
+	Location    string      `yaml:"location,omitempty"`
+}
+
+type TimeRange struct {
+	StartTime string `yaml:"start_time"`
+	EndTime   string `yaml:"end_time"`
+}
+
+type Receiver struct {
+	Name             string            `yaml:"name"`
+	EmailConfigs     []EmailConfig     `yaml:"email_configs,omitempty"`
+	WebhookConfigs   []WebhookConfig   `yaml:"webhook_configs,omitempty"`
+	SlackConfigs     []SlackConfig     `yaml:"slack_configs,omitempty"`
+	PagerDutyConfigs []PagerDutyConfig `yaml:"pagerduty_configs,omitempty"`
+	OpsGenieConfigs  []OpsGenieConfig  `yaml:"opsgenie_configs,omitempty"`
+}
+
+type EmailConfig struct {
+	SendResolved     *bool             `yaml:"send_resolved,omitempty"`
+	To               string            `yaml:"to"`
+	From             string            `yaml:"from,omitempty"`
+	Smarthost        string            `yaml:"smarthost,omitempty"`
+	Hello            string            `yaml:"hello,omitempty"`
+	AuthUsername     string            `yaml:"auth_username,omitempty"`
+	AuthPasswordFile string            `yaml:"auth_password_file,omitempty"`
+	RequireTLS       *bool             `yaml:"require_tls,omitempty"`
+	Headers          map[string]string `yaml:"headers,omitempty"`
+	HTML             string            `yaml:"html,omitempty"`
+	Text             string            `yaml:"text,omitempty"`
+}
+
+type WebhookConfig struct {
+	SendResolved *bool  `yaml:"send_resolved,omitempty"`
+	URLFile      string `yaml:"url_file,omitempty"`
+	MaxAlerts    uint64 `yaml:"max_alerts,omitempty"`
+	Timeout      string `yaml:"timeout,omitempty"`
+}
+
+type SlackConfig struct {
+	SendResolved *bool  `yaml:"send_resolved,omitempty"`
+	APIURLFile   string `yaml:"api_url_file,omitempty"`
+	Channel      string `yaml:"channel,omitempty"`
+	Username     string `yaml:"username,omitempty"`
+	Color        string `yaml:"color,omitempty"`
+	Title        string `yaml:"title,omitempty"`
+	TitleLink    string `yaml:"title_link,omitempty"`
+	Text         string `yaml:"text,omitempty"`
+	IconEmoji    string `yaml:"icon_emoji,omitempty"`
+	IconURL      string `yaml:"icon_url,omitempty"`
+}
+
+type PagerDutyConfig struct {
+	SendResolved   *bool             `yaml:"send_resolved,omitempty"`
+	RoutingKeyFile string            `yaml:"routing_key_file,omitempty"`
+	ServiceKeyFile string            `yaml:"service_key_file,omitempty"`
+	URL            string            `yaml:"url,omitempty"`
+	Client         string            `yaml:"client,omitempty"`
+	ClientURL      string            `yaml:"client_url,omitempty"`
+	Description    string            `yaml:"description,omitempty"`
+	Severity       string            `yaml:"severity,omitempty"`
+	Class          string            `yaml:"class,omitempty"`
+	Component      string            `yaml:"component,omitempty"`
+	Group          string            `yaml:"group,omitempty"`
+	Details        map[string]string `yaml:"details,omitempty"`
+}
+
+type OpsGenieConfig struct {
+	SendResolved *bool               `yaml:"send_resolved,omitempty"`
+	APIKeyFile   string              `yaml:"api_key_file,omitempty"`
+	APIURL       string              `yaml:"api_url,omitempty"`
+	Message      string              `yaml:"message,omitempty"`
+	Description  string              `yaml:"description,omitempty"`
+	Source       string              `yaml:"source,omitempty"`
+	Details      map[string]string   `yaml:"details,omitempty"`
+	Responders   []OpsGenieResponder `yaml:"responders,omitempty"`
+	Tags         string              `yaml:"tags,omitempty"`
+	Note         string              `yaml:"note,omitempty"`
+	Priority     string              `yaml:"priority,omitempty"`
+}
+
+type OpsGenieResponder struct {
+	ID       string `yaml:"id,omitempty"`
+	Name     string `yaml:"name,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Type     string `yaml:"type"`
+}
+
+// 收集渲染过程中引用的密钥文件
+type secretFiles map[string][]byte
+
+// 密钥存在时记录密钥文件并返回配置中引用的路径，文件名为 "<prefix>.<key>"
+func (s secretFiles) ref(prefix string, values map[string]string, key string) string {
+	value, ok := values[key]
+	if !ok {
+		return ""
+	}
+	name := prefix + "." + key
+	s[name] = []byte(value)
+	return path.Join(SecretsDir, name)
+}
+
+// BuildConfig 生成 alertmanager.yml 的结构和其中引用的密钥文件，文件名相对于 SecretsDir
+func BuildConfig(cfg models.AlertmanagerConfig) (*Config, map[string][]byte) {
+	secrets := secretFiles{}
+	out := &Config{
+		Global:    buildGlobal(cfg.Global, cfg.GlobalSecrets, secrets),
+		Route:     buildRoute(cfg.Route),
+		Receivers: make([]Receiver, 0, len(cfg.Receivers)+1),
+	}
+
+	hasDefault := false
+	for _, receiver := range cfg.Receivers {
+		out.Receivers = append(out.Receivers, buildReceiver(receiver, secrets))
+		hasDefault = hasDefault || receiver.Name == DefaultReceiver
+	}
+	if out.Route.Receiver == "" {
+		out.Route.Receiver = DefaultReceiver
+		if !hasDefault {
+			out.Receivers = append(out.Receivers, Receiver{Name: DefaultReceiver})
+		}
+	}
+
+	for _, rule := range cfg.InhibitRules {
+		out.InhibitRules = append(out.InhibitRules, InhibitRule(rule))
+	}
+	for _, interval := range cfg.TimeIntervals {
+		ti := TimeInterval{Name: interval.Name}
+		for _, spec := range interval.TimeIntervals {
+			s := TimeIntervalSpec{
+				Weekdays:    spec.Weekdays,
+				DaysOfMonth: spec.DaysOfMonth,
+				Months:      spec.Months,
+				Years:       spec.Years,
+				Location:    spec.Location,
+			}
+			for _, t := range spec.Times {
+				s.Times = append(s.Times, TimeRange(t))
+			}
+			ti.TimeIntervals = append(ti.TimeIntervals, s)
+		}
+		out.TimeIntervals = append(out.TimeIntervals, ti)
+	}
+
+	return out, secrets
+}
+
+func buildGlobal(global models.AlertmanagerGlobal, values map[string]string, secrets secretFiles) *GlobalConfig {
+	out := GlobalConfig{
+		ResolveTimeout:       global.ResolveTimeout,
+		SMTPFrom:             global.SMTPFrom,
+		SMTPSmarthost:        global.SMTPSmarthost,
+		SMTPHello:            global.SMTPHello,
+		SMTPAuthUsername:     global.SMTPAuthUsername,
+		SMTPAuthPasswordFile: secrets.ref("global", values, "smtp_auth_password"),
+		SMTPRequireTLS:       global.SMTPRequireTLS,
+		SlackAPIURLFile:      secrets.ref("global", values, "slack_api_url"),
+		PagerDutyURL:         global.PagerDutyURL,
+		OpsGenieAPIURL:       global.OpsGenieAPIURL,
+		OpsGenieAPIKeyFile:   secrets.ref("global", values, "opsgenie_api_key"),
+	}
+	if out == (GlobalConfig{}) {
+		return nil
+	}
+	return &out
+}
+
+func buildRoute(route models.AlertmanagerRoute) *Route {
+	out := &Route{
+		Receiver:            route.Receiver,
+		GroupBy:             route.GroupBy,
+		Matchers:            route.Matchers,
+		Continue:            route.Continue,
+		GroupWait:           route.GroupWait,
+		GroupInterval:       route.GroupInterval,
+		RepeatInterval:      route.RepeatInterval,
+		MuteTimeIntervals:   route.MuteTimeIntervals,
+		ActiveTimeIntervals: route.ActiveTimeIntervals,
+	}
+	for _, child := range route.Routes {
+		out.Routes = append(out.Routes, buildRoute(child))
+	}
+	return out
+}
+
+func buildReceiver(receiver models.AlertmanagerReceiver, secrets secretFiles) Receiver {
+	prefix := receiver.ID.String()
+	out := Receiver{Name: receiver.Name}
+
+	for _, c := range receiver.EmailConfigs {
+		out.EmailConfigs = append(out.EmailConfigs, EmailConfig{
+			SendResolved:     c.SendResolved,
+			To:               c.To,
+			From:             c.From,
+			Smarthost:        c.Smarthost,
+			Hello:            c.Hello,
+			AuthUsername:     c.AuthUsername,
+			AuthPasswordFile: secrets.ref(prefix, receiver.Secrets, SecretKey("email_configs", c.ID, "auth_password")),
+			RequireTLS:       c.RequireTLS,
+			Headers:          c.Headers,
+			HTML:             c.HTML,
+			Text:             c.Text,
+		})
+	}
+	for _, c := range receiver.WebhookConfigs {
+		out.WebhookConfigs = append(out.WebhookConfigs, WebhookConfig{
+			SendResolved: c.SendResolved,
+			URLFile:      secrets.ref(prefix, receiver.Secrets, SecretKey("webhook_configs", c.ID, "url")),
+			MaxAlerts:    c.MaxAlerts,
+			Timeout:      c.Timeout,
+		})
+	}
+	for _, c := range receiver.SlackConfigs {
+		out.SlackConfigs = append(out.SlackConfigs, SlackConfig{
+			SendResolved: c.SendResolved,
+			APIURLFile:   secrets.ref(prefix, receiver.Secrets, SecretKey("slack_configs", c.ID, "api_url")),
+			Channel:      c.Channel,
+			Username:     c.Username,
+			Color:        c.Color,
+			Title:        c.Title,
+			TitleLink:    c.TitleLink,
+			Text:         c.Text,
+			IconEmoji:    c.IconEmoji,
+			IconURL:      c.IconURL,
+		})
+	}
+	for _, c := range receiver.PagerDutyConfigs {
+		out.PagerDutyConfigs = append(out.PagerDutyConfigs, PagerDutyConfig{
+			SendResolved:   c.SendResolved,
+			RoutingKeyFile: secrets.ref(prefix, receiver.Secrets, SecretKey("pagerduty_configs", c.ID, "routing_key")),
+			ServiceKeyFile: secrets.ref(prefix, receiver.Secrets, SecretKey("pagerduty_configs", c.ID, "service_key")),
+			URL:            c.URL,
+			Client:         c.Client,
+			ClientURL:      c.ClientURL,
+			Description:    c.Description,
+			Severity:       c.Severity,
+			Class:          c.Class,
+			Component:      c.Component,
+			Group:          c.Group,
+			Details:        c.Details,
+		})
+	}
+	for _, c := range receiver.OpsGenieConfigs {
+		og := OpsGenieConfig{
+			SendResolved: c.SendResolved,
+			APIKeyFile:   secrets.ref(prefix, receiver.Secrets, SecretKey("opsgenie_configs", c.ID, "api_key")),
+			APIURL:       c.APIURL,
+			Message:      c.Message,
+			Description:  c.Description,
+			Source:       c.Source,
+			Details:      c.Details,
+			Tags:         c.Tags,
+			Note:         c.Note,
+			Priority:     c.Priority,
+		}
+		for _, r := range c.Responders {
+			og.Responders = append(og.Responders, OpsGenieResponder(r))
+		}
+		out.OpsGenieConfigs = append(out.OpsGenieConfigs, og)
+	}
+
+	return out
+}
+
+// SecretKey 接收者密钥在 Secrets 中的键，如 "email_configs.<配置ID>.auth_password"
+func SecretKey(integration, id, field string) string {
+	return integration + "." + id + "." + field
+}
+
+// Render 生成 alertmanager.yml 和其中引用的密钥文件
+func Render(cfg models.AlertmanagerConfig) ([]byte, map[string][]byte, error) {
+	out, secrets := BuildConfig(cfg)
+
+	var buf bytes.Buffer
+	buf.WriteString(generatedHeader)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(out); err != nil {
+		return nil, nil, fmt.Errorf("failed to render %s: %w", ConfigFileName, err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to render %s: %w", ConfigFileName, err)
+	}
+
+	return buf.Bytes(), secrets, nil
+}
@@ -0,0 +1,150 @@
+package alertmanager
+
+import (
+	"fmt"
+	"sort"
+
+	amconfig "github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/common/model"
+)
+
+// RouteTestResult 一组标签在路由树中的匹配结果，等同于 amtool config routes test
+// Unresolved 为规则中值含模板、未参与匹配的标签
+type RouteTestResult struct {
+	Labels      map[string]string `json:"labels"`
+	Unresolved  []string          `json:"unresolved"`
+	Receivers   []string          `json:"receivers"`
+	Routes      []MatchedRoute    `json:"routes"`
+	Inhibitions []Inhibition      `json:"inhibitions"`
+}
+
+// MatchedRoute 匹配到的一个路由，Path 为从根路由到该路由经过的节点，各项设置已按继承规则展开
+// 按全部标签分组时 GroupBy 为 ["..."]
+type MatchedRoute struct {
+	ID                  string      `json:"id"`
+	Path                []RouteStep `json:"path"`
+	Receiver            string      `json:"receiver"`
+	GroupBy             []string    `json:"group_by"`
+	GroupWait           string      `json:"group_wait"`
+	GroupInterval       string      `json:"group_interval"`
+	RepeatInterval      string      `json:"repeat_interval"`
+	MuteTimeIntervals   []string    `json:"mute_time_intervals,omitempty"`
+	ActiveTimeIntervals []string    `json:"active_time_intervals,omitempty"`
+}
+
+// RouteStep 路径上的一个路由节点，Index 为在上级 routes 中的序号，根路由为-1
+type RouteStep struct {
+	Index    int      `json:"index"`
+	Matchers []string `json:"matchers"`
+	Receiver string   `json:"receiver"`
+	Continue bool     `json:"continue,omitempty"`
+}
+
+// Inhibition 与这组标签相关的抑制规则
+// Role 为 target 时，匹配 source_matchers 且 equal 中标签相同的告警触发期间这组标签的告警不会通知；
+// 为 source 时，这组标签的告警会抑制其他匹配 target_matchers 的告警
+type Inhibition struct {
+	Index          int      `json:"index"`
+	Role           string   `json:"role"`
+	SourceMatchers []string `json:"source_matchers"`
+	TargetMatchers []string `json:"target_matchers"`
+	Equal          []string `json:"equal,omitempty"`
+}
+
+// TestRoutes 用Alertmanager的路由逻辑匹配一组标签，config 为 alertmanager.yml 的内容
+func TestRoutes(config []byte, lset map[string]string) (*RouteTestResult, error) {
+	cfg, err := amconfig.Load(string(config))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ConfigFileName, err)
+	}
+
+	labelSet := model.LabelSet{}
+	for name, value := range lset {
+		labelSet[model.LabelName(name)] = model.LabelValue(value)
+	}
+
+	root := dispatch.NewRoute(cfg.Route, nil)
+	paths := map[*dispatch.Route][]RouteStep{}
+	var walk func(r *dispatch.Route, index int, parent []RouteStep)
+	walk = func(r *dispatch.Route, index int, parent []RouteStep) {
+		path := append(append([]RouteStep{}, parent...), RouteStep{
+			Index:    index,
+			Matchers: matcherStrings(r.Matchers),
+			Receiver: r.RouteOpts.Receiver,
+			Continue: r.Continue,
+		})
+		paths[r] = path
+		for i, child := range r.Routes {
+			walk(child, i, path)
+		}
+	}
+	walk(root, -1, nil)
+
+	result := &RouteTestResult{
+		Labels:      lset,
+		Unresolved:  []string{},
+		Receivers:   []string{},
+		Routes:      []MatchedRoute{},
+		Inhibitions: []Inhibition{},
+	}
+	seen := map[string]bool{}
+	for _, r := range root.Match(labelSet) {
+		opts := r.RouteOpts
+		groupBy := make([]string, 0, len(opts.GroupBy))
+		for name := range opts.GroupBy {
+			groupBy = append(groupBy, string(name))
+		}
+		sort.Strings(groupBy)
+		if opts.GroupByAll {
+			groupBy = []string{"..."}
+		}
+
+		result.Routes = append(result.Routes, MatchedRoute{
+			ID:                  r.ID(),
+			Path:                paths[r],
+			Receiver:            opts.Receiver,
+			GroupBy:             groupBy,
+			GroupWait:           model.Duration(opts.GroupWait).String(),
+			GroupInterval:       model.Duration(opts.GroupInterval).String(),
+			RepeatInterval:      model.Duration(opts.RepeatInterval).String(),
+			MuteTimeIntervals:   opts.MuteTimeIntervals,
+			ActiveTimeIntervals: opts.ActiveTimeIntervals,
+		})
+		if !seen[opts.Receiver] {
+			seen[opts.Receiver] = true
+			result.Receivers = append(result.Receivers, opts.Receiver)
+		}
+	}
+
+	for i, rule := range cfg.InhibitRules {
+		source := labels.Matchers(rule.SourceMatchers)
+		target := labels.Matchers(rule.TargetMatchers)
+		for _, role := range []struct {
+			name     string
+			matchers labels.Matchers
+		}{{"target", target}, {"source", source}} {
+			if !role.matchers.Matches(labelSet) {
+				continue
+			}
+			result.Inhibitions = append(result.Inhibitions, Inhibition{
+				Index:          i,
+				Role:           role.name,
+				SourceMatchers: matcherStrings(source),
+				TargetMatchers: matcherStrings(target),
+				Equal:          rule.Equal,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+func matcherStrings(matchers labels.Matchers) []string {
+	out := make([]string, 0, len(matchers))
+	for _, m := range matchers {
+		out = append(out, m.String())
+	}
+	return out
+}
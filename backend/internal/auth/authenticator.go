@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials 用户不存在或密码错误，登录时会继续尝试下一个认证方式
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Authenticator 用户名/密码认证方式
+type Authenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, username, password string) (*Identity, error)
+}
+
+// LocalAuthenticator 使用users表中的bcrypt密码认证
+type LocalAuthenticator struct {
+	db *sql.DB
+}
+
+func NewLocalAuthenticator(db *sql.DB) *LocalAuthenticator {
+	return &LocalAuthenticator{db: db}
+}
+
+func (a *LocalAuthenticator) Name() string {
+	return "local"
+}
+
+// Authenticate 认证成功时 Identity.Subject 为用户ID
+func (a *LocalAuthenticator) Authenticate(ctx context.Context, username, password string) (*Identity, error) {
+	var userID, passwordHash string
+	err := a.db.QueryRowContext(ctx, `
+		SELECT id, COALESCE(password_hash, '') FROM users WHERE email = $1`, username).Scan(&userID, &passwordHash)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Identity{Provider: a.Name(), Subject: userID, Email: username}, nil
+}
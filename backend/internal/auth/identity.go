@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"promeconfig-backend/internal/rbac"
+)
+
+// Identity 外部身份提供方认证后返回的用户身份
+type Identity struct {
+	// Provider 身份来源，例如 oidc
+	Provider string
+	// Subject 身份提供方中的唯一标识
+	Subject string
+	Email   string
+	Groups  []string
+}
+
+// MapGroupsToRole 按映射关系把用户组转换为角色，命中多个时取最高的角色
+func MapGroupsToRole(groups []string, mapping map[string]string) (rbac.Role, bool) {
+	var role rbac.Role
+	found := false
+
+	for _, group := range groups {
+		mapped := rbac.Role(mapping[group])
+		if !mapped.Valid() {
+			continue
+		}
+		if !found || mapped.Higher(role) {
+			role = mapped
+			found = true
+		}
+	}
+
+	return role, found
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPConfig LDAP/AD认证配置
+type LDAPConfig struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	CAFile             string
+
+	// 用于搜索用户的服务账号，为空时匿名绑定
+	BindDN       string
+	BindPassword string
+
+	// 用户搜索，过滤器中的 {username} 会被替换为转义后的登录名
+	BaseDN     string
+	UserFilter string
+
+	EmailAttribute   string
+	SubjectAttribute string
+	GroupAttribute   string
+
+	// 可选的组搜索，过滤器中的 {dn} 会被替换为用户DN
+	GroupBaseDN string
+	GroupFilter string
+
+	Timeout time.Duration
+}
+
+// LDAPAuthenticator 先用服务账号搜索用户，再以用户DN和密码绑定验证
+type LDAPAuthenticator struct {
+	cfg       LDAPConfig
+	tlsConfig *tls.Config
+}
+
+func NewLDAPAuthenticator(cfg LDAPConfig) (*LDAPAuthenticator, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CAFile != "" {
+		caCert, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LDAP CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("no certificates found in LDAP CA file")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	return &LDAPAuthenticator{cfg: cfg, tlsConfig: tlsConfig}, nil
+}
+
+func (a *LDAPAuthenticator) Name() string {
+	return "ldap"
+}
+
+func (a *LDAPAuthenticator) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(a.cfg.URL,
+		ldap.DialWithTLSConfig(a.tlsConfig),
+		ldap.DialWithDialer(&net.Dialer{Timeout: a.cfg.Timeout}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP: %w", err)
+	}
+	conn.SetTimeout(a.cfg.Timeout)
+
+	if a.cfg.StartTLS {
+		if err := conn.StartTLS(a.tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, username, password string) (*Identity, error) {
+	// 空密码会被服务器当作匿名绑定而成功，必须拒绝
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if a.cfg.BindDN != "" {
+		err = conn.Bind(a.cfg.BindDN, a.cfg.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind service account: %w", err)
+	}
+
+	attributes := []string{a.cfg.EmailAttribute, a.cfg.GroupAttribute}
+	if a.cfg.SubjectAttribute != "" {
+		attributes = append(attributes, a.cfg.SubjectAttribute)
+	}
+
+	filter := strings.ReplaceAll(a.cfg.UserFilter, "{username}", ldap.EscapeFilter(username))
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		filter, attributes, nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search user: %w", err)
+	}
+
+	if len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to bind user: %w", err)
+	}
+
+	identity := &Identity{
+		Provider: a.Name(),
+		Subject:  entry.DN,
+		Email:    entry.GetAttributeValue(a.cfg.EmailAttribute),
+	}
+	if a.cfg.SubjectAttribute != "" {
+		if subject := entry.GetAttributeValue(a.cfg.SubjectAttribute); subject != "" {
+			identity.Subject = subject
+		}
+	}
+
+	groups := entry.GetAttributeValues(a.cfg.GroupAttribute)
+	if a.cfg.GroupBaseDN != "" && a.cfg.GroupFilter != "" {
+		// 组搜索沿用服务账号的权限
+		if a.cfg.BindDN != "" {
+			err = conn.Bind(a.cfg.BindDN, a.cfg.BindPassword)
+		} else {
+			err = conn.UnauthenticatedBind("")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to rebind service account: %w", err)
+		}
+
+		groupFilter := strings.ReplaceAll(a.cfg.GroupFilter, "{dn}", ldap.EscapeFilter(entry.DN))
+		groupResult, err := conn.Search(ldap.NewSearchRequest(
+			a.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+			groupFilter, []string{"cn"}, nil))
+		if err != nil {
+			return nil, fmt.Errorf("failed to search groups: %w", err)
+		}
+		for _, group := range groupResult.Entries {
+			groups = append(groups, group.DN)
+		}
+	}
+
+	identity.Groups = expandGroupNames(groups)
+	return identity, nil
+}
+
+// 映射配置中既可以写完整DN，也可以只写CN
+func expandGroupNames(groupDNs []string) []string {
+	var groups []string
+	for _, dn := range groupDNs {
+		groups = append(groups, dn)
+		parsed, err := ldap.ParseDN(dn)
+		if err != nil || len(parsed.RDNs) == 0 {
+			continue
+		}
+		for _, attr := range parsed.RDNs[0].Attributes {
+			if strings.EqualFold(attr.Type, "cn") {
+				groups = append(groups, attr.Value)
+			}
+		}
+	}
+	return groups
+}
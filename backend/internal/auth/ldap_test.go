@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// 测试用的LDAP服务器，只实现简单绑定和搜索
+type fakeLDAP struct {
+	// 用户DN对应的密码
+	passwords map[string]string
+	// 搜索过滤器对应的结果
+	results map[string][]*ldap.Entry
+
+	mu       sync.Mutex
+	binds    []string
+	searches []string
+}
+
+func (s *fakeLDAP) start(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return "ldap://" + listener.Addr().String()
+}
+
+func (s *fakeLDAP) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			s.mu.Lock()
+			s.binds = append(s.binds, dn)
+			s.mu.Unlock()
+
+			code := ldap.LDAPResultSuccess
+			if expected, ok := s.passwords[dn]; (dn != "" || password != "") && (!ok || expected != password) {
+				code = ldap.LDAPResultInvalidCredentials
+			}
+			conn.Write(ldapMessage(messageID, ldapResult(ldap.ApplicationBindResponse, code)).Bytes())
+
+		case ldap.ApplicationSearchRequest:
+			filter, err := ldap.DecompileFilter(op.Children[6])
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.searches = append(s.searches, filter)
+			s.mu.Unlock()
+
+			for _, entry := range s.results[filter] {
+				conn.Write(ldapMessage(messageID, ldapEntry(entry)).Bytes())
+			}
+			conn.Write(ldapMessage(messageID, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)).Bytes())
+
+		default:
+			return
+		}
+	}
+}
+
+func ldapMessage(messageID int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	packet.AppendChild(op)
+	return packet
+}
+
+func ldapResult(tag ber.Tag, code int) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return op
+}
+
+func ldapEntry(entry *ldap.Entry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "DN"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, attr := range entry.Attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attr.Name, "Type"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range attr.Values {
+			values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(values)
+		attributes.AppendChild(attribute)
+	}
+	op.AppendChild(attributes)
+	return op
+}
+
+const (
+	serviceDN = "cn=svc,dc=example,dc=com"
+	aliceDN   = "uid=alice,ou=people,dc=example,dc=com"
+)
+
+func newFakeLDAP() *fakeLDAP {
+	alice := ldap.NewEntry(aliceDN, map[string][]string{
+		"mail":      {"alice@example.com"},
+		"entryUUID": {"5f0c-alice"},
+		"memberOf":  {"cn=admins,ou=groups,dc=example,dc=com"},
+	})
+	return &fakeLDAP{
+		passwords: map[string]string{serviceDN: "svc-secret", aliceDN: "alice-secret"},
+		results: map[string][]*ldap.Entry{
+			"(uid=alice)": {alice},
+			"(uid=twin)": {
+				ldap.NewEntry("uid=twin,ou=a,dc=example,dc=com", nil),
+				ldap.NewEntry("uid=twin,ou=b,dc=example,dc=com", nil),
+			},
+			"(member=" + aliceDN + ")": {
+				ldap.NewEntry("cn=oncall,ou=groups,dc=example,dc=com", nil),
+			},
+		},
+	}
+}
+
+func testLDAPConfig(url string) LDAPConfig {
+	return LDAPConfig{
+		URL:            url,
+		BindDN:         serviceDN,
+		BindPassword:   "svc-secret",
+		BaseDN:         "dc=example,dc=com",
+		UserFilter:     "(uid={username})",
+		EmailAttribute: "mail",
+		GroupAttribute: "memberOf",
+	}
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	server := newFakeLDAP()
+	url := server.start(t)
+
+	tests := []struct {
+		name     string
+		cfg      func(*LDAPConfig)
+		username string
+		password string
+		want     *Identity
+		wantErr  error
+	}{
+		{
+			name:     "success",
+			username: "alice",
+			password: "alice-secret",
+			want: &Identity{
+				Provider: "ldap",
+				Subject:  aliceDN,
+				Email:    "alice@example.com",
+				Groups:   []string{"cn=admins,ou=groups,dc=example,dc=com", "admins"},
+			},
+		},
+		{
+			name: "subject attribute and group search",
+			cfg: func(cfg *LDAPConfig) {
+				cfg.SubjectAttribute = "entryUUID"
+				cfg.GroupBaseDN = "ou=groups,dc=example,dc=com"
+				cfg.GroupFilter = "(member={dn})"
+			},
+			username: "alice",
+			password: "alice-secret",
+			want: &Identity{
+				Provider: "ldap",
+				Subject:  "5f0c-alice",
+				Email:    "alice@example.com",
+				Groups: []string{
+					"cn=admins,ou=groups,dc=example,dc=com", "admins",
+					"cn=oncall,ou=groups,dc=example,dc=com", "oncall",
+				},
+			},
+		},
+		{name: "wrong password", username: "alice", password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "unknown user", username: "bob", password: "secret", wantErr: ErrInvalidCredentials},
+		{name: "ambiguous user", username: "twin", password: "secret", wantErr: ErrInvalidCredentials},
+		{name: "empty password", username: "alice", password: "", wantErr: ErrInvalidCredentials},
+		{name: "filter injection", username: "*", password: "alice-secret", wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testLDAPConfig(url)
+			if tt.cfg != nil {
+				tt.cfg(&cfg)
+			}
+			authenticator, err := NewLDAPAuthenticator(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			identity, err := authenticator.Authenticate(context.Background(), tt.username, tt.password)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(identity, tt.want) {
+				t.Errorf("identity = %+v, want %+v", identity, tt.want)
+			}
+		})
+	}
+}
+
+func TestLDAPBindsAndFilters(t *testing.T) {
+	server := newFakeLDAP()
+	cfg := testLDAPConfig(server.start(t))
+	cfg.GroupBaseDN = "ou=groups,dc=example,dc=com"
+	cfg.GroupFilter = "(member={dn})"
+	authenticator, err := NewLDAPAuthenticator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := authenticator.Authenticate(context.Background(), "alice", "alice-secret"); err != nil {
+		t.Fatal(err)
+	}
+	// 先用服务账号搜索，再以用户绑定验证密码，组搜索前重新绑定服务账号
+	if want := []string{serviceDN, aliceDN, serviceDN}; !reflect.DeepEqual(server.binds, want) {
+		t.Errorf("binds = %v, want %v", server.binds, want)
+	}
+
+	// 登录名中的特殊字符在过滤器中转义
+	server.searches = nil
+	if _, err := authenticator.Authenticate(context.Background(), "a*)(uid=*", "x"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if want := []string{`(uid=a\2a\29\28uid=\2a)`}; !reflect.DeepEqual(server.searches, want) {
+		t.Errorf("searches = %v, want %v", server.searches, want)
+	}
+}
+
+func TestLDAPServiceBindFailure(t *testing.T) {
+	server := newFakeLDAP()
+	cfg := testLDAPConfig(server.start(t))
+	cfg.BindPassword = "wrong"
+	authenticator, err := NewLDAPAuthenticator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 服务账号配置错误不是用户的凭据错误，不应继续尝试其他认证方式
+	_, err = authenticator.Authenticate(context.Background(), "alice", "alice-secret")
+	if err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("err = %v, want a service account error", err)
+	}
+}
+
+func TestExpandGroupNames(t *testing.T) {
+	got := expandGroupNames([]string{
+		"cn=admins,ou=groups,dc=example,dc=com",
+		"ou=groups,dc=example,dc=com",
+		"developers",
+	})
+	want := []string{"cn=admins,ou=groups,dc=example,dc=com", "admins", "ou=groups,dc=example,dc=com", "developers"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig OIDC客户端配置
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string
+	// 身份提供方不返回 email_verified 时信任其邮箱，只应对只允许已验证邮箱的IdP开启
+	TrustEmail bool
+}
+
+// OIDC 授权码 + PKCE 登录流程
+// 身份提供方的discovery在首次使用时进行，避免IdP不可用时服务无法启动
+type OIDC struct {
+	cfg OIDCConfig
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// AuthRequest 发起登录时生成的一次性参数，需要在回调时原样取回
+type AuthRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	URL          string
+}
+
+func NewOIDC(cfg OIDCConfig) *OIDC {
+	return &OIDC{cfg: cfg}
+}
+
+// 执行discovery并缓存结果
+func (o *OIDC) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.oauth2 != nil {
+		return o.oauth2, o.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, o.cfg.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+
+	o.oauth2 = &oauth2.Config{
+		ClientID:     o.cfg.ClientID,
+		ClientSecret: o.cfg.ClientSecret,
+		RedirectURL:  o.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       o.cfg.Scopes,
+	}
+	o.verifier = provider.Verifier(&oidc.Config{ClientID: o.cfg.ClientID})
+
+	return o.oauth2, o.verifier, nil
+}
+
+// Begin 生成state、nonce和PKCE verifier，并返回身份提供方的授权地址
+func (o *OIDC) Begin(ctx context.Context) (*AuthRequest, error) {
+	oauth2Config, _, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier := oauth2.GenerateVerifier()
+
+	return &AuthRequest{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		URL: oauth2Config.AuthCodeURL(state,
+			oidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier)),
+	}, nil
+}
+
+// Exchange 用授权码换取并校验ID Token，返回用户身份
+func (o *OIDC) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	oauth2Config, verifier, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response missing id_token")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify id_token: %w", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse claims: %w", err)
+	}
+
+	identity := &Identity{
+		Provider: "oidc",
+		Subject:  idToken.Subject,
+		Groups:   stringsClaim(claims[o.cfg.GroupsClaim]),
+	}
+	identity.Email, _ = claims["email"].(string)
+
+	// 邮箱经IdP验证后才能用于创建或关联账号，未返回 email_verified 时按 TrustEmail 决定
+	verified, ok := emailVerifiedClaim(claims["email_verified"])
+	if !(verified || (!ok && o.cfg.TrustEmail)) {
+		identity.Email = ""
+	}
+
+	return identity, nil
+}
+
+// email_verified 一般是布尔值，部分IdP返回字符串，ok 为false表示没有该claim
+func emailVerifiedClaim(value interface{}) (verified, ok bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		return v == "true", true
+	}
+	return false, false
+}
+
+// 组claim可能是字符串数组或单个字符串
+func stringsClaim(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "promeconfig"
+
+// 测试用的身份提供方，实现discovery、JWKS和授权码换token，授权请求由 authorize 直接完成
+type fakeIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]fakeGrant
+}
+
+// 一个授权码对应的PKCE challenge和ID Token中的claims
+type fakeGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{key: key, codes: map[string]fakeGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// 模拟用户在IdP登录并同意授权，返回回调中的授权码
+func (idp *fakeIdP) authorize(t *testing.T, authURL string, claims jwt.MapClaims) (code, state string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("client_id") != testClientID || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	idToken := jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"aud":   testClientID,
+		"sub":   "user-1",
+		"nonce": query.Get("nonce"),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		idToken[name] = value
+	}
+
+	code = "code-" + query.Get("state")
+	idp.mu.Lock()
+	idp.codes[code] = fakeGrant{challenge: query.Get("code_challenge"), claims: idToken}
+	idp.mu.Unlock()
+	return code, query.Get("state")
+}
+
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	grant, ok := idp.codes[r.FormValue("code")]
+	delete(idp.codes, r.FormValue("code"))
+	idp.mu.Unlock()
+
+	// 授权码只能使用一次，code_verifier 必须与授权请求中的challenge对应
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "invalid_grant"}`))
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+	token.Header["kid"] = "test"
+	idToken, err := token.SignedString(idp.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (idp *fakeIdP) oidc(trustEmail bool) *OIDC {
+	return NewOIDC(OIDCConfig{
+		Issuer:      idp.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost/api/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+		GroupsClaim: "groups",
+		TrustEmail:  trustEmail,
+	})
+}
+
+func TestOIDCExchange(t *testing.T) {
+	idp := newFakeIdP(t)
+
+	tests := []struct {
+		name       string
+		claims     jwt.MapClaims
+		trustEmail bool
+		wantEmail  string
+		wantGroups []string
+	}{
+		{
+			name:       "verified email",
+			claims:     jwt.MapClaims{"email": "a@example.com", "email_verified": true, "groups": []string{"admins", "ops"}},
+			wantEmail:  "a@example.com",
+			wantGroups: []string{"admins", "ops"},
+		},
+		{name: "verified as string", claims: jwt.MapClaims{"email": "a@example.com", "email_verified": "true"}, wantEmail: "a@example.com"},
+		{name: "unverified email", claims: jwt.MapClaims{"email": "a@example.com", "email_verified": false}},
+		{name: "unverified email with trust", claims: jwt.MapClaims{"email": "a@example.com", "email_verified": false}, trustEmail: true},
+		{name: "no email_verified claim", claims: jwt.MapClaims{"email": "a@example.com"}},
+		{name: "no email_verified claim with trust", claims: jwt.MapClaims{"email": "a@example.com"}, trustEmail: true, wantEmail: "a@example.com"},
+		{name: "single group", claims: jwt.MapClaims{"groups": "admins"}, wantGroups: []string{"admins"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := idp.oidc(tt.trustEmail)
+			authReq, err := o.Begin(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, state := idp.authorize(t, authReq.URL, tt.claims)
+			if state != authReq.State {
+				t.Errorf("state = %q, want %q", state, authReq.State)
+			}
+
+			identity, err := o.Exchange(context.Background(), code, authReq.CodeVerifier, authReq.Nonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &Identity{Provider: "oidc", Subject: "user-1", Email: tt.wantEmail, Groups: tt.wantGroups}
+			if !reflect.DeepEqual(identity, want) {
+				t.Errorf("identity = %+v, want %+v", identity, want)
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeErrors(t *testing.T) {
+	idp := newFakeIdP(t)
+	o := idp.oidc(false)
+	claims := jwt.MapClaims{"email": "a@example.com", "email_verified": true}
+
+	t.Run("wrong code verifier", func(t *testing.T) {
+		authReq, err := o.Begin(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, _ := idp.authorize(t, authReq.URL, claims)
+		if _, err := o.Exchange(context.Background(), code, "wrong-verifier", authReq.Nonce); err == nil {
+			t.Error("expected an error for a wrong code verifier")
+		}
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		authReq, err := o.Begin(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, _ := idp.authorize(t, authReq.URL, claims)
+		_, err = o.Exchange(context.Background(), code, authReq.CodeVerifier, "other-nonce")
+		if err == nil || !strings.Contains(err.Error(), "nonce") {
+			t.Errorf("err = %v, want a nonce mismatch", err)
+		}
+	})
+
+	t.Run("wrong audience", func(t *testing.T) {
+		authReq, err := o.Begin(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, _ := idp.authorize(t, authReq.URL, jwt.MapClaims{"aud": "other-client"})
+		if _, err := o.Exchange(context.Background(), code, authReq.CodeVerifier, authReq.Nonce); err == nil {
+			t.Error("expected an error for an id_token of another client")
+		}
+	})
+
+	t.Run("code reused", func(t *testing.T) {
+		authReq, err := o.Begin(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, _ := idp.authorize(t, authReq.URL, claims)
+		if _, err := o.Exchange(context.Background(), code, authReq.CodeVerifier, authReq.Nonce); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := o.Exchange(context.Background(), code, authReq.CodeVerifier, authReq.Nonce); err == nil {
+			t.Error("expected an error for a reused code")
+		}
+	})
+}
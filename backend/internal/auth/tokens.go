@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// GenerateOneTimeToken 生成一次性token，数据库只保存其哈希
+func GenerateOneTimeToken() (token string, hash string, err error) {
+	token, err = randomString()
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashOneTimeToken(token), nil
+}
+
+func HashOneTimeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,6 +2,11 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
 )
 
 type Config struct {
@@ -9,6 +14,94 @@ type Config struct {
 	JWTSecret      string
 	Environment    string
 	Port           string
+
+	// 可信的反向代理地址或网段，只有来自这些地址的 X-Forwarded-For 才用于确定客户端IP，为空时使用连接的地址
+	TrustedProxies []string
+
+	// 本地邮箱/密码登录，使用SSO时可关闭
+	LocalAuthEnabled bool
+
+	// 同步时生成的Prometheus配置文件所在目录，每个组织一个子目录
+	PrometheusConfigDir string
+
+	// Prometheus HTTP API，用于回测等查询，PrometheusURL为空时不启用
+	PrometheusURL                string
+	PrometheusUsername           string
+	PrometheusPassword           string
+	PrometheusInsecureSkipVerify bool
+	PrometheusCAFile             string
+
+	// 使用环境变量中默认Prometheus实例和默认Alertmanager连接的组织ID，为空时所有组织都要自己配置
+	OperatorOrganizationID string
+
+	// 查询代理对每个用户的限制
+	PrometheusQueryTimeout       time.Duration
+	PrometheusQueryMaxRange      time.Duration
+	PrometheusQueryMaxResults    int
+	PrometheusQueryMaxConcurrent int
+
+	// 运维组织未保存Alertmanager设置时使用的默认连接，AlertmanagerURL为空时不重新加载
+	AlertmanagerURL                string
+	AlertmanagerUsername           string
+	AlertmanagerPassword           string
+	AlertmanagerInsecureSkipVerify bool
+
+	// 前端地址，用于生成邮件中的链接
+	AppURL string
+
+	// 邮件发送方式: smtp、file 或 log
+	MailDriver      string
+	MailFrom        string
+	MailFileDir     string
+	SMTPHost        string
+	SMTPPort        int
+	SMTPUsername    string
+	SMTPPassword    string
+	SMTPImplicitTLS bool
+
+	// 两步验证中显示的签发方名称
+	TOTPIssuer string
+
+	// 加密保存的密钥（如AI设置的API Key）使用的主密钥，格式: 版本=base64编码的32字节密钥,...
+	// SecretsActiveKeyVersion 为0时用最大的版本加密
+	SecretsKeys             map[string]string
+	SecretsActiveKeyVersion int
+	// 未配置主密钥时使用固定的开发密钥，只能用于本地开发
+	SecretsInsecureDevKey bool
+
+	// OIDC单点登录，OIDCIssuer为空时不启用
+	OIDCIssuer            string
+	OIDCClientID          string
+	OIDCClientSecret      string
+	OIDCRedirectURL       string
+	OIDCScopes            []string
+	OIDCGroupsClaim       string
+	OIDCPostLoginRedirect string
+	// IdP不返回 email_verified 时是否信任其邮箱
+	OIDCTrustEmail bool
+
+	// LDAP/AD认证，LDAPURL为空时不启用
+	LDAPURL                string
+	LDAPStartTLS           bool
+	LDAPInsecureSkipVerify bool
+	LDAPCAFile             string
+	LDAPBindDN             string
+	LDAPBindPassword       string
+	LDAPBaseDN             string
+	LDAPUserFilter         string
+	LDAPEmailAttribute     string
+	LDAPSubjectAttribute   string
+	LDAPGroupAttribute     string
+	LDAPGroupBaseDN        string
+	LDAPGroupFilter        string
+
+	// 外部身份（SSO）用户加入的组织及角色映射，格式: group=role,group=role
+	SSOOrganization string
+	SSODefaultRole  string
+	SSOGroupRoleMap map[string]string
+
+	// 指定SSO用户加入的组织ID，为空时使用首次SSO登录时创建的组织
+	SSOOrganizationID string
 }
 
 func Load() *Config {
@@ -17,6 +110,73 @@ func Load() *Config {
 		JWTSecret:   getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-this-in-production"),
 		Environment: getEnv("ENVIRONMENT", "development"),
 		Port:        getEnv("PORT", "8080"),
+
+		TrustedProxies: getList("TRUSTED_PROXIES", ""),
+
+		LocalAuthEnabled: getEnv("LOCAL_AUTH_ENABLED", "true") == "true",
+
+		PrometheusConfigDir: getEnv("PROMETHEUS_CONFIG_DIR", "./prometheus"),
+
+		PrometheusURL:                getEnv("PROMETHEUS_URL", ""),
+		PrometheusUsername:           getEnv("PROMETHEUS_USERNAME", ""),
+		PrometheusPassword:           getEnv("PROMETHEUS_PASSWORD", ""),
+		PrometheusInsecureSkipVerify: getEnv("PROMETHEUS_INSECURE_SKIP_VERIFY", "false") == "true",
+		PrometheusCAFile:             getEnv("PROMETHEUS_CA_FILE", ""),
+		OperatorOrganizationID:       getEnv("OPERATOR_ORGANIZATION_ID", ""),
+		PrometheusQueryTimeout:       getDuration("PROMETHEUS_QUERY_TIMEOUT", 30*time.Second),
+		PrometheusQueryMaxRange:      getDuration("PROMETHEUS_QUERY_MAX_RANGE", 30*24*time.Hour),
+		PrometheusQueryMaxResults:    getInt("PROMETHEUS_QUERY_MAX_RESULTS", 1000),
+		PrometheusQueryMaxConcurrent: getInt("PROMETHEUS_QUERY_MAX_CONCURRENT", 4),
+
+		AlertmanagerURL:                getEnv("ALERTMANAGER_URL", ""),
+		AlertmanagerUsername:           getEnv("ALERTMANAGER_USERNAME", ""),
+		AlertmanagerPassword:           getEnv("ALERTMANAGER_PASSWORD", ""),
+		AlertmanagerInsecureSkipVerify: getEnv("ALERTMANAGER_INSECURE_SKIP_VERIFY", "false") == "true",
+
+		AppURL: getEnv("APP_URL", "http://localhost:5173"),
+
+		MailDriver:      getEnv("MAIL_DRIVER", "log"),
+		MailFrom:        getEnv("MAIL_FROM", "PromeConfig <noreply@localhost>"),
+		MailFileDir:     getEnv("MAIL_FILE_DIR", "./mail"),
+		SMTPHost:        getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:        getInt("SMTP_PORT", 587),
+		SMTPUsername:    getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:    getEnv("SMTP_PASSWORD", ""),
+		SMTPImplicitTLS: getEnv("SMTP_IMPLICIT_TLS", "false") == "true",
+
+		TOTPIssuer: getEnv("TOTP_ISSUER", "PromeConfig"),
+
+		SecretsKeys:             getMap("SECRETS_KEYS", ""),
+		SecretsActiveKeyVersion: getInt("SECRETS_ACTIVE_KEY_VERSION", 0),
+		SecretsInsecureDevKey:   getEnv("SECRETS_INSECURE_DEV_KEY", "false") == "true",
+
+		OIDCIssuer:            getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:          getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:      getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:       getEnv("OIDC_REDIRECT_URL", "http://localhost:8080/api/auth/oidc/callback"),
+		OIDCScopes:            getList("OIDC_SCOPES", "openid,email,profile"),
+		OIDCGroupsClaim:       getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCPostLoginRedirect: getEnv("OIDC_POST_LOGIN_REDIRECT", ""),
+		OIDCTrustEmail:        getEnv("OIDC_TRUST_EMAIL", "false") == "true",
+
+		LDAPURL:                getEnv("LDAP_URL", ""),
+		LDAPStartTLS:           getEnv("LDAP_START_TLS", "false") == "true",
+		LDAPInsecureSkipVerify: getEnv("LDAP_INSECURE_SKIP_VERIFY", "false") == "true",
+		LDAPCAFile:             getEnv("LDAP_CA_FILE", ""),
+		LDAPBindDN:             getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:       getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:             getEnv("LDAP_BASE_DN", ""),
+		LDAPUserFilter:         getEnv("LDAP_USER_FILTER", "(|(uid={username})(mail={username}))"),
+		LDAPEmailAttribute:     getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		LDAPSubjectAttribute:   getEnv("LDAP_SUBJECT_ATTRIBUTE", ""),
+		LDAPGroupAttribute:     getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		LDAPGroupBaseDN:        getEnv("LDAP_GROUP_BASE_DN", ""),
+		LDAPGroupFilter:        getEnv("LDAP_GROUP_FILTER", "(member={dn})"),
+
+		SSOOrganization:   getEnv("SSO_ORGANIZATION", "default"),
+		SSOOrganizationID: getEnv("SSO_ORGANIZATION_ID", ""),
+		SSODefaultRole:    getEnv("SSO_DEFAULT_ROLE", "viewer"),
+		SSOGroupRoleMap:   getMap("SSO_GROUP_ROLE_MAP", ""),
 	}
 }
 
@@ -25,4 +185,41 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// 解析时长，支持Prometheus的写法，例如 30s、1h30m、7d
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := model.ParseDuration(os.Getenv(key)); err == nil && value > 0 {
+		return time.Duration(value)
+	}
+	return defaultValue
+}
+
+// 解析逗号分隔的列表
+func getList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// 解析 key=value,key=value 形式的映射
+func getMap(key, defaultValue string) map[string]string {
+	m := make(map[string]string)
+	for _, item := range getList(key, defaultValue) {
+		if k, v, ok := strings.Cut(item, "="); ok {
+			m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return m
+}
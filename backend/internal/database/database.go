@@ -73,12 +73,379 @@ func Migrate(db *sql.DB) error {
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 		);`,
 
+		// Organizations表
+		`CREATE TABLE IF NOT EXISTS organizations (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			name TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// Organization Members表
+		`CREATE TABLE IF NOT EXISTS organization_members (
+			org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			role TEXT NOT NULL DEFAULT 'viewer' CHECK (role IN ('viewer', 'editor', 'approver', 'admin')),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (org_id, user_id)
+		);`,
+
+		// 配置归属到组织
+		`ALTER TABLE targets ADD COLUMN IF NOT EXISTS org_id UUID REFERENCES organizations(id) ON DELETE CASCADE;`,
+		`ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS org_id UUID REFERENCES organizations(id) ON DELETE CASCADE;`,
+
+		// 为尚未加入组织的已有用户创建个人组织（组织ID沿用用户ID）
+		`INSERT INTO organizations (id, name)
+		SELECT u.id, u.email FROM users u
+		WHERE NOT EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = u.id)
+		ON CONFLICT (id) DO NOTHING;`,
+
+		`INSERT INTO organization_members (org_id, user_id, role)
+		SELECT u.id, u.id, 'admin' FROM users u
+		WHERE NOT EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = u.id)
+		  AND EXISTS (SELECT 1 FROM organizations o WHERE o.id = u.id);`,
+
+		`UPDATE targets t SET org_id = t.user_id
+		WHERE t.org_id IS NULL AND EXISTS (SELECT 1 FROM organizations o WHERE o.id = t.user_id);`,
+
+		`UPDATE alert_rules r SET org_id = r.user_id
+		WHERE r.org_id IS NULL AND EXISTS (SELECT 1 FROM organizations o WHERE o.id = r.user_id);`,
+
+		// SSO用户加入的组织用标记查找，不按可被修改的名称查找
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS sso BOOLEAN NOT NULL DEFAULT FALSE;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_organizations_sso ON organizations(sso) WHERE sso;`,
+
+		// 外部身份（SSO）用户没有本地密码
+		`ALTER TABLE users ALTER COLUMN password_hash DROP NOT NULL;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS external_provider TEXT;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS external_subject TEXT;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_external_identity ON users(external_provider, external_subject);`,
+
+		// TOTP两步验证
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;`,
+
+		// 两步验证恢复码表
+		`CREATE TABLE IF NOT EXISTS user_recovery_codes (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			code_hash TEXT NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// 邮箱验证，已有用户视为已验证，新用户默认未验证
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS verified BOOLEAN NOT NULL DEFAULT TRUE;`,
+		`ALTER TABLE users ALTER COLUMN verified SET DEFAULT FALSE;`,
+
+		// 邮箱验证和密码重置的一次性token表，只保存token的哈希
+		`CREATE TABLE IF NOT EXISTS user_tokens (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			purpose TEXT NOT NULL CHECK (purpose IN ('verify_email', 'reset_password')),
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// 两步验证的challenge token同样是一次性token；连续输错验证码后锁定一段时间
+		`ALTER TABLE user_tokens DROP CONSTRAINT IF EXISTS user_tokens_purpose_check;
+		ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+			CHECK (purpose IN ('verify_email', 'reset_password', 'mfa_challenge'));`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_failed_attempts INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_locked_until TIMESTAMP WITH TIME ZONE;`,
+
+		// totp_secret 加密保存，totp_secret_version 为加密使用的主密钥版本（0为迁移前的明文，启动时加密）
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret_version INTEGER NOT NULL DEFAULT 0;`,
+		// challenge token 记录第一步登录的方式，验证码通过后按该方式记录登录
+		`ALTER TABLE user_tokens ADD COLUMN IF NOT EXISTS provider TEXT NOT NULL DEFAULT '';`,
+
+		// Config Revisions表，每次同步后写入，不再修改
+		`CREATE TABLE IF NOT EXISTS config_revisions (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			message TEXT NOT NULL DEFAULT '',
+			prometheus_config TEXT NOT NULL,
+			alert_rules_config TEXT NOT NULL,
+			snapshot JSONB NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// Audit Log表，不设外键，用户或组织删除后日志仍保留
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			org_id UUID,
+			actor_id UUID,
+			actor_email TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT '',
+			action TEXT NOT NULL,
+			entity_type TEXT NOT NULL,
+			entity_id TEXT NOT NULL DEFAULT '',
+			before JSONB,
+			after JSONB,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// 开启后必须通过审批后的变更集修改配置
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS require_approval BOOLEAN NOT NULL DEFAULT FALSE;`,
+
+		// Change Sets表
+		`CREATE TABLE IF NOT EXISTS change_sets (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'submitted', 'approved', 'applied', 'rejected')),
+			reviewer_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			reviewed_at TIMESTAMP WITH TIME ZONE,
+			applied_revision_id UUID REFERENCES config_revisions(id) ON DELETE SET NULL,
+			applied_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS change_set_items (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			change_set_id UUID NOT NULL REFERENCES change_sets(id) ON DELETE CASCADE,
+			entity_type TEXT NOT NULL CHECK (entity_type IN ('target', 'alert_rule')),
+			action TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+			entity_id UUID,
+			payload JSONB,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS change_set_comments (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			change_set_id UUID NOT NULL REFERENCES change_sets(id) ON DELETE CASCADE,
+			author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			body TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// 告警规则单元测试表，rule_id 不设外键，回滚会重新插入规则
+		`CREATE TABLE IF NOT EXISTS alert_rule_tests (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			rule_id UUID,
+			group_name TEXT NOT NULL DEFAULT '',
+			name TEXT NOT NULL,
+			spec JSONB NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// Prometheus实例表，每个组织可以有多个Prometheus服务器
+		`CREATE TABLE IF NOT EXISTS prometheus_instances (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			username TEXT NOT NULL DEFAULT '',
+			password TEXT NOT NULL DEFAULT '',
+			bearer_token TEXT NOT NULL DEFAULT '',
+			insecure_skip_verify BOOLEAN NOT NULL DEFAULT FALSE,
+			ca_cert TEXT NOT NULL DEFAULT '',
+			output_dir TEXT NOT NULL,
+			reload_mode TEXT NOT NULL DEFAULT 'http' CHECK (reload_mode IN ('http', 'none')),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (org_id, name),
+			UNIQUE (org_id, output_dir)
+		);`,
+
+		// targets和规则组分配到的实例，为空表示全部实例
+		`ALTER TABLE targets ADD COLUMN IF NOT EXISTS instance_ids JSONB NOT NULL DEFAULT '[]';`,
+		`ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS group_name TEXT NOT NULL DEFAULT 'default';`,
+
+		`CREATE TABLE IF NOT EXISTS rule_groups (
+			org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			instance_ids JSONB NOT NULL DEFAULT '[]',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (org_id, name)
+		);`,
+
+		// 实例的全局配置，instance_id 为空时属于默认实例
+		`CREATE TABLE IF NOT EXISTS prometheus_global_configs (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			instance_id UUID REFERENCES prometheus_instances(id) ON DELETE CASCADE,
+			scrape_interval TEXT NOT NULL DEFAULT '',
+			scrape_timeout TEXT NOT NULL DEFAULT '',
+			evaluation_interval TEXT NOT NULL DEFAULT '',
+			external_labels JSONB NOT NULL DEFAULT '{}',
+			query_log_file TEXT NOT NULL DEFAULT '',
+			rule_files JSONB NOT NULL DEFAULT '[]',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// remote_write 和 remote_read，instance_ids 为空表示全部实例
+		`CREATE TABLE IF NOT EXISTS remote_storages (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			kind TEXT NOT NULL CHECK (kind IN ('write', 'read')),
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			instance_ids JSONB NOT NULL DEFAULT '[]',
+			remote_timeout TEXT NOT NULL DEFAULT '',
+			headers JSONB NOT NULL DEFAULT '{}',
+			username TEXT NOT NULL DEFAULT '',
+			password TEXT NOT NULL DEFAULT '',
+			bearer_token TEXT NOT NULL DEFAULT '',
+			tls_ca_cert TEXT NOT NULL DEFAULT '',
+			tls_cert TEXT NOT NULL DEFAULT '',
+			tls_key TEXT NOT NULL DEFAULT '',
+			tls_server_name TEXT NOT NULL DEFAULT '',
+			insecure_skip_verify BOOLEAN NOT NULL DEFAULT FALSE,
+			write_relabel_configs JSONB,
+			queue_config JSONB,
+			metadata_config JSONB,
+			read_recent BOOLEAN NOT NULL DEFAULT FALSE,
+			required_matchers JSONB NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (org_id, kind, name)
+		);`,
+
+		// Prometheus发送告警的Alertmanager，instance_ids 为空表示全部实例
+		`CREATE TABLE IF NOT EXISTS alertmanagers (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			instance_ids JSONB NOT NULL DEFAULT '[]',
+			scheme TEXT NOT NULL DEFAULT '',
+			path_prefix TEXT NOT NULL DEFAULT '',
+			api_version TEXT NOT NULL DEFAULT '',
+			timeout TEXT NOT NULL DEFAULT '',
+			static_targets JSONB NOT NULL DEFAULT '[]',
+			sd_configs JSONB,
+			relabel_configs JSONB,
+			username TEXT NOT NULL DEFAULT '',
+			password TEXT NOT NULL DEFAULT '',
+			bearer_token TEXT NOT NULL DEFAULT '',
+			tls_ca_cert TEXT NOT NULL DEFAULT '',
+			tls_cert TEXT NOT NULL DEFAULT '',
+			tls_key TEXT NOT NULL DEFAULT '',
+			tls_server_name TEXT NOT NULL DEFAULT '',
+			insecure_skip_verify BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (org_id, name)
+		);`,
+		`ALTER TABLE prometheus_global_configs ADD COLUMN IF NOT EXISTS alert_relabel_configs JSONB;`,
+
+		// Alertmanager连接设置，没有记录时使用环境变量中的默认值
+		`CREATE TABLE IF NOT EXISTS alertmanager_settings (
+			org_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+			url TEXT NOT NULL DEFAULT '',
+			username TEXT NOT NULL DEFAULT '',
+			password TEXT NOT NULL DEFAULT '',
+			bearer_token TEXT NOT NULL DEFAULT '',
+			insecure_skip_verify BOOLEAN NOT NULL DEFAULT FALSE,
+			ca_cert TEXT NOT NULL DEFAULT '',
+			output_dir TEXT NOT NULL DEFAULT 'alertmanager',
+			reload_mode TEXT NOT NULL DEFAULT 'http' CHECK (reload_mode IN ('http', 'none')),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// alertmanager.yml 的 global、路由树、抑制规则和时间段，每个组织一份
+		`CREATE TABLE IF NOT EXISTS alertmanager_configs (
+			org_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+			global JSONB NOT NULL DEFAULT '{}',
+			global_secrets JSONB NOT NULL DEFAULT '{}',
+			route JSONB NOT NULL DEFAULT '{}',
+			inhibit_rules JSONB NOT NULL DEFAULT '[]',
+			time_intervals JSONB NOT NULL DEFAULT '[]',
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// Alertmanager通知接收者，各集成的密钥单独保存在 secrets 中
+		`CREATE TABLE IF NOT EXISTS alertmanager_receivers (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			email_configs JSONB NOT NULL DEFAULT '[]',
+			webhook_configs JSONB NOT NULL DEFAULT '[]',
+			slack_configs JSONB NOT NULL DEFAULT '[]',
+			pagerduty_configs JSONB NOT NULL DEFAULT '[]',
+			opsgenie_configs JSONB NOT NULL DEFAULT '[]',
+			secrets JSONB NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (org_id, name)
+		);`,
+
+		// 每次同步写入的 alertmanager.yml，不含密钥
+		`CREATE TABLE IF NOT EXISTS alertmanager_revisions (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			message TEXT NOT NULL DEFAULT '',
+			config TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// 告警规则检查设置，每个组织一份
+		`CREATE TABLE IF NOT EXISTS rule_lint_settings (
+			org_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+			strict BOOLEAN NOT NULL DEFAULT FALSE,
+			allowed_severities JSONB NOT NULL DEFAULT '[]',
+			required_annotations JSONB NOT NULL DEFAULT '[]',
+			check_for BOOLEAN NOT NULL DEFAULT TRUE,
+			rate_window_scrapes INTEGER NOT NULL DEFAULT 4,
+			check_aggregations BOOLEAN NOT NULL DEFAULT TRUE,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+
+		// Azure OpenAI 的 api-version
+		`ALTER TABLE ai_settings ADD COLUMN IF NOT EXISTS api_version TEXT NOT NULL DEFAULT '';`,
+
+		// api_key 加密保存，api_key_version 为加密使用的主密钥版本（0为迁移前的明文，启动时加密），api_key_hint 为返回给前端的提示
+		`ALTER TABLE ai_settings ADD COLUMN IF NOT EXISTS api_key_version INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE ai_settings ADD COLUMN IF NOT EXISTS api_key_hint TEXT;`,
+
+		// revision属于某个实例，为空时属于默认实例
+		`ALTER TABLE config_revisions ADD COLUMN IF NOT EXISTS instance_id UUID REFERENCES prometheus_instances(id) ON DELETE SET NULL;`,
+
+		// 同步时实例的名称，默认实例为空；删除实例时保留revision历史，instance_id 置空后按名称区分
+		`ALTER TABLE config_revisions ADD COLUMN IF NOT EXISTS instance_name TEXT NOT NULL DEFAULT '';`,
+		`UPDATE config_revisions r SET instance_name = i.name
+			FROM prometheus_instances i WHERE r.instance_id = i.id AND r.instance_name = '';`,
+		`ALTER TABLE config_revisions DROP CONSTRAINT IF EXISTS config_revisions_instance_id_fkey;
+		ALTER TABLE config_revisions ADD CONSTRAINT config_revisions_instance_id_fkey
+			FOREIGN KEY (instance_id) REFERENCES prometheus_instances(id) ON DELETE SET NULL;`,
+
 		// 创建索引
+		`CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_targets_org_id ON targets(org_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_alert_rules_org_id ON alert_rules(org_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_targets_user_id ON targets(user_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_targets_job_name ON targets(job_name);`,
 		`CREATE INDEX IF NOT EXISTS idx_alert_rules_user_id ON alert_rules(user_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_alert_rules_alert_name ON alert_rules(alert_name);`,
 		`CREATE INDEX IF NOT EXISTS idx_ai_settings_user_id ON ai_settings(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user_id ON user_recovery_codes(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_config_revisions_org_id ON config_revisions(org_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_org_id ON audit_log(org_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log(actor_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_change_sets_org_id ON change_sets(org_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_change_set_items_change_set_id ON change_set_items(change_set_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_change_set_comments_change_set_id ON change_set_comments(change_set_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_alert_rule_tests_org_id ON alert_rule_tests(org_id, rule_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_config_revisions_instance_id ON config_revisions(instance_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_alertmanager_revisions_org_id ON alertmanager_revisions(org_id, created_at DESC);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_prometheus_global_configs_instance
+			ON prometheus_global_configs(org_id, COALESCE(instance_id, '00000000-0000-0000-0000-000000000000'));`,
 
 		// 创建更新时间触发器函数
 		`CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -89,6 +456,17 @@ func Migrate(db *sql.DB) error {
 		END;
 		$$ language 'plpgsql';`,
 
+		// 审计日志只允许追加
+		`CREATE OR REPLACE FUNCTION prevent_audit_log_modification()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_log is append-only';
+		END;
+		$$ language 'plpgsql';`,
+
+		`DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+		CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log FOR EACH ROW EXECUTE FUNCTION prevent_audit_log_modification();`,
+
 		// 为各表创建更新时间触发器
 		`DROP TRIGGER IF EXISTS update_users_updated_at ON users;
 		CREATE TRIGGER update_users_updated_at BEFORE UPDATE ON users FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
@@ -101,6 +479,39 @@ func Migrate(db *sql.DB) error {
 
 		`DROP TRIGGER IF EXISTS update_ai_settings_updated_at ON ai_settings;
 		CREATE TRIGGER update_ai_settings_updated_at BEFORE UPDATE ON ai_settings FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+
+		`DROP TRIGGER IF EXISTS update_organizations_updated_at ON organizations;
+		CREATE TRIGGER update_organizations_updated_at BEFORE UPDATE ON organizations FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+
+		`DROP TRIGGER IF EXISTS update_organization_members_updated_at ON organization_members;
+		CREATE TRIGGER update_organization_members_updated_at BEFORE UPDATE ON organization_members FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+
+		`DROP TRIGGER IF EXISTS update_change_sets_updated_at ON change_sets;
+		CREATE TRIGGER update_change_sets_updated_at BEFORE UPDATE ON change_sets FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+
+		`DROP TRIGGER IF EXISTS update_alert_rule_tests_updated_at ON alert_rule_tests;
+		CREATE TRIGGER update_alert_rule_tests_updated_at BEFORE UPDATE ON alert_rule_tests FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+
+		`DROP TRIGGER IF EXISTS update_prometheus_instances_updated_at ON prometheus_instances;
+		CREATE TRIGGER update_prometheus_instances_updated_at BEFORE UPDATE ON prometheus_instances FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+
+		`DROP TRIGGER IF EXISTS update_rule_groups_updated_at ON rule_groups;
+		CREATE TRIGGER update_rule_groups_updated_at BEFORE UPDATE ON rule_groups FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+
+		`DROP TRIGGER IF EXISTS update_prometheus_global_configs_updated_at ON prometheus_global_configs;
+		CREATE TRIGGER update_prometheus_global_configs_updated_at BEFORE UPDATE ON prometheus_global_configs FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+		`DROP TRIGGER IF EXISTS update_remote_storages_updated_at ON remote_storages;
+		CREATE TRIGGER update_remote_storages_updated_at BEFORE UPDATE ON remote_storages FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+		`DROP TRIGGER IF EXISTS update_alertmanagers_updated_at ON alertmanagers;
+		CREATE TRIGGER update_alertmanagers_updated_at BEFORE UPDATE ON alertmanagers FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+		`DROP TRIGGER IF EXISTS update_alertmanager_settings_updated_at ON alertmanager_settings;
+		CREATE TRIGGER update_alertmanager_settings_updated_at BEFORE UPDATE ON alertmanager_settings FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+		`DROP TRIGGER IF EXISTS update_alertmanager_configs_updated_at ON alertmanager_configs;
+		CREATE TRIGGER update_alertmanager_configs_updated_at BEFORE UPDATE ON alertmanager_configs FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+		`DROP TRIGGER IF EXISTS update_alertmanager_receivers_updated_at ON alertmanager_receivers;
+		CREATE TRIGGER update_alertmanager_receivers_updated_at BEFORE UPDATE ON alertmanager_receivers FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
+		`DROP TRIGGER IF EXISTS update_rule_lint_settings_updated_at ON rule_lint_settings;
+		CREATE TRIGGER update_rule_lint_settings_updated_at BEFORE UPDATE ON rule_lint_settings FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();`,
 	}
 
 	for _, migration := range migrations {
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/secrets"
+)
+
+// MigrateSecrets 把明文保存（加密前的数据，版本为0）或用旧版本主密钥加密的AI API Key和TOTP密钥用当前主密钥重新加密
+func MigrateSecrets(db *sql.DB, keyring *secrets.Keyring) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	apiKeys, err := migrateAIAPIKeys(tx, keyring)
+	if err != nil {
+		return err
+	}
+	totpSecrets, err := migrateTOTPSecrets(tx, keyring)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if apiKeys > 0 {
+		log.Printf("Re-encrypted %d AI API keys with secrets key version %d", apiKeys, keyring.ActiveVersion())
+	}
+	if totpSecrets > 0 {
+		log.Printf("Re-encrypted %d TOTP secrets with secrets key version %d", totpSecrets, keyring.ActiveVersion())
+	}
+	return nil
+}
+
+// 待重新加密的值
+type staleSecret struct {
+	userID  uuid.UUID
+	value   string
+	version int
+}
+
+// 查询待重新加密的值，query 返回用户ID、值和版本
+func staleSecrets(tx *sql.Tx, query string, activeVersion int) ([]staleSecret, error) {
+	rows, err := tx.Query(query, activeVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stale []staleSecret
+	for rows.Next() {
+		var s staleSecret
+		if err := rows.Scan(&s.userID, &s.value, &s.version); err != nil {
+			return nil, err
+		}
+		stale = append(stale, s)
+	}
+	return stale, rows.Err()
+}
+
+// 解密旧的值，版本为0时为明文
+func (s staleSecret) plaintext(keyring *secrets.Keyring, aad []byte) (string, error) {
+	if s.version == 0 {
+		return s.value, nil
+	}
+	return keyring.Decrypt(s.value, aad)
+}
+
+func migrateAIAPIKeys(tx *sql.Tx, keyring *secrets.Keyring) (int, error) {
+	stale, err := staleSecrets(tx, `
+		SELECT user_id, api_key, api_key_version FROM ai_settings
+		WHERE api_key IS NOT NULL AND api_key_version <> $1 FOR UPDATE`, keyring.ActiveVersion())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range stale {
+		aad := secrets.AIAPIKeyAAD(key.userID)
+		plaintext, err := key.plaintext(keyring, aad)
+		if err != nil {
+			return 0, fmt.Errorf("decrypt AI API key of user %s: %w", key.userID, err)
+		}
+
+		// 空的Key直接清除
+		if plaintext == "" {
+			if _, err := tx.Exec(`
+				UPDATE ai_settings SET api_key = NULL, api_key_version = 0, api_key_hint = NULL WHERE user_id = $1`,
+				key.userID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		ciphertext, err := keyring.Encrypt(plaintext, aad)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`
+			UPDATE ai_settings SET api_key = $1, api_key_version = $2, api_key_hint = $3 WHERE user_id = $4`,
+			ciphertext, keyring.ActiveVersion(), secrets.Mask(plaintext), key.userID); err != nil {
+			return 0, err
+		}
+	}
+	return len(stale), nil
+}
+
+func migrateTOTPSecrets(tx *sql.Tx, keyring *secrets.Keyring) (int, error) {
+	stale, err := staleSecrets(tx, `
+		SELECT id, totp_secret, totp_secret_version FROM users
+		WHERE totp_secret IS NOT NULL AND totp_secret_version <> $1 FOR UPDATE`, keyring.ActiveVersion())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, secret := range stale {
+		aad := secrets.TOTPSecretAAD(secret.userID)
+		plaintext, err := secret.plaintext(keyring, aad)
+		if err != nil {
+			return 0, fmt.Errorf("decrypt TOTP secret of user %s: %w", secret.userID, err)
+		}
+
+		ciphertext, err := keyring.Encrypt(plaintext, aad)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`
+			UPDATE users SET totp_secret = $1, totp_secret_version = $2 WHERE id = $3`,
+			ciphertext, keyring.ActiveVersion(), secret.userID); err != nil {
+			return 0, err
+		}
+	}
+	return len(stale), nil
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"promeconfig-backend/internal/auth"
+	"promeconfig-backend/internal/config"
+	"promeconfig-backend/internal/mail"
+	"promeconfig-backend/internal/models"
+)
+
+const (
+	tokenPurposeVerifyEmail   = "verify_email"
+	tokenPurposeResetPassword = "reset_password"
+	tokenPurposeMFAChallenge  = "mfa_challenge"
+
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+	mfaChallengeTTL  = 5 * time.Minute
+)
+
+// 根据配置创建邮件发送方式
+func newMailSender(cfg *config.Config) (mail.Sender, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return mail.NewSMTPSender(mail.SMTPConfig{
+			Host:        cfg.SMTPHost,
+			Port:        cfg.SMTPPort,
+			Username:    cfg.SMTPUsername,
+			Password:    cfg.SMTPPassword,
+			From:        cfg.MailFrom,
+			ImplicitTLS: cfg.SMTPImplicitTLS,
+		}), nil
+	case "file":
+		return mail.FileSender{Dir: cfg.MailFileDir, From: cfg.MailFrom}, nil
+	case "log", "":
+		return mail.LogSender{}, nil
+	}
+	return nil, fmt.Errorf("unknown mail driver: %s", cfg.MailDriver)
+}
+
+// 签发一次性token，同一用途下未使用的旧token立即失效，provider 为两步验证challenge第一步登录的方式
+func (h *Handlers) issueUserToken(userID uuid.UUID, purpose, provider string, ttl time.Duration) (string, error) {
+	token, hash, err := auth.GenerateOneTimeToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE user_tokens SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`, userID, purpose); err != nil {
+		return "", err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at, provider)
+		VALUES ($1, $2, $3, $4, $5)`, userID, purpose, hash, time.Now().Add(ttl), provider); err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// 使用一次性token，token无效、过期或已使用时返回 sql.ErrNoRows
+func consumeUserToken(tx *sql.Tx, token, purpose string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := tx.QueryRow(`
+		UPDATE user_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`, auth.HashOneTimeToken(token), purpose).Scan(&userID)
+	return userID, err
+}
+
+func (h *Handlers) sendVerificationEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := h.issueUserToken(userID, tokenPurposeVerifyEmail, "", verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	link := h.cfg.AppURL + "/verify-email?token=" + url.QueryEscape(token)
+	return h.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Verify your PromeConfig account",
+		Body: fmt.Sprintf("Please verify your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in 24 hours. If you did not sign up, ignore this email.\n", link),
+	})
+}
+
+func (h *Handlers) sendPasswordResetEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := h.issueUserToken(userID, tokenPurposeResetPassword, "", resetPasswordTTL)
+	if err != nil {
+		return err
+	}
+
+	link := h.cfg.AppURL + "/reset-password?token=" + url.QueryEscape(token)
+	return h.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Reset your PromeConfig password",
+		Body: fmt.Sprintf("A password reset was requested for your account. Open the link below to choose a new password:\n\n%s\n\n"+
+			"The link expires in 1 hour. If you did not request this, ignore this email.\n", link),
+	})
+}
+
+// 验证邮箱
+func (h *Handlers) VerifyEmail(c *gin.Context) {
+	var req models.TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeUserToken(tx, req.Token, tokenPurposeVerifyEmail)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE users SET verified = TRUE WHERE id = $1", userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// 重新发送验证邮件，无论邮箱是否存在都返回相同结果
+func (h *Handlers) ResendVerification(c *gin.Context) {
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var userID uuid.UUID
+	err := h.db.QueryRow(`
+		SELECT id FROM users WHERE email = $1 AND verified = FALSE`, req.Email).Scan(&userID)
+
+	if err == nil {
+		if err := h.sendVerificationEmail(c.Request.Context(), userID, req.Email); err != nil {
+			log.Printf("Failed to send verification email: %v", err)
+		}
+	} else if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists and is unverified, a verification email has been sent"})
+}
+
+// 申请重置密码，无论邮箱是否存在都返回相同结果
+func (h *Handlers) ForgotPassword(c *gin.Context) {
+	if !h.cfg.LocalAuthEnabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Local authentication is disabled"})
+		return
+	}
+
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 只有本地账号可以重置密码，SSO账号的密码由身份提供方管理
+	var userID uuid.UUID
+	err := h.db.QueryRow(`
+		SELECT id FROM users WHERE email = $1 AND password_hash IS NOT NULL`, req.Email).Scan(&userID)
+
+	if err == nil {
+		if err := h.sendPasswordResetEmail(c.Request.Context(), userID, req.Email); err != nil {
+			log.Printf("Failed to send password reset email: %v", err)
+		}
+	} else if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists, a password reset email has been sent"})
+}
+
+// 使用重置token设置新密码
+func (h *Handlers) ResetPassword(c *gin.Context) {
+	if !h.cfg.LocalAuthEnabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Local authentication is disabled"})
+		return
+	}
+
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeUserToken(tx, req.Token, tokenPurposeResetPassword)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// 能收到重置邮件说明邮箱有效，同时视为已验证
+	if _, err := tx.Exec(`
+		UPDATE users SET password_hash = $1, verified = TRUE WHERE id = $2`,
+		string(hashedPassword), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/ai"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+const (
+	// 生成的规则未通过校验时，把错误交给模型修正的最多次数（含第一次）
+	maxGenerateAttempts = 3
+	aiRequestTimeout    = 60 * time.Second
+)
+
+var errAIRequestFailed = errors.New("AI request failed")
+
+const generateRuleSystemPrompt = `你是一个Prometheus告警规则专家。根据用户的描述，生成合适的Prometheus告警规则。
+
+只返回一个JSON对象，不要包含其他文字，字段如下：
+- alert_name: 告警名称（英文，驼峰命名）
+- expr: PromQL表达式
+- for: 持续时间，如 5m
+- severity: 严重程度%s
+- summary: 简短描述
+- description: 详细描述（可以包含模板变量如 {{ $labels.instance }}、{{ $value | humanize }}）
+- runbook_url: 处理手册链接，没有时为空字符串
+
+示例：
+{
+  "alert_name": "HighCPUUsage",
+  "expr": "100 - (avg by(instance) (rate(node_cpu_seconds_total{mode=\"idle\"}[5m])) * 100) > 80",
+  "for": "10m",
+  "severity": "warning",
+  "summary": "CPU使用率过高",
+  "description": "实例 {{ $labels.instance }} 的CPU使用率超过80%%，当前值：{{ $value | humanize }}%%",
+  "runbook_url": ""
+}`
+
+// 模型返回的规则
+type generatedRule struct {
+	AlertName   string `json:"alert_name"`
+	Expr        string `json:"expr"`
+	For         string `json:"for"`
+	Severity    string `json:"severity"`
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	RunbookURL  string `json:"runbook_url"`
+}
+
+// 解析模型返回的JSON，没有JSON模式的提供商可能在对象前后带有代码块或其他文字
+func parseGeneratedRule(content string) (*generatedRule, error) {
+	if start, end := strings.Index(content, "{"), strings.LastIndex(content, "}"); start >= 0 && end > start {
+		content = content[start : end+1]
+	}
+
+	var rule generatedRule
+	if err := json.Unmarshal([]byte(content), &rule); err != nil {
+		return nil, fmt.Errorf("response is not a JSON object: %v", err)
+	}
+	return &rule, nil
+}
+
+func (g *generatedRule) request(groupName string) (models.CreateAlertRuleRequest, error) {
+	ruleLabels := map[string]string{}
+	if g.Severity != "" {
+		ruleLabels["severity"] = g.Severity
+	}
+	annotations := map[string]string{}
+	for name, value := range map[string]string{"summary": g.Summary, "description": g.Description, "runbook_url": g.RunbookURL} {
+		if value != "" {
+			annotations[name] = value
+		}
+	}
+
+	req := models.CreateAlertRuleRequest{
+		AlertName:   g.AlertName,
+		GroupName:   groupName,
+		Expr:        g.Expr,
+		ForDuration: g.For,
+	}
+	var err error
+	if req.Labels, err = json.Marshal(ruleLabels); err != nil {
+		return req, err
+	}
+	req.Annotations, err = json.Marshal(annotations)
+	return req, err
+}
+
+// 用规则文件的检查（与 promtool check rules 相同）校验生成的规则，严格模式下检查规则发现的问题也视为错误
+func (h *Handlers) validateGeneratedRule(orgID uuid.UUID, req models.CreateAlertRuleRequest) ([]models.LintIssue, error) {
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return nil, err
+	}
+
+	content, err := prometheus.RenderRules([]models.AlertRule{{
+		AlertName:   req.AlertName,
+		GroupName:   req.GroupName,
+		Expr:        req.Expr,
+		ForDuration: req.ForDuration,
+		Labels:      req.Labels,
+		Annotations: req.Annotations,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if errs := prometheus.CheckRules(prometheus.RulesFileName, content); len(errs) > 0 {
+		messages := make([]string, 0, len(errs))
+		for _, e := range errs {
+			messages = append(messages, e.Message)
+		}
+		return nil, errors.New(strings.Join(messages, "; "))
+	}
+
+	issues, strict, err := h.runRuleLint(h.db, orgID, req)
+	if err != nil {
+		return nil, err
+	}
+	if strict && len(issues) > 0 {
+		messages := make([]string, 0, len(issues))
+		for _, issue := range issues {
+			messages = append(messages, issue.Message)
+		}
+		return nil, errors.New(strings.Join(messages, "; "))
+	}
+	return issues, nil
+}
+
+// 根据描述生成告警规则：在服务端用用户的AI设置调用模型，校验失败时把错误交给模型修正
+func (h *Handlers) GenerateAlertRule(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.GenerateRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.GroupName == "" {
+		req.GroupName = prometheus.DefaultRuleGroup
+	}
+
+	settings, err := getAISettings(h.db, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "AI settings not configured"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI settings"})
+		return
+	}
+	cfg, err := h.settingsConfig(settings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt AI API key"})
+		return
+	}
+	provider, err := ai.New(cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid AI settings: " + err.Error()})
+		return
+	}
+
+	lintSettings, err := getRuleLintSettings(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rule lint settings"})
+		return
+	}
+	severities := ""
+	if len(lintSettings.AllowedSeverities) > 0 {
+		severities = "，只能是 " + strings.Join(lintSettings.AllowedSeverities, "、") + " 之一"
+	}
+
+	messages := []ai.Message{
+		{Role: "system", Content: fmt.Sprintf(generateRuleSystemPrompt, severities)},
+		{Role: "user", Content: req.Prompt},
+	}
+
+	result, err := generateRule(c.Request.Context(), provider, messages, req.GroupName, func(rule models.CreateAlertRuleRequest) ([]models.LintIssue, error) {
+		return h.validateGeneratedRule(orgID, rule)
+	})
+	if errors.Is(err, errAIRequestFailed) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":    "Generated rule failed validation: " + err.Error(),
+			"attempts": maxGenerateAttempts,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// 调用模型生成规则，校验失败时把错误交给模型修正，最多尝试 maxGenerateAttempts 次
+// 模型调用失败时返回包装了 errAIRequestFailed 的错误，否则返回最后一次校验的错误
+func generateRule(ctx context.Context, provider ai.Provider, messages []ai.Message, groupName string,
+	validate func(models.CreateAlertRuleRequest) ([]models.LintIssue, error)) (*models.GeneratedAlertRule, error) {
+	var lastErr error
+	for attempt := 1; attempt <= maxGenerateAttempts; attempt++ {
+		reqCtx, cancel := context.WithTimeout(ctx, aiRequestTimeout)
+		content, err := provider.Complete(reqCtx, ai.Request{Messages: messages, JSON: true})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errAIRequestFailed, err)
+		}
+		messages = append(messages, ai.Message{Role: "assistant", Content: content})
+
+		var rule models.CreateAlertRuleRequest
+		var warnings []models.LintIssue
+		generated, err := parseGeneratedRule(content)
+		if err == nil {
+			rule, err = generated.request(groupName)
+		}
+		if err == nil {
+			warnings, err = validate(rule)
+		}
+		if err == nil {
+			return &models.GeneratedAlertRule{CreateAlertRuleRequest: rule, Attempts: attempt, Warnings: warnings}, nil
+		}
+
+		lastErr = err
+		messages = append(messages, ai.Message{
+			Role:    "user",
+			Content: "生成的规则未通过校验：" + err.Error() + "\n请修正后重新返回完整的JSON对象。",
+		})
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"promeconfig-backend/internal/ai"
+	"promeconfig-backend/internal/models"
+)
+
+func TestParseGeneratedRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *generatedRule
+	}{
+		{
+			name:    "plain JSON",
+			content: `{"alert_name": "HighCPU", "expr": "cpu > 80", "for": "10m", "severity": "warning"}`,
+			want:    &generatedRule{AlertName: "HighCPU", Expr: "cpu > 80", For: "10m", Severity: "warning"},
+		},
+		{
+			name:    "code block",
+			content: "```json\n{\"alert_name\": \"HighCPU\", \"expr\": \"cpu > 80\"}\n```",
+			want:    &generatedRule{AlertName: "HighCPU", Expr: "cpu > 80"},
+		},
+		{
+			name:    "surrounding text",
+			content: "好的，规则如下：\n{\"alert_name\": \"HighCPU\", \"summary\": \"{{ $labels.instance }} CPU过高\"}\n希望有帮助。",
+			want:    &generatedRule{AlertName: "HighCPU", Summary: "{{ $labels.instance }} CPU过高"},
+		},
+		{
+			name:    "no object",
+			content: "I cannot help with that.",
+		},
+		{
+			name:    "truncated object",
+			content: `{"alert_name": "HighCPU", "expr": "cpu > 80"`,
+		},
+		{
+			name:    "not an object",
+			content: `["HighCPU"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGeneratedRule(tt.content)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratedRuleRequest(t *testing.T) {
+	rule := generatedRule{
+		AlertName:   "HighCPU",
+		Expr:        "cpu > 80",
+		For:         "10m",
+		Severity:    "critical",
+		Summary:     "CPU过高",
+		Description: "",
+		RunbookURL:  "https://runbooks.example.com/cpu",
+	}
+
+	req, err := rule.request("node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.AlertName != "HighCPU" || req.GroupName != "node" || req.Expr != "cpu > 80" || req.ForDuration != "10m" {
+		t.Errorf("unexpected request %+v", req)
+	}
+
+	var ruleLabels, annotations map[string]string
+	if err := json.Unmarshal(req.Labels, &ruleLabels); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(req.Annotations, &annotations); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"severity": "critical"}; !reflect.DeepEqual(ruleLabels, want) {
+		t.Errorf("labels = %v, want %v", ruleLabels, want)
+	}
+	// 空的注解不写入
+	if want := map[string]string{"summary": "CPU过高", "runbook_url": "https://runbooks.example.com/cpu"}; !reflect.DeepEqual(annotations, want) {
+		t.Errorf("annotations = %v, want %v", annotations, want)
+	}
+
+	empty, err := (&generatedRule{AlertName: "X", Expr: "up == 0"}).request("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(empty.Labels) != "{}" || string(empty.Annotations) != "{}" {
+		t.Errorf("labels = %s, annotations = %s, want empty objects", empty.Labels, empty.Annotations)
+	}
+}
+
+// 按顺序返回预设回复的提供商，记录每次收到的消息
+type scriptedProvider struct {
+	replies  []string
+	err      error
+	requests [][]ai.Message
+}
+
+func (p *scriptedProvider) Complete(ctx context.Context, req ai.Request) (string, error) {
+	p.requests = append(p.requests, req.Messages)
+	if p.err != nil {
+		return "", p.err
+	}
+	reply := p.replies[0]
+	p.replies = p.replies[1:]
+	return reply, nil
+}
+
+func TestGenerateRule(t *testing.T) {
+	valid := `{"alert_name": "HighCPU", "expr": "cpu > 80", "for": "10m"}`
+	invalid := `{"alert_name": "HighCPU", "expr": "cpu >", "for": "10m"}`
+
+	// 表达式不完整时校验失败
+	validate := func(rule models.CreateAlertRuleRequest) ([]models.LintIssue, error) {
+		if strings.HasSuffix(rule.Expr, ">") {
+			return nil, errors.New("parse error: unexpected end of input")
+		}
+		return []models.LintIssue{{Check: "runbook", Message: "missing runbook_url"}}, nil
+	}
+
+	tests := []struct {
+		name         string
+		replies      []string
+		providerErr  error
+		wantAttempts int
+		wantErr      string
+		wantRequests int
+	}{
+		{name: "first attempt", replies: []string{valid}, wantAttempts: 1, wantRequests: 1},
+		{name: "fixed after feedback", replies: []string{invalid, "not json", valid}, wantAttempts: 3, wantRequests: 3},
+		{name: "gives up", replies: []string{invalid, invalid, invalid}, wantErr: "parse error", wantRequests: maxGenerateAttempts},
+		{name: "provider error", providerErr: errors.New("connection refused"), wantErr: "AI request failed: connection refused", wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &scriptedProvider{replies: tt.replies, err: tt.providerErr}
+			messages := []ai.Message{{Role: "system", Content: "system"}, {Role: "user", Content: "prompt"}}
+
+			result, err := generateRule(context.Background(), provider, messages, "node", validate)
+			if len(provider.requests) != tt.wantRequests {
+				t.Errorf("provider called %d times, want %d", len(provider.requests), tt.wantRequests)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if got, want := errors.Is(err, errAIRequestFailed), tt.providerErr != nil; got != want {
+					t.Errorf("errors.Is(err, errAIRequestFailed) = %v, want %v", got, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Attempts != tt.wantAttempts || result.GroupName != "node" || len(result.Warnings) != 1 {
+				t.Errorf("unexpected result %+v", result)
+			}
+		})
+	}
+}
+
+func TestGenerateRuleFeedback(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{"not json", `{"alert_name": "HighCPU", "expr": "cpu > 80"}`}}
+	messages := []ai.Message{{Role: "system", Content: "system"}, {Role: "user", Content: "prompt"}}
+	validate := func(models.CreateAlertRuleRequest) ([]models.LintIssue, error) { return nil, nil }
+
+	if _, err := generateRule(context.Background(), provider, messages, "node", validate); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二次请求包含上一次的回复和校验错误
+	retry := provider.requests[1]
+	if len(retry) != 4 {
+		t.Fatalf("retry has %d messages, want 4", len(retry))
+	}
+	if retry[2].Role != "assistant" || retry[2].Content != "not json" {
+		t.Errorf("unexpected assistant message %+v", retry[2])
+	}
+	if retry[3].Role != "user" || !strings.Contains(retry[3].Content, "response is not a JSON object") {
+		t.Errorf("unexpected feedback message %+v", retry[3])
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"promeconfig-backend/internal/ai"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 测试连接时的超时，比生成规则短
+const aiTestTimeout = 20 * time.Second
+
+func aiConfig(provider, apiKey string, baseURL *string, model, apiVersion string, temperature float64) ai.Config {
+	cfg := ai.Config{
+		Provider:    provider,
+		APIKey:      apiKey,
+		Model:       model,
+		APIVersion:  apiVersion,
+		Temperature: temperature,
+	}
+	if baseURL != nil {
+		cfg.BaseURL = *baseURL
+	}
+	return cfg
+}
+
+// 已保存的AI设置，API Key解密后使用
+func (h *Handlers) settingsConfig(settings *models.AISettings) (ai.Config, error) {
+	apiKey, err := h.aiAPIKey(settings)
+	if err != nil {
+		return ai.Config{}, err
+	}
+	return aiConfig(settings.Provider, apiKey, settings.BaseURL, settings.Model, settings.APIVersion, settings.Temperature), nil
+}
+
+// 请求中的设置与已保存的设置使用同一个接口时才能沿用已保存的API Key，避免把Key发送到其他地址
+func sameAIEndpoint(saved *models.AISettings, provider string, baseURL *string) bool {
+	if saved == nil || saved.Provider != provider {
+		return false
+	}
+	savedURL, reqURL := "", ""
+	if saved.BaseURL != nil {
+		savedURL = *saved.BaseURL
+	}
+	if baseURL != nil {
+		reqURL = *baseURL
+	}
+	return savedURL == reqURL
+}
+
+// 测试AI设置能否正常调用模型，与生成规则一样需要 rules:write 权限；请求体为空时测试已保存的设置，
+// 否则测试请求中的设置（未提供api_key且提供商和base_url与已保存的相同时使用已保存的Key）
+func (h *Handlers) TestAISettings(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	saved, err := getAISettings(h.db, userID)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI settings"})
+		return
+	}
+
+	var cfg ai.Config
+	var req models.SaveAISettingsRequest
+	if err := c.ShouldBindJSON(&req); err == nil {
+		apiKey := ""
+		if req.APIKey != nil {
+			apiKey = *req.APIKey
+		} else if sameAIEndpoint(saved, req.Provider, req.BaseURL) {
+			if apiKey, err = h.aiAPIKey(saved); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt AI API key"})
+				return
+			}
+		}
+		cfg = aiConfig(req.Provider, apiKey, req.BaseURL, req.Model, req.APIVersion, req.Temperature)
+	} else if errors.Is(err, io.EOF) {
+		if saved == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "AI settings not configured"})
+			return
+		}
+		if cfg, err = h.settingsConfig(saved); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt AI API key"})
+			return
+		}
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider, err := ai.New(cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), aiTestTimeout)
+	defer cancel()
+
+	started := time.Now()
+	reply, err := provider.Complete(ctx, ai.Request{
+		Messages:  []ai.Message{{Role: "user", Content: "Reply with the single word: pong"}},
+		MaxTokens: 16,
+	})
+	latency := time.Since(started).Milliseconds()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "AI request failed: " + err.Error(), "latency_ms": latency})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Connection successful",
+		"provider":   cfg.Provider,
+		"model":      cfg.Model,
+		"reply":      reply,
+		"latency_ms": latency,
+	})
+}
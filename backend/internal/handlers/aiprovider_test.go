@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"testing"
+
+	"promeconfig-backend/internal/models"
+)
+
+func TestSameAIEndpoint(t *testing.T) {
+	savedURL := "https://llm.example.com/v1"
+	otherURL := "https://attacker.example.com/v1"
+	empty := ""
+	saved := &models.AISettings{Provider: "custom", BaseURL: &savedURL}
+	savedDefault := &models.AISettings{Provider: "openai"}
+
+	tests := []struct {
+		name     string
+		saved    *models.AISettings
+		provider string
+		baseURL  *string
+		want     bool
+	}{
+		{"same endpoint", saved, "custom", &savedURL, true},
+		{"different base_url", saved, "custom", &otherURL, false},
+		{"base_url removed", saved, "custom", nil, false},
+		{"different provider", saved, "openai", &savedURL, false},
+		{"default endpoint", savedDefault, "openai", nil, true},
+		{"empty base_url is the default endpoint", savedDefault, "openai", &empty, true},
+		{"base_url added", savedDefault, "openai", &otherURL, false},
+		{"nothing saved", nil, "openai", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameAIEndpoint(tt.saved, tt.provider, tt.baseURL); got != tt.want {
+				t.Errorf("sameAIEndpoint = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+const alertmanagerColumns = `id, org_id, name, instance_ids, scheme, path_prefix, api_version, timeout, static_targets,
+	sd_configs, relabel_configs, username, password, bearer_token, tls_ca_cert, tls_cert, tls_key, tls_server_name,
+	insecure_skip_verify, created_at, updated_at`
+
+func scanAlertmanager(row rowScanner) (*models.Alertmanager, error) {
+	var am models.Alertmanager
+	var staticTargets, sdConfigs, relabelConfigs []byte
+	err := row.Scan(&am.ID, &am.OrgID, &am.Name, &am.InstanceIDs, &am.Scheme, &am.PathPrefix, &am.APIVersion,
+		&am.Timeout, &staticTargets, &sdConfigs, &relabelConfigs, &am.Username, &am.Password, &am.BearerToken,
+		&am.TLSCACert, &am.TLSCert, &am.TLSKey, &am.TLSServerName, &am.InsecureSkipVerify, &am.CreatedAt, &am.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(staticTargets, &am.StaticTargets); err != nil {
+		return nil, err
+	}
+	am.SDConfigs = sdConfigs
+	am.RelabelConfigs = relabelConfigs
+	setClientAuthFlags(&am.ClientAuth)
+	return &am, nil
+}
+
+// 查询组织的Alertmanager，按名称排序
+func queryAlertmanagers(q querier, orgID uuid.UUID) ([]models.Alertmanager, error) {
+	rows, err := q.Query(`SELECT `+alertmanagerColumns+` FROM alertmanagers WHERE org_id = $1 ORDER BY name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alertmanagers := []models.Alertmanager{}
+	for rows.Next() {
+		am, err := scanAlertmanager(rows)
+		if err != nil {
+			return nil, err
+		}
+		alertmanagers = append(alertmanagers, *am)
+	}
+	return alertmanagers, rows.Err()
+}
+
+func insertAlertmanager(q querier, am *models.Alertmanager) (*models.Alertmanager, error) {
+	if am.StaticTargets == nil {
+		am.StaticTargets = []string{}
+	}
+	staticTargets, err := json.Marshal(am.StaticTargets)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAlertmanager(q.QueryRow(`
+		INSERT INTO alertmanagers (id, org_id, name, instance_ids, scheme, path_prefix, api_version, timeout,
+		                           static_targets, sd_configs, relabel_configs, username, password, bearer_token,
+		                           tls_ca_cert, tls_cert, tls_key, tls_server_name, insecure_skip_verify)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		RETURNING `+alertmanagerColumns,
+		am.ID, am.OrgID, am.Name, am.InstanceIDs, am.Scheme, am.PathPrefix, am.APIVersion, am.Timeout,
+		staticTargets, nullableJSON(am.SDConfigs), nullableJSON(am.RelabelConfigs), am.Username, am.Password,
+		am.BearerToken, am.TLSCACert, am.TLSCert, am.TLSKey, am.TLSServerName, am.InsecureSkipVerify))
+}
+
+// 按路径中的ID加载Alertmanager，返回nil时已写入响应
+func loadAlertmanager(c *gin.Context, q querier, orgID uuid.UUID, lock bool) *models.Alertmanager {
+	amUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alertmanager ID"})
+		return nil
+	}
+
+	query := `SELECT ` + alertmanagerColumns + ` FROM alertmanagers WHERE id = $1 AND org_id = $2`
+	if lock {
+		query += " FOR UPDATE"
+	}
+	am, err := scanAlertmanager(q.QueryRow(query, amUUID, orgID))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alertmanager not found"})
+		return nil
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alertmanager"})
+		return nil
+	}
+	return am
+}
+
+// 把请求应用到Alertmanager上，并用Prometheus的配置解析检查，返回false时已写入响应
+func prepareAlertmanager(c *gin.Context, am *models.Alertmanager, req models.SaveAlertmanagerRequest) bool {
+	// sd_configs 只能包含服务发现配置，其他字段有各自的请求字段
+	if len(req.SDConfigs) > 0 {
+		var sdConfigs map[string]json.RawMessage
+		if err := json.Unmarshal(req.SDConfigs, &sdConfigs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sd_configs must be an object"})
+			return false
+		}
+		for key := range sdConfigs {
+			if !strings.HasSuffix(key, "_sd_configs") {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "sd_configs only accepts *_sd_configs keys, got " + key})
+				return false
+			}
+		}
+	}
+	if len(req.StaticTargets) == 0 && len(req.SDConfigs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "static_targets or sd_configs is required"})
+		return false
+	}
+
+	am.Name = req.Name
+	am.InstanceIDs = req.InstanceIDs
+	am.Scheme = req.Scheme
+	am.PathPrefix = req.PathPrefix
+	am.APIVersion = req.APIVersion
+	am.Timeout = req.Timeout
+	am.StaticTargets = req.StaticTargets
+	am.SDConfigs = req.SDConfigs
+	am.RelabelConfigs = req.RelabelConfigs
+	applyClientAuth(&am.ClientAuth, req.ClientAuthRequest)
+
+	if am.InstanceIDs == nil {
+		am.InstanceIDs = models.UUIDList{}
+	}
+	if am.StaticTargets == nil {
+		am.StaticTargets = []string{}
+	}
+
+	if checkErrs := prometheus.CheckAlertmanager(am); len(checkErrs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid alertmanager config", "errors": checkErrs})
+		return false
+	}
+	return true
+}
+
+// 名称在组织内不能重复，返回false时已写入响应
+func checkAlertmanagerConflict(c *gin.Context, q querier, am *models.Alertmanager) bool {
+	var exists bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM alertmanagers WHERE org_id = $1 AND id <> $2 AND name = $3)`,
+		am.OrgID, am.ID, am.Name).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "An alertmanager with this name already exists"})
+		return false
+	}
+	return true
+}
+
+func (h *Handlers) GetAlertmanagers(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	alertmanagers, err := queryAlertmanagers(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alertmanagers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alertmanagers)
+}
+
+func (h *Handlers) GetAlertmanager(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	am := loadAlertmanager(c, h.db, orgID, false)
+	if am == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, am)
+}
+
+func (h *Handlers) CreateAlertmanager(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
+		return
+	}
+
+	var req models.SaveAlertmanagerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	am := &models.Alertmanager{ID: uuid.New(), OrgID: orgID}
+	if !prepareAlertmanager(c, am, req) {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if !validateInstanceIDs(c, tx, orgID, am.InstanceIDs) || !checkAlertmanagerConflict(c, tx, am) {
+		return
+	}
+
+	am, err = insertAlertmanager(tx, am)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alertmanager"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditCreate, "alertmanager", am.ID, nil, am); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alertmanager"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, am)
+}
+
+func (h *Handlers) UpdateAlertmanager(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
+		return
+	}
+
+	var req models.SaveAlertmanagerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before := loadAlertmanager(c, tx, orgID, true)
+	if before == nil {
+		return
+	}
+
+	am := *before
+	if !prepareAlertmanager(c, &am, req) ||
+		!validateInstanceIDs(c, tx, orgID, am.InstanceIDs) ||
+		!checkAlertmanagerConflict(c, tx, &am) {
+		return
+	}
+
+	staticTargets, err := json.Marshal(am.StaticTargets)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	after, err := scanAlertmanager(tx.QueryRow(`
+		UPDATE alertmanagers
+		SET name = $1, instance_ids = $2, scheme = $3, path_prefix = $4, api_version = $5, timeout = $6,
+		    static_targets = $7, sd_configs = $8, relabel_configs = $9, username = $10, password = $11,
+		    bearer_token = $12, tls_ca_cert = $13, tls_cert = $14, tls_key = $15, tls_server_name = $16,
+		    insecure_skip_verify = $17
+		WHERE id = $18 AND org_id = $19
+		RETURNING `+alertmanagerColumns,
+		am.Name, am.InstanceIDs, am.Scheme, am.PathPrefix, am.APIVersion, am.Timeout, staticTargets,
+		nullableJSON(am.SDConfigs), nullableJSON(am.RelabelConfigs), am.Username, am.Password, am.BearerToken,
+		am.TLSCACert, am.TLSCert, am.TLSKey, am.TLSServerName, am.InsecureSkipVerify, am.ID, orgID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alertmanager"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditUpdate, "alertmanager", after.ID, before, after); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alertmanager"})
+		return
+	}
+
+	c.JSON(http.StatusOK, after)
+}
+
+func (h *Handlers) DeleteAlertmanager(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before := loadAlertmanager(c, tx, orgID, true)
+	if before == nil {
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM alertmanagers WHERE id = $1 AND org_id = $2", before.ID, orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alertmanager"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditDelete, "alertmanager", before.ID, before, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alertmanager"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alertmanager deleted successfully"})
+}
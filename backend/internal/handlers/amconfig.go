@@ -0,0 +1,865 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/alertmanager"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 组织没有保存Alertmanager设置，也没有配置 ALERTMANAGER_URL
+var errAlertmanagerNotConfigured = errors.New("Alertmanager is not configured")
+
+// 未指定输出目录时 alertmanager.yml 写入组织目录下的该子目录
+const defaultAlertmanagerOutputDir = "alertmanager"
+
+const alertmanagerSettingsColumns = `org_id, url, username, password, bearer_token, insecure_skip_verify,
+	ca_cert, output_dir, reload_mode, updated_at`
+
+func scanAlertmanagerSettings(row rowScanner) (*models.AlertmanagerSettings, error) {
+	var settings models.AlertmanagerSettings
+	err := row.Scan(&settings.OrgID, &settings.URL, &settings.Username, &settings.Password, &settings.BearerToken,
+		&settings.InsecureSkipVerify, &settings.CACert, &settings.OutputDir, &settings.ReloadMode, &settings.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	settings.HasPassword = settings.Password != ""
+	settings.HasBearerToken = settings.BearerToken != ""
+	return &settings, nil
+}
+
+// 组织未保存设置时使用的默认设置，只有运维组织使用环境变量配置的连接，其他组织没有连接
+func (h *Handlers) defaultAlertmanagerSettings(orgID uuid.UUID) models.AlertmanagerSettings {
+	settings := models.AlertmanagerSettings{
+		OrgID:      orgID,
+		OutputDir:  defaultAlertmanagerOutputDir,
+		ReloadMode: "none",
+		Implicit:   true,
+	}
+	if h.isOperatorOrg(orgID) && h.cfg.AlertmanagerURL != "" {
+		settings.URL = h.cfg.AlertmanagerURL
+		settings.Username = h.cfg.AlertmanagerUsername
+		settings.Password = h.cfg.AlertmanagerPassword
+		settings.HasPassword = h.cfg.AlertmanagerPassword != ""
+		settings.InsecureSkipVerify = h.cfg.AlertmanagerInsecureSkipVerify
+		settings.ReloadMode = "http"
+	}
+	return settings
+}
+
+// 组织的Alertmanager设置，没有保存过时返回默认设置
+func (h *Handlers) getAlertmanagerSettings(q querier, orgID uuid.UUID) (*models.AlertmanagerSettings, error) {
+	settings, err := scanAlertmanagerSettings(q.QueryRow(`SELECT `+alertmanagerSettingsColumns+`
+		FROM alertmanager_settings WHERE org_id = $1`, orgID))
+	if err == sql.ErrNoRows {
+		defaults := h.defaultAlertmanagerSettings(orgID)
+		return &defaults, nil
+	}
+	return settings, err
+}
+
+// alertmanager.yml 的输出目录，位于组织目录之下
+func (h *Handlers) alertmanagerDir(settings *models.AlertmanagerSettings) string {
+	return filepath.Join(h.configDir(settings.OrgID), settings.OutputDir)
+}
+
+func alertmanagerClientConfig(settings *models.AlertmanagerSettings) alertmanager.ClientConfig {
+	return alertmanager.ClientConfig{
+		URL:                settings.URL,
+		Username:           settings.Username,
+		Password:           settings.Password,
+		BearerToken:        settings.BearerToken,
+		InsecureSkipVerify: settings.InsecureSkipVerify,
+		CACert:             settings.CACert,
+	}
+}
+
+// Alertmanager的API客户端，没有配置URL时返回 errAlertmanagerNotConfigured
+func alertmanagerClient(settings *models.AlertmanagerSettings) (*alertmanager.Client, error) {
+	if settings.URL == "" {
+		return nil, errAlertmanagerNotConfigured
+	}
+	return alertmanager.NewClient(alertmanagerClientConfig(settings))
+}
+
+const alertmanagerConfigColumns = `global, global_secrets, route, inhibit_rules, time_intervals`
+
+// 组织的 alertmanager.yml 配置（不含接收者），没有保存过时返回空配置
+func getAlertmanagerConfig(q querier, orgID uuid.UUID, lock bool) (*models.AlertmanagerConfig, error) {
+	query := `SELECT ` + alertmanagerConfigColumns + ` FROM alertmanager_configs WHERE org_id = $1`
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	cfg := models.AlertmanagerConfig{
+		GlobalSecrets: map[string]string{},
+		InhibitRules:  []models.InhibitRule{},
+		TimeIntervals: []models.TimeInterval{},
+	}
+	var global, globalSecrets, route, inhibitRules, timeIntervals []byte
+	err := q.QueryRow(query, orgID).Scan(&global, &globalSecrets, &route, &inhibitRules, &timeIntervals)
+	if err == sql.ErrNoRows {
+		return &cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range []struct {
+		raw []byte
+		v   interface{}
+	}{
+		{global, &cfg.Global},
+		{globalSecrets, &cfg.GlobalSecrets},
+		{route, &cfg.Route},
+		{inhibitRules, &cfg.InhibitRules},
+		{timeIntervals, &cfg.TimeIntervals},
+	} {
+		if err := json.Unmarshal(field.raw, field.v); err != nil {
+			return nil, err
+		}
+	}
+	setGlobalSecretFlags(&cfg.Global, cfg.GlobalSecrets)
+	return &cfg, nil
+}
+
+// 完整的 alertmanager.yml 配置，包括全部接收者
+func loadAlertmanagerConfig(q querier, orgID uuid.UUID) (*models.AlertmanagerConfig, error) {
+	cfg, err := getAlertmanagerConfig(q, orgID, false)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Receivers, err = queryAlertmanagerReceivers(q, orgID); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// 保存 alertmanager_configs 中的一列，没有记录时插入
+func saveAlertmanagerConfigColumn(q querier, orgID uuid.UUID, column string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`
+		INSERT INTO alertmanager_configs (org_id, `+column+`) VALUES ($1, $2)
+		ON CONFLICT (org_id) DO UPDATE SET `+column+` = EXCLUDED.`+column, orgID, data)
+	return err
+}
+
+// global 的密钥只在请求中出现
+func setGlobalSecretFlags(global *models.AlertmanagerGlobal, secrets map[string]string) {
+	global.SMTPAuthPassword = nil
+	global.SlackAPIURL = nil
+	global.OpsGenieAPIKey = nil
+	_, global.HasSMTPAuthPassword = secrets["smtp_auth_password"]
+	_, global.HasSlackAPIURL = secrets["slack_api_url"]
+	_, global.HasOpsGenieAPIKey = secrets["opsgenie_api_key"]
+}
+
+// 把请求中的密钥移入 secrets 并清空请求中的值：null 沿用 previous 中的原值，空字符串表示清除
+func moveSecret(secrets, previous map[string]string, key string, value **string) {
+	if *value != nil {
+		if **value != "" {
+			secrets[key] = **value
+		}
+	} else if current, ok := previous[key]; ok {
+		secrets[key] = current
+	}
+	*value = nil
+}
+
+// 检查替换部分内容后的完整配置，返回false时已写入响应
+func checkAlertmanagerConfig(c *gin.Context, cfg *models.AlertmanagerConfig, message string) bool {
+	if checkErrs := alertmanager.CheckConfig(*cfg); len(checkErrs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": message, "errors": checkErrs})
+		return false
+	}
+	return true
+}
+
+// 获取Alertmanager连接设置，未保存时返回由环境变量配置的默认设置
+func (h *Handlers) GetAlertmanagerSettings(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	settings, err := h.getAlertmanagerSettings(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Alertmanager settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// 保存Alertmanager连接设置
+func (h *Handlers) SaveAlertmanagerSettings(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.SaveAlertmanagerSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := scanAlertmanagerSettings(tx.QueryRow(`SELECT `+alertmanagerSettingsColumns+`
+		FROM alertmanager_settings WHERE org_id = $1 FOR UPDATE`, orgID))
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save Alertmanager settings"})
+		return
+	}
+
+	settings := models.AlertmanagerSettings{
+		OrgID:              orgID,
+		URL:                req.URL,
+		Username:           req.Username,
+		InsecureSkipVerify: req.InsecureSkipVerify,
+		CACert:             req.CACert,
+		OutputDir:          req.OutputDir,
+		ReloadMode:         req.ReloadMode,
+	}
+	if current != nil {
+		settings.Password = current.Password
+		settings.BearerToken = current.BearerToken
+	}
+	if req.Password != nil {
+		settings.Password = *req.Password
+	}
+	if req.BearerToken != nil {
+		settings.BearerToken = *req.BearerToken
+	}
+	if settings.ReloadMode == "" {
+		settings.ReloadMode = "http"
+	}
+	if settings.ReloadMode == "http" && settings.URL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url is required when reload_mode is http"})
+		return
+	}
+
+	// 输出目录是组织目录下的相对路径，不能与Prometheus实例的输出目录重合
+	if settings.OutputDir == "" {
+		settings.OutputDir = defaultAlertmanagerOutputDir
+	}
+	settings.OutputDir = filepath.Clean(settings.OutputDir)
+	if !filepath.IsLocal(settings.OutputDir) || settings.OutputDir == "." {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "output_dir must be a subdirectory of the organization config directory"})
+		return
+	}
+	var conflict bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM prometheus_instances WHERE org_id = $1 AND output_dir = $2)`,
+		orgID, settings.OutputDir).Scan(&conflict); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if conflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "output_dir is used by a Prometheus instance"})
+		return
+	}
+
+	// 创建客户端以校验URL和CA证书
+	if settings.URL != "" {
+		if _, err := alertmanager.NewClient(alertmanagerClientConfig(&settings)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	saved, err := scanAlertmanagerSettings(tx.QueryRow(`
+		INSERT INTO alertmanager_settings (org_id, url, username, password, bearer_token, insecure_skip_verify,
+		                                   ca_cert, output_dir, reload_mode)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (org_id) DO UPDATE SET
+			url = EXCLUDED.url, username = EXCLUDED.username, password = EXCLUDED.password,
+			bearer_token = EXCLUDED.bearer_token, insecure_skip_verify = EXCLUDED.insecure_skip_verify,
+			ca_cert = EXCLUDED.ca_cert, output_dir = EXCLUDED.output_dir, reload_mode = EXCLUDED.reload_mode
+		RETURNING `+alertmanagerSettingsColumns,
+		orgID, settings.URL, settings.Username, settings.Password, settings.BearerToken, settings.InsecureSkipVerify,
+		settings.CACert, settings.OutputDir, settings.ReloadMode))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save Alertmanager settings"})
+		return
+	}
+
+	var before interface{}
+	if current != nil {
+		before = current
+	}
+	if err := recordAudit(tx, c, auditEntry{
+		Action:     auditUpdate,
+		EntityType: "alertmanager_settings",
+		EntityID:   orgID.String(),
+		Before:     before,
+		After:      saved,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save Alertmanager settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// 读取 alertmanager.yml 配置的一部分
+func (h *Handlers) getAlertmanagerSection(c *gin.Context, section func(cfg *models.AlertmanagerConfig) interface{}) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	cfg, err := getAlertmanagerConfig(h.db, orgID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Alertmanager config"})
+		return
+	}
+
+	c.JSON(http.StatusOK, section(cfg))
+}
+
+// 保存 alertmanager.yml 配置的一部分，调用前已鉴权：apply 把请求应用到配置上并返回要写入的列和值，
+// 保存前检查替换后的完整配置，修改记录审计日志
+func (h *Handlers) saveAlertmanagerSection(c *gin.Context, entityType string,
+	apply func(cfg *models.AlertmanagerConfig) (before, after interface{}, columns map[string]interface{})) {
+	orgID, _ := middleware.GetOrgID(c)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	cfg, err := getAlertmanagerConfig(tx, orgID, true)
+	if err == nil {
+		cfg.Receivers, err = queryAlertmanagerReceivers(tx, orgID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Alertmanager config"})
+		return
+	}
+
+	before, after, columns := apply(cfg)
+	if !checkAlertmanagerConfig(c, cfg, "Invalid Alertmanager config") {
+		return
+	}
+
+	for column, value := range columns {
+		if err := saveAlertmanagerConfigColumn(tx, orgID, column, value); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save Alertmanager config"})
+			return
+		}
+	}
+
+	if err := recordAudit(tx, c, auditEntry{
+		Action:     auditUpdate,
+		EntityType: entityType,
+		EntityID:   orgID.String(),
+		Before:     before,
+		After:      after,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save Alertmanager config"})
+		return
+	}
+
+	c.JSON(http.StatusOK, after)
+}
+
+// 获取 alertmanager.yml 的 global，密钥只返回是否已设置
+func (h *Handlers) GetAlertmanagerGlobal(c *gin.Context) {
+	h.getAlertmanagerSection(c, func(cfg *models.AlertmanagerConfig) interface{} { return cfg.Global })
+}
+
+// 保存 global，密钥为null时保持不变，空字符串表示清除
+func (h *Handlers) SaveAlertmanagerGlobal(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+
+	var req models.AlertmanagerGlobal
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.saveAlertmanagerSection(c, "alertmanager_global",
+		func(cfg *models.AlertmanagerConfig) (interface{}, interface{}, map[string]interface{}) {
+			before := cfg.Global
+			secrets := map[string]string{}
+			moveSecret(secrets, cfg.GlobalSecrets, "smtp_auth_password", &req.SMTPAuthPassword)
+			moveSecret(secrets, cfg.GlobalSecrets, "slack_api_url", &req.SlackAPIURL)
+			moveSecret(secrets, cfg.GlobalSecrets, "opsgenie_api_key", &req.OpsGenieAPIKey)
+			setGlobalSecretFlags(&req, secrets)
+
+			cfg.Global = req
+			cfg.GlobalSecrets = secrets
+			return before, req, map[string]interface{}{"global": req, "global_secrets": secrets}
+		})
+}
+
+// 获取路由树
+func (h *Handlers) GetAlertmanagerRoute(c *gin.Context) {
+	h.getAlertmanagerSection(c, func(cfg *models.AlertmanagerConfig) interface{} { return cfg.Route })
+}
+
+// 保存路由树，引用的接收者和时间段必须存在
+func (h *Handlers) SaveAlertmanagerRoute(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+
+	var req models.AlertmanagerRoute
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.saveAlertmanagerSection(c, "alertmanager_route",
+		func(cfg *models.AlertmanagerConfig) (interface{}, interface{}, map[string]interface{}) {
+			before := cfg.Route
+			cfg.Route = req
+			return before, req, map[string]interface{}{"route": req}
+		})
+}
+
+// 获取抑制规则
+func (h *Handlers) GetInhibitRules(c *gin.Context) {
+	h.getAlertmanagerSection(c, func(cfg *models.AlertmanagerConfig) interface{} { return cfg.InhibitRules })
+}
+
+// 保存抑制规则，整体替换
+func (h *Handlers) SaveInhibitRules(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+
+	var req []models.InhibitRule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req == nil {
+		req = []models.InhibitRule{}
+	}
+
+	h.saveAlertmanagerSection(c, "alertmanager_inhibit_rules",
+		func(cfg *models.AlertmanagerConfig) (interface{}, interface{}, map[string]interface{}) {
+			before := cfg.InhibitRules
+			cfg.InhibitRules = req
+			return before, req, map[string]interface{}{"inhibit_rules": req}
+		})
+}
+
+// 获取时间段
+func (h *Handlers) GetTimeIntervals(c *gin.Context) {
+	h.getAlertmanagerSection(c, func(cfg *models.AlertmanagerConfig) interface{} { return cfg.TimeIntervals })
+}
+
+// 保存时间段，整体替换，路由仍在引用的时间段不能删除
+func (h *Handlers) SaveTimeIntervals(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+
+	var req []models.TimeInterval
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req == nil {
+		req = []models.TimeInterval{}
+	}
+
+	h.saveAlertmanagerSection(c, "alertmanager_time_intervals",
+		func(cfg *models.AlertmanagerConfig) (interface{}, interface{}, map[string]interface{}) {
+			before := cfg.TimeIntervals
+			cfg.TimeIntervals = req
+			return before, req, map[string]interface{}{"time_intervals": req}
+		})
+}
+
+// 预览当前配置渲染出的 alertmanager.yml 和检查结果，不写入文件
+func (h *Handlers) GetAlertmanagerConfig(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRender) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	cfg, err := loadAlertmanagerConfig(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Alertmanager config"})
+		return
+	}
+
+	config, _, err := alertmanager.Render(*cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	checkErrs := alertmanager.Check(config)
+	c.JSON(http.StatusOK, gin.H{
+		"config": string(config),
+		"valid":  len(checkErrs) == 0,
+		"errors": checkErrs,
+	})
+}
+
+// 渲染、检查并写入 alertmanager.yml 和密钥文件，记录revision；未通过检查时返回422且不写入
+func (h *Handlers) SyncAlertmanagerConfig(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !middleware.Authorize(c, rbac.PermPrometheusSync) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.SyncConfigRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.ChangeSetID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Change sets do not apply to Alertmanager config"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// 同一组织的同步串行执行，保证revision顺序与文件内容一致
+	if _, err := tx.Exec("SELECT id FROM organizations WHERE id = $1 FOR UPDATE", orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	settings, err := h.getAlertmanagerSettings(tx, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Alertmanager settings"})
+		return
+	}
+	cfg, err := loadAlertmanagerConfig(tx, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Alertmanager config"})
+		return
+	}
+
+	config, secrets, err := alertmanager.Render(*cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if checkErrs := alertmanager.Check(config); len(checkErrs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Configuration check failed", "errors": checkErrs})
+		return
+	}
+
+	// 与Prometheus的同步一样先暂存文件，事务提交后再替换；密钥文件先于引用它的配置替换
+	var staged prometheus.StagedFiles
+	defer staged.Discard()
+	dir := h.alertmanagerDir(settings)
+	err = staged.StageSecrets(filepath.Join(dir, alertmanager.SecretsDir), secrets)
+	if err == nil {
+		err = staged.Stage(dir, map[string][]byte{alertmanager.ConfigFileName: config})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	revision := models.AlertmanagerRevision{Config: string(config)}
+	err = tx.QueryRow(`
+		INSERT INTO alertmanager_revisions (org_id, author_id, message, config)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, org_id, author_id, message, created_at`,
+		orgID, userID, req.Message, revision.Config).Scan(
+		&revision.ID, &revision.OrgID, &revision.AuthorID, &revision.Message, &revision.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record revision"})
+		return
+	}
+
+	if err := recordAudit(tx, c, auditEntry{
+		Action:     auditSync,
+		EntityType: "alertmanager",
+		EntityID:   orgID.String(),
+		After:      gin.H{"revision_id": revision.ID, "message": revision.Message},
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync configuration"})
+		return
+	}
+
+	if err := staged.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration synced but failed to write files: " + err.Error(), "revision": revision})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alertmanager configuration synced successfully", "revision": revision})
+}
+
+// 通过 /-/reload 让Alertmanager重新加载配置
+func (h *Handlers) ReloadAlertmanagerConfig(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermPrometheusReload) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	settings, err := h.getAlertmanagerSettings(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Alertmanager settings"})
+		return
+	}
+	if settings.ReloadMode != "http" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reload is disabled for Alertmanager (reload_mode is none)"})
+		return
+	}
+
+	client, err := alertmanagerClient(settings)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), instanceRequestTimeout)
+	defer cancel()
+	reloadErr := client.Reload(ctx)
+
+	result := gin.H{"status": syncStatusReloaded}
+	if reloadErr != nil {
+		result = gin.H{"status": syncStatusFailed, "error": reloadErr.Error()}
+	}
+	if err := recordAudit(h.db, c, auditEntry{
+		Action:     auditReload,
+		EntityType: "alertmanager",
+		EntityID:   orgID.String(),
+		After:      result,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if reloadErr != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reload Alertmanager configuration: " + reloadErr.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Alertmanager configuration reloaded successfully"})
+}
+
+// 最近一次同步的revision，没有时返回 sql.ErrNoRows
+func latestAlertmanagerRevision(q querier, orgID uuid.UUID) (*models.AlertmanagerRevision, error) {
+	var revision models.AlertmanagerRevision
+	err := q.QueryRow(`
+		SELECT id, org_id, author_id, message, config, created_at FROM alertmanager_revisions
+		WHERE org_id = $1 ORDER BY created_at DESC LIMIT 1`, orgID).Scan(
+		&revision.ID, &revision.OrgID, &revision.AuthorID, &revision.Message, &revision.Config, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// 获取Alertmanager的运行状态，并比较其加载的配置与最近一次同步的配置
+func (h *Handlers) GetAlertmanagerStatus(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	settings, err := h.getAlertmanagerSettings(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Alertmanager settings"})
+		return
+	}
+
+	status := models.AlertmanagerStatus{URL: settings.URL}
+	revision, err := latestAlertmanagerRevision(h.db, orgID)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revision"})
+		return
+	}
+	if revision != nil {
+		status.LastRevisionID = &revision.ID
+	}
+
+	client, err := alertmanagerClient(settings)
+	if err != nil {
+		status.Error = err.Error()
+		c.JSON(http.StatusOK, status)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), instanceRequestTimeout)
+	defer cancel()
+
+	if err := client.Healthy(ctx); err != nil {
+		status.Error = err.Error()
+		c.JSON(http.StatusOK, status)
+		return
+	}
+	status.Healthy = true
+
+	amStatus, err := client.Status(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		c.JSON(http.StatusOK, status)
+		return
+	}
+	status.Version = amStatus.VersionInfo.Version
+	status.Uptime = &amStatus.Uptime
+	status.ClusterStatus = amStatus.Cluster.Status
+	if revision != nil {
+		inSync := amStatus.Config.Original == revision.Config
+		status.ConfigInSync = &inSync
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// 获取同步记录，不含配置内容
+func (h *Handlers) GetAlertmanagerRevisions(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	rows, err := h.db.Query(`
+		SELECT r.id, r.org_id, r.author_id, COALESCE(u.email, ''), r.message, r.created_at
+		FROM alertmanager_revisions r LEFT JOIN users u ON u.id = r.author_id
+		WHERE r.org_id = $1
+		ORDER BY r.created_at DESC`, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revisions"})
+		return
+	}
+	defer rows.Close()
+
+	revisions := []models.AlertmanagerRevision{}
+	for rows.Next() {
+		var revision models.AlertmanagerRevision
+		if err := rows.Scan(&revision.ID, &revision.OrgID, &revision.AuthorID, &revision.AuthorEmail,
+			&revision.Message, &revision.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan revision"})
+			return
+		}
+		revisions = append(revisions, revision)
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// 用当前配置的路由树匹配一组标签，返回匹配的路由、接收者和相关的抑制规则
+func (h *Handlers) TestAlertmanagerRoute(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.RouteTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Labels) == 0 && req.AlertRuleID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "labels or alert_rule_id is required"})
+		return
+	}
+
+	// 规则中值含模板的标签要到告警触发时才有值，不参与匹配
+	lset := map[string]string{}
+	unresolved := []string{}
+	if req.AlertRuleID != nil {
+		rule, err := getAlertRule(h.db, orgID, *req.AlertRuleID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rule"})
+			return
+		}
+		ruleLabels := map[string]string{}
+		if len(rule.Labels) > 0 && string(rule.Labels) != "null" {
+			if err := json.Unmarshal(rule.Labels, &ruleLabels); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid alert rule labels"})
+				return
+			}
+		}
+		for name, value := range ruleLabels {
+			if !strings.Contains(value, "{{") {
+				lset[name] = value
+			} else if _, ok := req.Labels[name]; !ok {
+				unresolved = append(unresolved, name)
+			}
+		}
+		sort.Strings(unresolved)
+		lset["alertname"] = rule.AlertName
+	}
+	for name, value := range req.Labels {
+		lset[name] = value
+	}
+
+	cfg, err := loadAlertmanagerConfig(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Alertmanager config"})
+		return
+	}
+	config, _, err := alertmanager.Render(*cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := alertmanager.TestRoutes(config, lset)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid Alertmanager config", "errors": alertmanager.Check(config)})
+		return
+	}
+	result.Unresolved = unresolved
+
+	c.JSON(http.StatusOK, result)
+}
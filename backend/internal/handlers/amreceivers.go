@@ -0,0 +1,484 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/alertmanager"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/rbac"
+)
+
+const alertmanagerReceiverColumns = `id, org_id, name, email_configs, webhook_configs, slack_configs,
+	pagerduty_configs, opsgenie_configs, secrets, created_at, updated_at`
+
+func scanAlertmanagerReceiver(row rowScanner) (*models.AlertmanagerReceiver, error) {
+	var receiver models.AlertmanagerReceiver
+	var email, webhook, slack, pagerDuty, opsGenie, secrets []byte
+	err := row.Scan(&receiver.ID, &receiver.OrgID, &receiver.Name, &email, &webhook, &slack, &pagerDuty, &opsGenie,
+		&secrets, &receiver.CreatedAt, &receiver.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range []struct {
+		raw []byte
+		v   interface{}
+	}{
+		{email, &receiver.EmailConfigs},
+		{webhook, &receiver.WebhookConfigs},
+		{slack, &receiver.SlackConfigs},
+		{pagerDuty, &receiver.PagerDutyConfigs},
+		{opsGenie, &receiver.OpsGenieConfigs},
+		{secrets, &receiver.Secrets},
+	} {
+		if err := json.Unmarshal(field.raw, field.v); err != nil {
+			return nil, err
+		}
+	}
+	fillLegacyConfigIDs(&receiver)
+	return &receiver, nil
+}
+
+// 查询组织的接收者，按名称排序
+func queryAlertmanagerReceivers(q querier, orgID uuid.UUID) ([]models.AlertmanagerReceiver, error) {
+	rows, err := q.Query(`SELECT `+alertmanagerReceiverColumns+` FROM alertmanager_receivers
+		WHERE org_id = $1 ORDER BY name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receivers := []models.AlertmanagerReceiver{}
+	for rows.Next() {
+		receiver, err := scanAlertmanagerReceiver(rows)
+		if err != nil {
+			return nil, err
+		}
+		receivers = append(receivers, *receiver)
+	}
+	return receivers, rows.Err()
+}
+
+// 接收者的各列，集成列表和密钥以JSON保存
+func receiverColumnValues(receiver *models.AlertmanagerReceiver) ([]interface{}, error) {
+	values := []interface{}{}
+	for _, v := range []interface{}{
+		receiver.EmailConfigs, receiver.WebhookConfigs, receiver.SlackConfigs,
+		receiver.PagerDutyConfigs, receiver.OpsGenieConfigs, receiver.Secrets,
+	} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, data)
+	}
+	return values, nil
+}
+
+// 按路径中的ID加载接收者，返回nil时已写入响应
+func loadAlertmanagerReceiver(c *gin.Context, q querier, orgID uuid.UUID, lock bool) *models.AlertmanagerReceiver {
+	receiverUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receiver ID"})
+		return nil
+	}
+
+	query := `SELECT ` + alertmanagerReceiverColumns + ` FROM alertmanager_receivers WHERE id = $1 AND org_id = $2`
+	if lock {
+		query += " FOR UPDATE"
+	}
+	receiver, err := scanAlertmanagerReceiver(q.QueryRow(query, receiverUUID, orgID))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receiver not found"})
+		return nil
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get receiver"})
+		return nil
+	}
+	return receiver
+}
+
+// 把请求应用到接收者上，密钥移入 Secrets：null 沿用同ID配置的原值，空字符串表示清除
+// 请求中的ID必须是该接收者同一集成中原有配置的ID且不能重复，没有ID的配置分配新ID，不会沿用其他配置的密钥
+func applyAlertmanagerReceiver(receiver *models.AlertmanagerReceiver, req models.SaveAlertmanagerReceiverRequest) error {
+	previous := receiver.Secrets
+	previousIDs := receiverConfigIDs(receiver)
+	secrets := map[string]string{}
+	has := func(key string) bool {
+		_, ok := secrets[key]
+		return ok
+	}
+	seen := map[string]bool{}
+	assignID := func(integration string, id *string) error {
+		if *id == "" {
+			*id = uuid.NewString()
+			return nil
+		}
+		key := integration + "." + *id
+		if !previousIDs[key] {
+			return fmt.Errorf("%s: unknown id %q", integration, *id)
+		}
+		if seen[key] {
+			return fmt.Errorf("%s: duplicate id %q", integration, *id)
+		}
+		seen[key] = true
+		return nil
+	}
+
+	receiver.Name = req.Name
+	receiver.EmailConfigs = append([]models.EmailConfig{}, req.EmailConfigs...)
+	receiver.WebhookConfigs = append([]models.WebhookConfig{}, req.WebhookConfigs...)
+	receiver.SlackConfigs = append([]models.SlackConfig{}, req.SlackConfigs...)
+	receiver.PagerDutyConfigs = append([]models.PagerDutyConfig{}, req.PagerDutyConfigs...)
+	receiver.OpsGenieConfigs = append([]models.OpsGenieConfig{}, req.OpsGenieConfigs...)
+
+	for i := range receiver.EmailConfigs {
+		cfg := &receiver.EmailConfigs[i]
+		if err := assignID("email_configs", &cfg.ID); err != nil {
+			return err
+		}
+		key := alertmanager.SecretKey("email_configs", cfg.ID, "auth_password")
+		moveSecret(secrets, previous, key, &cfg.AuthPassword)
+		cfg.HasAuthPassword = has(key)
+	}
+	for i := range receiver.WebhookConfigs {
+		cfg := &receiver.WebhookConfigs[i]
+		if err := assignID("webhook_configs", &cfg.ID); err != nil {
+			return err
+		}
+		key := alertmanager.SecretKey("webhook_configs", cfg.ID, "url")
+		moveSecret(secrets, previous, key, &cfg.URL)
+		cfg.HasURL = has(key)
+	}
+	for i := range receiver.SlackConfigs {
+		cfg := &receiver.SlackConfigs[i]
+		if err := assignID("slack_configs", &cfg.ID); err != nil {
+			return err
+		}
+		key := alertmanager.SecretKey("slack_configs", cfg.ID, "api_url")
+		moveSecret(secrets, previous, key, &cfg.APIURL)
+		cfg.HasAPIURL = has(key)
+	}
+	for i := range receiver.PagerDutyConfigs {
+		cfg := &receiver.PagerDutyConfigs[i]
+		if err := assignID("pagerduty_configs", &cfg.ID); err != nil {
+			return err
+		}
+		routingKey := alertmanager.SecretKey("pagerduty_configs", cfg.ID, "routing_key")
+		serviceKey := alertmanager.SecretKey("pagerduty_configs", cfg.ID, "service_key")
+		moveSecret(secrets, previous, routingKey, &cfg.RoutingKey)
+		moveSecret(secrets, previous, serviceKey, &cfg.ServiceKey)
+		cfg.HasRoutingKey = has(routingKey)
+		cfg.HasServiceKey = has(serviceKey)
+	}
+	for i := range receiver.OpsGenieConfigs {
+		cfg := &receiver.OpsGenieConfigs[i]
+		if err := assignID("opsgenie_configs", &cfg.ID); err != nil {
+			return err
+		}
+		key := alertmanager.SecretKey("opsgenie_configs", cfg.ID, "api_key")
+		moveSecret(secrets, previous, key, &cfg.APIKey)
+		cfg.HasAPIKey = has(key)
+	}
+
+	receiver.Secrets = secrets
+	return nil
+}
+
+// 接收者现有的集成配置ID，键为 "<集成>.<配置ID>"
+func receiverConfigIDs(receiver *models.AlertmanagerReceiver) map[string]bool {
+	ids := map[string]bool{}
+	for _, cfg := range receiver.EmailConfigs {
+		ids["email_configs."+cfg.ID] = true
+	}
+	for _, cfg := range receiver.WebhookConfigs {
+		ids["webhook_configs."+cfg.ID] = true
+	}
+	for _, cfg := range receiver.SlackConfigs {
+		ids["slack_configs."+cfg.ID] = true
+	}
+	for _, cfg := range receiver.PagerDutyConfigs {
+		ids["pagerduty_configs."+cfg.ID] = true
+	}
+	for _, cfg := range receiver.OpsGenieConfigs {
+		ids["opsgenie_configs."+cfg.ID] = true
+	}
+	return ids
+}
+
+// 较早保存的集成配置没有ID，密钥按序号保存，以序号作为ID
+func fillLegacyConfigIDs(receiver *models.AlertmanagerReceiver) {
+	for i := range receiver.EmailConfigs {
+		if receiver.EmailConfigs[i].ID == "" {
+			receiver.EmailConfigs[i].ID = strconv.Itoa(i)
+		}
+	}
+	for i := range receiver.WebhookConfigs {
+		if receiver.WebhookConfigs[i].ID == "" {
+			receiver.WebhookConfigs[i].ID = strconv.Itoa(i)
+		}
+	}
+	for i := range receiver.SlackConfigs {
+		if receiver.SlackConfigs[i].ID == "" {
+			receiver.SlackConfigs[i].ID = strconv.Itoa(i)
+		}
+	}
+	for i := range receiver.PagerDutyConfigs {
+		if receiver.PagerDutyConfigs[i].ID == "" {
+			receiver.PagerDutyConfigs[i].ID = strconv.Itoa(i)
+		}
+	}
+	for i := range receiver.OpsGenieConfigs {
+		if receiver.OpsGenieConfigs[i].ID == "" {
+			receiver.OpsGenieConfigs[i].ID = strconv.Itoa(i)
+		}
+	}
+}
+
+// 用替换或去掉接收者后的完整配置检查，removeID 不为空时去掉该接收者，返回nil时已写入响应
+func checkReceivers(c *gin.Context, q querier, orgID uuid.UUID, receiver *models.AlertmanagerReceiver, removeID uuid.UUID) []models.AlertmanagerReceiver {
+	cfg, err := loadAlertmanagerConfig(q, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Alertmanager config"})
+		return nil
+	}
+
+	receivers := []models.AlertmanagerReceiver{}
+	for _, current := range cfg.Receivers {
+		if current.ID == removeID || (receiver != nil && current.ID == receiver.ID) {
+			continue
+		}
+		receivers = append(receivers, current)
+	}
+	if receiver != nil {
+		receivers = append(receivers, *receiver)
+	}
+	cfg.Receivers = receivers
+
+	if checkErrs := alertmanager.CheckConfig(*cfg); len(checkErrs) > 0 {
+		status := http.StatusUnprocessableEntity
+		if receiver == nil {
+			// 删除后配置无效，通常是路由仍在引用该接收者
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": "Invalid Alertmanager config", "errors": checkErrs})
+		return nil
+	}
+	return receivers
+}
+
+// 名称在组织内不能重复，返回false时已写入响应
+func checkReceiverConflict(c *gin.Context, q querier, receiver *models.AlertmanagerReceiver) bool {
+	var exists bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM alertmanager_receivers WHERE org_id = $1 AND id <> $2 AND name = $3)`,
+		receiver.OrgID, receiver.ID, receiver.Name).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "A receiver with this name already exists"})
+		return false
+	}
+	return true
+}
+
+func (h *Handlers) GetAlertmanagerReceivers(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	receivers, err := queryAlertmanagerReceivers(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get receivers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, receivers)
+}
+
+func (h *Handlers) GetAlertmanagerReceiver(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	receiver := loadAlertmanagerReceiver(c, h.db, orgID, false)
+	if receiver == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, receiver)
+}
+
+func (h *Handlers) CreateAlertmanagerReceiver(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.SaveAlertmanagerReceiverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	receiver := &models.AlertmanagerReceiver{ID: uuid.New(), OrgID: orgID}
+	if err := applyAlertmanagerReceiver(receiver, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkReceiverConflict(c, tx, receiver) || checkReceivers(c, tx, orgID, receiver, uuid.Nil) == nil {
+		return
+	}
+
+	values, err := receiverColumnValues(receiver)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	receiver, err = scanAlertmanagerReceiver(tx.QueryRow(`
+		INSERT INTO alertmanager_receivers (id, org_id, name, email_configs, webhook_configs, slack_configs,
+		                                    pagerduty_configs, opsgenie_configs, secrets)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+alertmanagerReceiverColumns,
+		append([]interface{}{receiver.ID, orgID, receiver.Name}, values...)...))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create receiver"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditCreate, "alertmanager_receiver", receiver.ID, nil, receiver); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create receiver"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, receiver)
+}
+
+// 更新接收者，改名时路由中的引用需要另行修改，否则检查不通过
+func (h *Handlers) UpdateAlertmanagerReceiver(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.SaveAlertmanagerReceiverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before := loadAlertmanagerReceiver(c, tx, orgID, true)
+	if before == nil {
+		return
+	}
+
+	receiver := *before
+	if err := applyAlertmanagerReceiver(&receiver, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkReceiverConflict(c, tx, &receiver) || checkReceivers(c, tx, orgID, &receiver, uuid.Nil) == nil {
+		return
+	}
+
+	values, err := receiverColumnValues(&receiver)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	after, err := scanAlertmanagerReceiver(tx.QueryRow(`
+		UPDATE alertmanager_receivers
+		SET name = $1, email_configs = $2, webhook_configs = $3, slack_configs = $4,
+		    pagerduty_configs = $5, opsgenie_configs = $6, secrets = $7
+		WHERE id = $8 AND org_id = $9
+		RETURNING `+alertmanagerReceiverColumns,
+		append(append([]interface{}{receiver.Name}, values...), receiver.ID, orgID)...))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update receiver"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditUpdate, "alertmanager_receiver", after.ID, before, after); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update receiver"})
+		return
+	}
+
+	c.JSON(http.StatusOK, after)
+}
+
+// 删除接收者，路由仍在引用时返回409
+func (h *Handlers) DeleteAlertmanagerReceiver(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before := loadAlertmanagerReceiver(c, tx, orgID, true)
+	if before == nil {
+		return
+	}
+	if checkReceivers(c, tx, orgID, nil, before.ID) == nil {
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM alertmanager_receivers WHERE id = $1 AND org_id = $2", before.ID, orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete receiver"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditDelete, "alertmanager_receiver", before.ID, before, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete receiver"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Receiver deleted successfully"})
+}
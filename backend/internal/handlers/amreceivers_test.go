@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"promeconfig-backend/internal/models"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestApplyAlertmanagerReceiver(t *testing.T) {
+	// 较早保存的接收者，密钥按序号保存
+	saved := func() *models.AlertmanagerReceiver {
+		receiver := &models.AlertmanagerReceiver{
+			Name:           "ops",
+			WebhookConfigs: []models.WebhookConfig{{}, {}},
+			Secrets: map[string]string{
+				"webhook_configs.0.url": "https://a.example.com/hook?token=a",
+				"webhook_configs.1.url": "https://b.example.com/hook?token=b",
+			},
+		}
+		fillLegacyConfigIDs(receiver)
+		return receiver
+	}
+
+	t.Run("secrets follow the id when reordered", func(t *testing.T) {
+		receiver := saved()
+		err := applyAlertmanagerReceiver(receiver, models.SaveAlertmanagerReceiverRequest{
+			Name:           "ops",
+			WebhookConfigs: []models.WebhookConfig{{ID: "1"}, {ID: "0"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{
+			"webhook_configs.1.url": "https://b.example.com/hook?token=b",
+			"webhook_configs.0.url": "https://a.example.com/hook?token=a",
+		}
+		if !reflect.DeepEqual(receiver.Secrets, want) {
+			t.Errorf("secrets = %v, want %v", receiver.Secrets, want)
+		}
+		if receiver.WebhookConfigs[0].ID != "1" || !receiver.WebhookConfigs[0].HasURL || receiver.WebhookConfigs[0].URL != nil {
+			t.Errorf("unexpected config %+v", receiver.WebhookConfigs[0])
+		}
+	})
+
+	t.Run("deleted entry does not leak its secret", func(t *testing.T) {
+		receiver := saved()
+		err := applyAlertmanagerReceiver(receiver, models.SaveAlertmanagerReceiverRequest{
+			Name:           "ops",
+			WebhookConfigs: []models.WebhookConfig{{ID: "1"}, {}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 新配置分配新ID，null 不沿用已删除配置的密钥
+		added := receiver.WebhookConfigs[1]
+		if added.ID == "" || added.ID == "0" || added.HasURL {
+			t.Errorf("unexpected new config %+v", added)
+		}
+		want := map[string]string{"webhook_configs.1.url": "https://b.example.com/hook?token=b"}
+		if !reflect.DeepEqual(receiver.Secrets, want) {
+			t.Errorf("secrets = %v, want %v", receiver.Secrets, want)
+		}
+	})
+
+	t.Run("new and cleared secrets", func(t *testing.T) {
+		receiver := saved()
+		err := applyAlertmanagerReceiver(receiver, models.SaveAlertmanagerReceiverRequest{
+			Name:           "ops",
+			WebhookConfigs: []models.WebhookConfig{{ID: "0", URL: strPtr("")}, {ID: "1", URL: strPtr("https://c.example.com")}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"webhook_configs.1.url": "https://c.example.com"}
+		if !reflect.DeepEqual(receiver.Secrets, want) {
+			t.Errorf("secrets = %v, want %v", receiver.Secrets, want)
+		}
+	})
+
+	for _, tt := range []struct {
+		name string
+		req  models.SaveAlertmanagerReceiverRequest
+	}{
+		{"unknown id", models.SaveAlertmanagerReceiverRequest{WebhookConfigs: []models.WebhookConfig{{ID: "2"}}}},
+		{"duplicate id", models.SaveAlertmanagerReceiverRequest{WebhookConfigs: []models.WebhookConfig{{ID: "0"}, {ID: "0"}}}},
+		{"id of another integration", models.SaveAlertmanagerReceiverRequest{SlackConfigs: []models.SlackConfig{{ID: "0"}}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := applyAlertmanagerReceiver(saved(), tt.req); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
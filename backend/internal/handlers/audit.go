@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 审计日志中的操作类型
+const (
+	auditCreate       = "create"
+	auditUpdate       = "update"
+	auditDelete       = "delete"
+	auditSignIn       = "signin"
+	auditSignInFailed = "signin_failed"
+	auditSync         = "sync"
+	auditReload       = "reload"
+	auditRollback     = "rollback"
+	auditSubmit       = "submit"
+	auditApprove      = "approve"
+	auditReject       = "reject"
+	auditApply        = "apply"
+	auditExpire       = "expire"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type auditEntry struct {
+	// ActorID 为空时使用当前登录用户
+	ActorID    uuid.UUID
+	ActorEmail string
+	Action     string
+	EntityType string
+	EntityID   string
+	Before     interface{}
+	After      interface{}
+}
+
+// 写入一条审计日志，应与被记录的修改在同一事务中执行
+func recordAudit(q querier, c *gin.Context, entry auditEntry) error {
+	if entry.ActorID == uuid.Nil {
+		entry.ActorID, _ = middleware.GetUserID(c)
+	}
+
+	var orgID interface{}
+	if id, ok := middleware.GetOrgID(c); ok {
+		orgID = id
+	}
+
+	var actorID interface{}
+	if entry.ActorID != uuid.Nil {
+		actorID = entry.ActorID
+	}
+
+	before, err := marshalAuditValue(entry.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshalAuditValue(entry.After)
+	if err != nil {
+		return err
+	}
+
+	// 同时记录邮箱，用户删除后日志仍可读
+	_, err = q.Exec(`
+		INSERT INTO audit_log (org_id, actor_id, actor_email, ip, action, entity_type, entity_id, before, after)
+		VALUES ($1, $2, COALESCE(NULLIF($3, ''), (SELECT email FROM users WHERE id = $2), ''), $4, $5, $6, $7, $8, $9)`,
+		orgID, actorID, entry.ActorEmail, c.ClientIP(), entry.Action, entry.EntityType, entry.EntityID, before, after)
+	return err
+}
+
+// 记录实体的增删改
+func recordChange(q querier, c *gin.Context, action, entityType string, entityID uuid.UUID, before, after interface{}) error {
+	return recordAudit(q, c, auditEntry{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID.String(),
+		Before:     before,
+		After:      after,
+	})
+}
+
+func marshalAuditValue(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// 查询审计日志
+// 支持按 entity_type、entity_id、actor（用户ID或邮箱）、since/until（RFC3339）过滤，format=jsonl 时按行导出
+func (h *Handlers) GetAuditLog(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermAuditRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	userID, _ := middleware.GetUserID(c)
+
+	// 不属于任何组织的记录（登录、AI设置）只有操作人本人可见
+	conditions := []string{`(a.org_id = $1 OR (a.org_id IS NULL AND a.actor_id = $2))`}
+	args := []interface{}{orgID, userID}
+
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if entityType := c.Query("entity_type"); entityType != "" {
+		addCondition("a.entity_type = $%d", entityType)
+	}
+	if entityID := c.Query("entity_id"); entityID != "" {
+		addCondition("a.entity_id = $%d", entityID)
+	}
+	if action := c.Query("action"); action != "" {
+		addCondition("a.action = $%d", action)
+	}
+	if actor := c.Query("actor"); actor != "" {
+		if actorID, err := uuid.Parse(actor); err == nil {
+			addCondition("a.actor_id = $%d", actorID)
+		} else {
+			addCondition("a.actor_email = $%d", actor)
+		}
+	}
+	for param, op := range map[string]string{"since": ">=", "until": "<"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s, expected RFC3339 time", param)})
+			return
+		}
+		addCondition("a.created_at "+op+" $%d", t)
+	}
+
+	limit := defaultAuditLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(n, maxAuditLimit)
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	jsonl := c.Query("format") == "jsonl"
+	query := `
+		SELECT a.id, a.org_id, a.actor_id, a.actor_email, a.ip, a.action, a.entity_type, a.entity_id,
+		       a.before, a.after, a.created_at
+		FROM audit_log a WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY a.created_at DESC, a.id DESC`
+	// 导出时不分页
+	if !jsonl {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, max(offset, 0))
+	}
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit log"})
+		return
+	}
+	defer rows.Close()
+
+	if jsonl {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		c.Status(http.StatusOK)
+	}
+
+	entries := []models.AuditLogEntry{}
+	enc := json.NewEncoder(c.Writer)
+	for rows.Next() {
+		var entry models.AuditLogEntry
+		var before, after []byte
+		if err := rows.Scan(&entry.ID, &entry.OrgID, &entry.ActorID, &entry.ActorEmail, &entry.IP,
+			&entry.Action, &entry.EntityType, &entry.EntityID, &before, &after, &entry.CreatedAt); err != nil {
+			if !jsonl {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan audit log"})
+			}
+			return
+		}
+		entry.Before = before
+		entry.After = after
+
+		if jsonl {
+			// 逐行写出，避免大量记录占用内存
+			if err := enc.Encode(entry); err != nil {
+				return
+			}
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if !jsonl {
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+// 记录登录结果，登录不在事务中，写入失败只打印日志不影响登录
+func (h *Handlers) recordSignIn(c *gin.Context, userID uuid.UUID, email, provider string, success bool) {
+	entry := auditEntry{
+		ActorID:    userID,
+		ActorEmail: email,
+		Action:     auditSignIn,
+		EntityType: "user",
+		After:      gin.H{"provider": provider},
+	}
+	if userID != uuid.Nil {
+		entry.EntityID = userID.String()
+	}
+	if !success {
+		entry.Action = auditSignInFailed
+	}
+
+	if err := recordAudit(h.db, c, entry); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+	}
+}
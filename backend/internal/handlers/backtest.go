@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/common/model"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+const (
+	defaultBacktestRange = 7 * 24 * time.Hour
+	maxBacktestRange     = 30 * 24 * time.Hour
+	defaultBacktestStep  = time.Minute
+	// Prometheus 单个序列最多返回11000个点
+	maxBacktestPoints = 11000
+)
+
+// 查询Prometheus失败时的响应，表达式错误返回400，其余返回502
+func writePrometheusError(c *gin.Context, err error) {
+	var apiErr *prometheus.APIError
+	if errors.As(err, &apiErr) && apiErr.Type == "bad_data" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": apiErr.Message})
+		return
+	}
+
+	c.JSON(http.StatusBadGateway, gin.H{"error": "Prometheus query failed: " + err.Error()})
+}
+
+func parseQueryDuration(c *gin.Context, name string, fallback time.Duration) (time.Duration, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, true
+	}
+	d, err := model.ParseDuration(value)
+	if err != nil || d <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+		return 0, false
+	}
+	return time.Duration(d), true
+}
+
+// 解析回测的区间和step，区间较长时放大step
+func backtestWindow(c *gin.Context) (time.Duration, time.Duration, bool) {
+	queryRange, ok := parseQueryDuration(c, "range", defaultBacktestRange)
+	if !ok {
+		return 0, 0, false
+	}
+	if queryRange > maxBacktestRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Backtest range must not exceed 30d"})
+		return 0, 0, false
+	}
+
+	step, ok := parseQueryDuration(c, "step", defaultBacktestStep)
+	if !ok {
+		return 0, 0, false
+	}
+	// 区间较长时放大step，避免超过Prometheus的点数限制
+	if queryRange/step > maxBacktestPoints {
+		step = (queryRange / maxBacktestPoints).Truncate(time.Second) + time.Second
+	}
+	return queryRange, step, true
+}
+
+// 在历史数据上回测告警规则，返回按 for 语义会触发的时间段
+// 请求体可以覆盖 expr 和 for_duration，用于上线前评估新阈值
+func (h *Handlers) BacktestAlertRule(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	// instance_id 参数指定回测使用的实例
+	client := h.prometheusClient(c, orgID)
+	if client == nil {
+		return
+	}
+
+	ruleUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var req models.BacktestRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	queryRange, step, ok := backtestWindow(c)
+	if !ok {
+		return
+	}
+
+	rule, err := getAlertRule(h.db, orgID, ruleUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rule"})
+		return
+	}
+
+	runBacktest(c, client, rule, req, queryRange, step)
+}
+
+// 用请求中的覆盖值回测规则并返回结果
+func runBacktest(c *gin.Context, client *prometheus.Client, rule *models.AlertRule, req models.BacktestRequest, queryRange, step time.Duration) {
+	if req.Expr != "" {
+		rule.Expr = req.Expr
+	}
+	if req.ForDuration != nil {
+		rule.ForDuration = *req.ForDuration
+	}
+
+	var forDuration time.Duration
+	if rule.ForDuration != "" {
+		d, err := model.ParseDuration(rule.ForDuration)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid for_duration"})
+			return
+		}
+		forDuration = time.Duration(d)
+	}
+
+	var ruleLabels map[string]string
+	if len(rule.Labels) > 0 && string(rule.Labels) != "null" {
+		if err := json.Unmarshal(rule.Labels, &ruleLabels); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid alert rule labels"})
+			return
+		}
+	}
+
+	end := time.Now().UTC().Truncate(step)
+	start := end.Add(-queryRange)
+
+	matrix, err := client.QueryRange(c.Request.Context(), rule.Expr, start, end, step)
+	if err != nil {
+		writePrometheusError(c, err)
+		return
+	}
+
+	result := models.BacktestResult{
+		RuleID:      rule.ID,
+		AlertName:   rule.AlertName,
+		Expr:        rule.Expr,
+		ForDuration: rule.ForDuration,
+		Start:       start,
+		End:         end,
+		Step:        model.Duration(step).String(),
+		Series:      prometheus.Backtest(matrix, rule.AlertName, ruleLabels, forDuration, step, end),
+	}
+	for _, series := range result.Series {
+		result.TotalFirings += series.Count
+	}
+	result.FiringsPerDay = float64(result.TotalFirings) / (queryRange.Hours() / 24)
+
+	c.JSON(http.StatusOK, result)
+}
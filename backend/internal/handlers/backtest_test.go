@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+)
+
+func TestBacktestWindow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantRange  time.Duration
+		wantStep   time.Duration
+		wantStatus int
+	}{
+		{name: "defaults", wantRange: 7 * 24 * time.Hour, wantStep: time.Minute},
+		{name: "short range keeps step", query: "?range=1h&step=15s", wantRange: time.Hour, wantStep: 15 * time.Second},
+		{name: "long range widens step", query: "?range=30d&step=1m", wantRange: 30 * 24 * time.Hour, wantStep: 236 * time.Second},
+		{name: "range over 30d", query: "?range=31d", wantStatus: http.StatusBadRequest},
+		{name: "invalid range", query: "?range=week", wantStatus: http.StatusBadRequest},
+		{name: "zero step", query: "?step=0s", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/alert-rules/id/backtest"+tt.query, nil)
+
+			queryRange, step, ok := backtestWindow(c)
+			if tt.wantStatus != 0 {
+				if ok || w.Code != tt.wantStatus {
+					t.Errorf("ok = %v, status = %d, want status %d", ok, w.Code, tt.wantStatus)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("unexpected error response: %s", w.Body.String())
+			}
+			if queryRange != tt.wantRange || step != tt.wantStep {
+				t.Errorf("range, step = %v, %v, want %v, %v", queryRange, step, tt.wantRange, tt.wantStep)
+			}
+			if queryRange/step > maxBacktestPoints {
+				t.Errorf("%d points exceed the limit of %d", queryRange/step, maxBacktestPoints)
+			}
+		})
+	}
+}
+
+func TestRunBacktest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 两个点都为1，间隔一个step，for 不超过一个step时触发
+	matrix := `{"status": "success", "data": {"resultType": "matrix", "result": [
+		{"metric": {"instance": "a"}, "values": [[%d, "1"], [%d, "1"]]}
+	]}}`
+
+	tests := []struct {
+		name        string
+		req         models.BacktestRequest
+		status      int
+		body        string
+		wantStatus  int
+		wantError   string
+		wantExpr    string
+		wantFor     string
+		wantFirings int
+	}{
+		{
+			name:        "saved rule",
+			status:      http.StatusOK,
+			body:        matrix,
+			wantStatus:  http.StatusOK,
+			wantExpr:    "up == 0",
+			wantFor:     "1m",
+			wantFirings: 1,
+		},
+		{
+			name:       "expr and for_duration override",
+			req:        models.BacktestRequest{Expr: "up < 1", ForDuration: strPtr("10m")},
+			status:     http.StatusOK,
+			body:       matrix,
+			wantStatus: http.StatusOK,
+			wantExpr:   "up < 1",
+			wantFor:    "10m",
+		},
+		{
+			name:        "empty for_duration override",
+			req:         models.BacktestRequest{ForDuration: strPtr("")},
+			status:      http.StatusOK,
+			body:        matrix,
+			wantStatus:  http.StatusOK,
+			wantExpr:    "up == 0",
+			wantFor:     "",
+			wantFirings: 1,
+		},
+		{
+			name:       "invalid for_duration override",
+			req:        models.BacktestRequest{ForDuration: strPtr("soon")},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "Invalid for_duration",
+		},
+		{
+			name:       "bad expression",
+			req:        models.BacktestRequest{Expr: "up =="},
+			status:     http.StatusBadRequest,
+			body:       `{"status": "error", "errorType": "bad_data", "error": "parse error: unexpected end of input"}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "parse error: unexpected end of input",
+		},
+		{
+			name:       "Prometheus unavailable",
+			status:     http.StatusServiceUnavailable,
+			body:       `{"status": "error", "errorType": "unavailable", "error": "shutting down"}`,
+			wantStatus: http.StatusBadGateway,
+			wantError:  "Prometheus query failed: prometheus unavailable: shutting down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotQuery, gotStep string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotQuery, gotStep = r.FormValue("query"), r.FormValue("step")
+				body := tt.body
+				if body == matrix {
+					end := time.Now().UTC().Truncate(time.Minute).Unix()
+					body = fmt.Sprintf(matrix, end-120, end-60)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(body))
+			}))
+			defer server.Close()
+			client, err := prometheus.NewClient(prometheus.ClientConfig{URL: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rule := &models.AlertRule{ID: uuid.New(), AlertName: "InstanceDown", Expr: "up == 0", ForDuration: "1m"}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/alert-rules/id/backtest", nil)
+			runBacktest(c, client, rule, tt.req, time.Hour, time.Minute)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantError != "" {
+				var body map[string]string
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if body["error"] != tt.wantError {
+					t.Errorf("error = %q, want %q", body["error"], tt.wantError)
+				}
+				return
+			}
+
+			var result models.BacktestResult
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if gotQuery != tt.wantExpr || result.Expr != tt.wantExpr {
+				t.Errorf("queried %q, result expr %q, want %q", gotQuery, result.Expr, tt.wantExpr)
+			}
+			if gotStep != "60" || result.Step != "1m" {
+				t.Errorf("step = %q (result %q), want 60 (1m)", gotStep, result.Step)
+			}
+			if result.ForDuration != tt.wantFor {
+				t.Errorf("for_duration = %q, want %q", result.ForDuration, tt.wantFor)
+			}
+			if result.TotalFirings != tt.wantFirings {
+				t.Errorf("total firings = %d, want %d", result.TotalFirings, tt.wantFirings)
+			}
+			if result.End.Sub(result.Start) != time.Hour {
+				t.Errorf("window = %v..%v, want one hour", result.Start, result.End)
+			}
+		})
+	}
+}
@@ -0,0 +1,1084 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 变更集状态
+const (
+	changeSetDraft     = "draft"
+	changeSetSubmitted = "submitted"
+	changeSetApproved  = "approved"
+	changeSetApplied   = "applied"
+	changeSetRejected  = "rejected"
+)
+
+// 变更与当前配置冲突，例如要修改的target已被删除
+var errChangeConflict = errors.New("change conflicts with current configuration")
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// 组织是否要求配置修改必须经过审批
+func requiresApproval(q querier, orgID uuid.UUID) (bool, error) {
+	var required bool
+	err := q.QueryRow("SELECT require_approval FROM organizations WHERE id = $1", orgID).Scan(&required)
+	return required, err
+}
+
+// 组织开启审批后不允许直接修改会写入Prometheus配置的内容，返回false时已写入响应
+func (h *Handlers) allowDirectChange(c *gin.Context, orgID uuid.UUID) bool {
+	required, err := requiresApproval(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization requires approved change sets, direct changes are disabled"})
+		return false
+	}
+	return true
+}
+
+const changeSetColumns = `
+	cs.id, cs.org_id, cs.author_id, COALESCE(a.email, ''), cs.title, cs.description, cs.status,
+	cs.reviewer_id, COALESCE(r.email, ''), cs.reviewed_at, cs.applied_revision_id, cs.applied_at,
+	cs.created_at, cs.updated_at
+	FROM change_sets cs
+	LEFT JOIN users a ON a.id = cs.author_id
+	LEFT JOIN users r ON r.id = cs.reviewer_id`
+
+func scanChangeSet(row rowScanner) (*models.ChangeSet, error) {
+	var cs models.ChangeSet
+	err := row.Scan(&cs.ID, &cs.OrgID, &cs.AuthorID, &cs.AuthorEmail, &cs.Title, &cs.Description, &cs.Status,
+		&cs.ReviewerID, &cs.ReviewerEmail, &cs.ReviewedAt, &cs.AppliedRevisionID, &cs.AppliedAt,
+		&cs.CreatedAt, &cs.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &cs, nil
+}
+
+// 查询单个变更集，lock 为true时加行锁，防止并发修改状态
+func getChangeSet(q querier, orgID, changeSetID uuid.UUID, lock bool) (*models.ChangeSet, error) {
+	query := "SELECT " + changeSetColumns + " WHERE cs.id = $1 AND cs.org_id = $2"
+	if lock {
+		query += " FOR UPDATE OF cs"
+	}
+	return scanChangeSet(q.QueryRow(query, changeSetID, orgID))
+}
+
+// 按添加顺序查询变更项，应用时也按该顺序执行
+func queryChangeSetItems(q querier, changeSetID uuid.UUID) ([]models.ChangeSetItem, error) {
+	rows, err := q.Query(`
+		SELECT id, change_set_id, entity_type, action, entity_id, payload, created_at
+		FROM change_set_items WHERE change_set_id = $1 ORDER BY created_at, id`, changeSetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ChangeSetItem{}
+	for rows.Next() {
+		var item models.ChangeSetItem
+		var payload []byte
+		if err := rows.Scan(&item.ID, &item.ChangeSetID, &item.EntityType, &item.Action,
+			&item.EntityID, &payload, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		item.Payload = payload
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func queryChangeSetComments(q querier, changeSetID uuid.UUID) ([]models.ChangeSetComment, error) {
+	rows, err := q.Query(`
+		SELECT cm.id, cm.change_set_id, cm.author_id, COALESCE(u.email, ''), cm.body, cm.created_at
+		FROM change_set_comments cm LEFT JOIN users u ON u.id = cm.author_id
+		WHERE cm.change_set_id = $1 ORDER BY cm.created_at, cm.id`, changeSetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.ChangeSetComment{}
+	for rows.Next() {
+		var comment models.ChangeSetComment
+		if err := rows.Scan(&comment.ID, &comment.ChangeSetID, &comment.AuthorID, &comment.AuthorEmail,
+			&comment.Body, &comment.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+func insertChangeSetComment(q querier, changeSetID, authorID uuid.UUID, body string) (*models.ChangeSetComment, error) {
+	var comment models.ChangeSetComment
+	err := q.QueryRow(`
+		INSERT INTO change_set_comments (change_set_id, author_id, body) VALUES ($1, $2, $3)
+		RETURNING id, change_set_id, author_id, COALESCE((SELECT email FROM users WHERE id = $2), ''), body, created_at`,
+		changeSetID, authorID, body).Scan(
+		&comment.ID, &comment.ChangeSetID, &comment.AuthorID, &comment.AuthorEmail, &comment.Body, &comment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// target未填写时的默认值
+func applyTargetDefaults(req *models.CreateTargetRequest) {
+	if req.ScrapeInterval == "" {
+		req.ScrapeInterval = "15s"
+	}
+	if req.MetricsPath == "" {
+		req.MetricsPath = "/metrics"
+	}
+}
+
+func decodeTargetPayload(payload json.RawMessage) (models.CreateTargetRequest, error) {
+	var req models.CreateTargetRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return req, err
+	}
+	return req, binding.Validator.ValidateStruct(&req)
+}
+
+func decodeAlertRulePayload(payload json.RawMessage) (models.CreateAlertRuleRequest, error) {
+	var req models.CreateAlertRuleRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return req, err
+	}
+	if req.GroupName == "" {
+		req.GroupName = prometheus.DefaultRuleGroup
+	}
+	return req, binding.Validator.ValidateStruct(&req)
+}
+
+// 在快照上依次应用变更项，得到变更集应用后的配置，用于预览diff
+func applyItemsToSnapshot(snapshot models.ConfigSnapshot, items []models.ChangeSetItem) (models.ConfigSnapshot, error) {
+	targets := append([]models.Target{}, snapshot.Targets...)
+	rules := append([]models.AlertRule{}, snapshot.AlertRules...)
+
+	for _, item := range items {
+		switch item.EntityType {
+		case "target":
+			idx := -1
+			for i := range targets {
+				if item.EntityID != nil && targets[i].ID == *item.EntityID {
+					idx = i
+				}
+			}
+			if item.Action != "create" && idx < 0 {
+				return snapshot, fmt.Errorf("%w: target %s not found", errChangeConflict, item.EntityID)
+			}
+
+			if item.Action == "delete" {
+				targets = append(targets[:idx], targets[idx+1:]...)
+				continue
+			}
+
+			req, err := decodeTargetPayload(item.Payload)
+			if err != nil {
+				return snapshot, fmt.Errorf("invalid target payload: %w", err)
+			}
+			target := models.Target{ID: item.ID}
+			if item.Action == "create" {
+				applyTargetDefaults(&req)
+			} else {
+				target = targets[idx]
+			}
+			target.JobName = req.JobName
+			target.Targets = req.Targets
+			target.ScrapeInterval = req.ScrapeInterval
+			target.MetricsPath = req.MetricsPath
+			target.RelabelConfigs = req.RelabelConfigs
+			target.MetricRelabelConfigs = req.MetricRelabelConfigs
+			target.InstanceIDs = req.InstanceIDs
+
+			// 新建的排在最前，与查询时按创建时间倒序一致
+			if item.Action == "create" {
+				targets = append([]models.Target{target}, targets...)
+			} else {
+				targets[idx] = target
+			}
+
+		case "alert_rule":
+			idx := -1
+			for i := range rules {
+				if item.EntityID != nil && rules[i].ID == *item.EntityID {
+					idx = i
+				}
+			}
+			if item.Action != "create" && idx < 0 {
+				return snapshot, fmt.Errorf("%w: alert rule %s not found", errChangeConflict, item.EntityID)
+			}
+
+			if item.Action == "delete" {
+				rules = append(rules[:idx], rules[idx+1:]...)
+				continue
+			}
+
+			req, err := decodeAlertRulePayload(item.Payload)
+			if err != nil {
+				return snapshot, fmt.Errorf("invalid alert rule payload: %w", err)
+			}
+			rule := models.AlertRule{ID: item.ID}
+			if item.Action != "create" {
+				rule = rules[idx]
+			}
+			rule.AlertName = req.AlertName
+			rule.GroupName = req.GroupName
+			rule.Expr = req.Expr
+			rule.ForDuration = req.ForDuration
+			rule.Labels = req.Labels
+			rule.Annotations = req.Annotations
+
+			if item.Action == "create" {
+				rules = append([]models.AlertRule{rule}, rules...)
+			} else {
+				rules[idx] = rule
+			}
+		}
+	}
+
+	snapshot.Targets = targets
+	snapshot.AlertRules = rules
+	return snapshot, nil
+}
+
+// 在事务中把变更集写入targets和告警规则，每项修改都记录审计日志
+// 新建的配置归属到变更集作者，作者已删除时归属到执行同步的用户
+func applyChangeSet(tx *sql.Tx, c *gin.Context, orgID, actorID uuid.UUID, cs *models.ChangeSet, items []models.ChangeSetItem) error {
+	ownerID := cs.AuthorID
+	if ownerID == uuid.Nil {
+		ownerID = actorID
+	}
+
+	for _, item := range items {
+		var err error
+		switch item.EntityType {
+		case "target":
+			err = applyTargetItem(tx, c, orgID, ownerID, item)
+		case "alert_rule":
+			err = applyAlertRuleItem(tx, c, orgID, ownerID, item)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyTargetItem(tx *sql.Tx, c *gin.Context, orgID, ownerID uuid.UUID, item models.ChangeSetItem) error {
+	if item.Action == "create" {
+		req, err := decodeTargetPayload(item.Payload)
+		if err != nil {
+			return err
+		}
+		applyTargetDefaults(&req)
+		target, err := insertTarget(tx, orgID, ownerID, req)
+		if err != nil {
+			return err
+		}
+		return recordChange(tx, c, auditCreate, "target", target.ID, nil, target)
+	}
+
+	before, err := getTargetForUpdate(tx, orgID, *item.EntityID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: target %s no longer exists", errChangeConflict, item.EntityID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if item.Action == "delete" {
+		if _, err := tx.Exec("DELETE FROM targets WHERE id = $1 AND org_id = $2", before.ID, orgID); err != nil {
+			return err
+		}
+		return recordChange(tx, c, auditDelete, "target", before.ID, before, nil)
+	}
+
+	req, err := decodeTargetPayload(item.Payload)
+	if err != nil {
+		return err
+	}
+	target, err := updateTarget(tx, orgID, before.ID, req)
+	if err != nil {
+		return err
+	}
+	return recordChange(tx, c, auditUpdate, "target", target.ID, before, target)
+}
+
+func applyAlertRuleItem(tx *sql.Tx, c *gin.Context, orgID, ownerID uuid.UUID, item models.ChangeSetItem) error {
+	if item.Action == "create" {
+		req, err := decodeAlertRulePayload(item.Payload)
+		if err != nil {
+			return err
+		}
+		rule, err := insertAlertRule(tx, orgID, ownerID, req)
+		if err != nil {
+			return err
+		}
+		return recordChange(tx, c, auditCreate, "alert_rule", rule.ID, nil, rule)
+	}
+
+	before, err := getAlertRuleForUpdate(tx, orgID, *item.EntityID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: alert rule %s no longer exists", errChangeConflict, item.EntityID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if item.Action == "delete" {
+		if _, err := tx.Exec("DELETE FROM alert_rules WHERE id = $1 AND org_id = $2", before.ID, orgID); err != nil {
+			return err
+		}
+		return recordChange(tx, c, auditDelete, "alert_rule", before.ID, before, nil)
+	}
+
+	req, err := decodeAlertRulePayload(item.Payload)
+	if err != nil {
+		return err
+	}
+	rule, err := updateAlertRule(tx, orgID, before.ID, req)
+	if err != nil {
+		return err
+	}
+	return recordChange(tx, c, auditUpdate, "alert_rule", rule.ID, before, rule)
+}
+
+// 单个实例在变更集应用前后的渲染结果
+type instancePreview struct {
+	instance models.PrometheusInstance
+	current  *renderedConfig
+	proposed *renderedConfig
+}
+
+// 单个实例的变更集diff
+type instanceDiff struct {
+	InstanceID *uuid.UUID `json:"instance_id"`
+	Name       string     `json:"name"`
+	Diff       string     `json:"diff"`
+}
+
+// 按实例渲染变更集应用到当前配置前后的结果，与同步一样只包含分配到实例的配置
+func (h *Handlers) previewChangeSet(q querier, orgID uuid.UUID, items []models.ChangeSetItem) ([]instancePreview, error) {
+	snapshot, err := loadSnapshot(q, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	proposedSnapshot, err := applyItemsToSnapshot(snapshot, items)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := h.orgInstances(q, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	previews := make([]instancePreview, len(instances))
+	for i := range instances {
+		preview := &previews[i]
+		preview.instance = instances[i]
+		if preview.current, err = renderSnapshot(filterSnapshot(snapshot, &instances[i])); err != nil {
+			return nil, fmt.Errorf("instance %s: %w", instances[i].Name, err)
+		}
+		if preview.proposed, err = renderSnapshot(filterSnapshot(proposedSnapshot, &instances[i])); err != nil {
+			return nil, fmt.Errorf("instance %s: %w", instances[i].Name, err)
+		}
+	}
+	return previews, nil
+}
+
+// 加载并锁定变更集，只有作者可以在草稿状态下修改，返回nil时已写入响应
+func loadDraftChangeSet(c *gin.Context, tx *sql.Tx, orgID, userID uuid.UUID) *models.ChangeSet {
+	changeSetUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change set ID"})
+		return nil
+	}
+
+	cs, err := getChangeSet(tx, orgID, changeSetUUID, true)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Change set not found"})
+		return nil
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set"})
+		return nil
+	}
+
+	if cs.AuthorID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can modify this change set"})
+		return nil
+	}
+
+	if cs.Status != changeSetDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Change set is %s, only drafts can be modified", cs.Status)})
+		return nil
+	}
+
+	return cs
+}
+
+func (h *Handlers) GetChangeSets(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	query := "SELECT " + changeSetColumns + " WHERE cs.org_id = $1"
+	args := []interface{}{orgID}
+	if status := c.Query("status"); status != "" {
+		query += " AND cs.status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY cs.created_at DESC, cs.id"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change sets"})
+		return
+	}
+	defer rows.Close()
+
+	changeSets := []models.ChangeSet{}
+	for rows.Next() {
+		cs, err := scanChangeSet(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan change set"})
+			return
+		}
+		changeSets = append(changeSets, *cs)
+	}
+
+	c.JSON(http.StatusOK, changeSets)
+}
+
+func (h *Handlers) CreateChangeSet(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	// 能修改targets或告警规则的成员都可以创建变更集
+	role, _ := middleware.GetRole(c)
+	if !role.Can(rbac.PermRulesWrite) && !middleware.Authorize(c, rbac.PermTargetsWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.CreateChangeSetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var changeSetID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO change_sets (org_id, author_id, title, description)
+		VALUES ($1, $2, $3, $4) RETURNING id`, orgID, userID, req.Title, req.Description).Scan(&changeSetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create change set"})
+		return
+	}
+
+	cs, err := getChangeSet(tx, orgID, changeSetID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create change set"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditCreate, "change_set", cs.ID, nil, cs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create change set"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, cs)
+}
+
+// 获取变更集详情，包含变更项和评论
+func (h *Handlers) GetChangeSet(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	changeSetUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change set ID"})
+		return
+	}
+
+	cs, err := getChangeSet(h.db, orgID, changeSetUUID, false)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Change set not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set"})
+		return
+	}
+
+	if cs.Items, err = queryChangeSetItems(h.db, cs.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set items"})
+		return
+	}
+	if cs.Comments, err = queryChangeSetComments(h.db, cs.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set comments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cs)
+}
+
+// 删除草稿或已拒绝的变更集，只有作者可以删除
+func (h *Handlers) DeleteChangeSet(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	changeSetUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change set ID"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	cs, err := getChangeSet(tx, orgID, changeSetUUID, true)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Change set not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set"})
+		return
+	}
+
+	if cs.AuthorID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can delete this change set"})
+		return
+	}
+
+	if cs.Status != changeSetDraft && cs.Status != changeSetRejected {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Change set is %s and cannot be deleted", cs.Status)})
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM change_sets WHERE id = $1", cs.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete change set"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditDelete, "change_set", cs.ID, cs, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete change set"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Change set deleted successfully"})
+}
+
+// 向草稿变更集添加一项修改
+func (h *Handlers) AddChangeSetItem(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.ChangeSetItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	perm := rbac.PermTargetsWrite
+	if req.EntityType == "alert_rule" {
+		perm = rbac.PermRulesWrite
+	}
+	if !middleware.Authorize(c, perm) {
+		return
+	}
+
+	if req.Action == "create" && req.EntityID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "entity_id must be empty when creating"})
+		return
+	}
+	if req.Action != "create" && req.EntityID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "entity_id is required"})
+		return
+	}
+
+	// 校验请求体并统一格式，删除时不需要请求体
+	var payload interface{}
+	switch {
+	case req.Action == "delete":
+		payload = nil
+	case req.EntityType == "target":
+		target, err := decodeTargetPayload(req.Payload)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload: " + err.Error()})
+			return
+		}
+		if req.Action == "create" {
+			applyTargetDefaults(&target)
+		}
+		if !validateInstanceIDs(c, h.db, orgID, target.InstanceIDs) {
+			return
+		}
+		payload = target
+	default:
+		rule, err := decodeAlertRulePayload(req.Payload)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload: " + err.Error()})
+			return
+		}
+		if err := validateAlertRuleTemplates(rule); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		// 严格模式下不符合检查规则的修改不能加入变更集
+		if _, ok := h.lintAlertRule(c, h.db, orgID, rule); !ok {
+			return
+		}
+		payload = rule
+	}
+
+	payloadJSON, err := marshalAuditValue(payload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	cs := loadDraftChangeSet(c, tx, orgID, userID)
+	if cs == nil {
+		return
+	}
+
+	var item models.ChangeSetItem
+	var storedPayload []byte
+	err = tx.QueryRow(`
+		INSERT INTO change_set_items (change_set_id, entity_type, action, entity_id, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, change_set_id, entity_type, action, entity_id, payload, created_at`,
+		cs.ID, req.EntityType, req.Action, req.EntityID, payloadJSON).Scan(
+		&item.ID, &item.ChangeSetID, &item.EntityType, &item.Action, &item.EntityID, &storedPayload, &item.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add change"})
+		return
+	}
+	item.Payload = storedPayload
+
+	// 确认加入后整个变更集仍能应用到当前配置并正常渲染
+	items, err := queryChangeSetItems(tx, cs.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set items"})
+		return
+	}
+	if _, err := h.previewChangeSet(tx, orgID, items); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add change"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+func (h *Handlers) RemoveChangeSetItem(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	itemUUID, err := uuid.Parse(c.Param("item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	cs := loadDraftChangeSet(c, tx, orgID, userID)
+	if cs == nil {
+		return
+	}
+
+	result, err := tx.Exec("DELETE FROM change_set_items WHERE id = $1 AND change_set_id = $2", itemUUID, cs.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove change"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Change not found"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Change removed successfully"})
+}
+
+// 变更集应用到当前配置后的unified diff
+func (h *Handlers) DiffChangeSet(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRender) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	changeSetUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change set ID"})
+		return
+	}
+
+	cs, err := getChangeSet(h.db, orgID, changeSetUUID, false)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Change set not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set"})
+		return
+	}
+
+	// 已应用的变更集请查看对应revision的diff
+	if cs.Status == changeSetApplied {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       "Change set has already been applied",
+			"revision_id": cs.AppliedRevisionID,
+		})
+		return
+	}
+
+	items, err := queryChangeSetItems(h.db, cs.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set items"})
+		return
+	}
+
+	previews, err := h.previewChangeSet(h.db, orgID, items)
+	if errors.Is(err, errChangeConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 每个实例单独比较，文件名前加实例名区分，默认实例不加
+	var diff string
+	instances := make([]instanceDiff, len(previews))
+	for i, preview := range previews {
+		fromName, toName := "current", cs.ID.String()
+		if !preview.instance.Implicit {
+			fromName += "/" + preview.instance.Name
+			toName += "/" + preview.instance.Name
+		}
+		d, err := diffRendered(fromName, toName, preview.current, preview.proposed)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate diff"})
+			return
+		}
+		instances[i].InstanceID = preview.instance.InstanceRef()
+		instances[i].Name = preview.instance.Name
+		instances[i].Diff = d
+		diff += d
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":      "current",
+		"to":        cs.ID.String(),
+		"diff":      diff,
+		"instances": instances,
+	})
+}
+
+// 作者提交变更集等待审批
+func (h *Handlers) SubmitChangeSet(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	cs := loadDraftChangeSet(c, tx, orgID, userID)
+	if cs == nil {
+		return
+	}
+
+	items, err := queryChangeSetItems(tx, cs.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set items"})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Change set has no changes"})
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE change_sets SET status = $1 WHERE id = $2", changeSetSubmitted, cs.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit change set"})
+		return
+	}
+
+	if err := recordAudit(tx, c, auditEntry{
+		Action:     auditSubmit,
+		EntityType: "change_set",
+		EntityID:   cs.ID.String(),
+		Before:     gin.H{"status": cs.Status},
+		After:      gin.H{"status": changeSetSubmitted, "items": items},
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit change set"})
+		return
+	}
+
+	cs.Status = changeSetSubmitted
+	c.JSON(http.StatusOK, cs)
+}
+
+func (h *Handlers) ApproveChangeSet(c *gin.Context) {
+	h.reviewChangeSet(c, changeSetApproved)
+}
+
+func (h *Handlers) RejectChangeSet(c *gin.Context) {
+	h.reviewChangeSet(c, changeSetRejected)
+}
+
+// 审批已提交的变更集，审批人不能是作者
+func (h *Handlers) reviewChangeSet(c *gin.Context, status string) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !middleware.Authorize(c, rbac.PermChangesApprove) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	changeSetUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change set ID"})
+		return
+	}
+
+	// 请求体可选，用于填写审批意见
+	var req models.ReviewChangeSetRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	cs, err := getChangeSet(tx, orgID, changeSetUUID, true)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Change set not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set"})
+		return
+	}
+
+	if cs.AuthorID == userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Change set author cannot review their own changes"})
+		return
+	}
+
+	if cs.Status != changeSetSubmitted {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Change set is %s, only submitted change sets can be reviewed", cs.Status)})
+		return
+	}
+
+	// 批准前确认变更仍能应用到当前配置
+	if status == changeSetApproved {
+		items, err := queryChangeSetItems(tx, cs.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set items"})
+			return
+		}
+		if _, err := h.previewChangeSet(tx, orgID, items); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE change_sets SET status = $1, reviewer_id = $2, reviewed_at = NOW()
+		WHERE id = $3`, status, userID, cs.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review change set"})
+		return
+	}
+
+	if req.Comment != "" {
+		if _, err := insertChangeSetComment(tx, cs.ID, userID, req.Comment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add comment"})
+			return
+		}
+	}
+
+	action := auditApprove
+	if status == changeSetRejected {
+		action = auditReject
+	}
+	if err := recordAudit(tx, c, auditEntry{
+		Action:     action,
+		EntityType: "change_set",
+		EntityID:   cs.ID.String(),
+		Before:     gin.H{"status": cs.Status},
+		After:      gin.H{"status": status, "comment": req.Comment},
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	reviewed, err := getChangeSet(tx, orgID, cs.ID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review change set"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review change set"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reviewed)
+}
+
+func (h *Handlers) AddChangeSetComment(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	changeSetUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change set ID"})
+		return
+	}
+
+	var req models.ChangeSetCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := getChangeSet(h.db, orgID, changeSetUUID, false); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Change set not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set"})
+		return
+	}
+
+	comment, err := insertChangeSetComment(h.db, changeSetUUID, userID, req.Body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add comment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
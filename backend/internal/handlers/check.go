@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 检查渲染后的配置文件
+func checkRendered(rendered *renderedConfig) []prometheus.CheckError {
+	return prometheus.CheckConfig(rendered.PrometheusConfig, map[string][]byte{
+		prometheus.RulesFileName: rendered.AlertRulesConfig,
+	})
+}
+
+// 单个实例的检查结果
+type instanceCheckResult struct {
+	InstanceID *uuid.UUID              `json:"instance_id"`
+	Name       string                  `json:"name"`
+	Valid      bool                    `json:"valid"`
+	Errors     []prometheus.CheckError `json:"errors"`
+}
+
+// 检查配置但不同步，等同于 promtool check config
+// 请求体可以直接提供配置文件内容，也可以指定变更集，为空时检查组织当前配置
+func (h *Handlers) CheckConfig(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRender) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.ConfigCheckRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var checkErrs []prometheus.CheckError
+	var instances []instanceCheckResult
+	switch {
+	case req.PrometheusConfig != "":
+		ruleFiles := make(map[string][]byte, len(req.RuleFiles))
+		for name, content := range req.RuleFiles {
+			ruleFiles[name] = []byte(content)
+		}
+		checkErrs = prometheus.CheckConfig([]byte(req.PrometheusConfig), ruleFiles)
+
+	case len(req.RuleFiles) > 0:
+		for name, content := range req.RuleFiles {
+			checkErrs = append(checkErrs, prometheus.CheckRules(name, []byte(content))...)
+		}
+
+	default:
+		var items []models.ChangeSetItem
+		if req.ChangeSetID != nil {
+			cs, err := getChangeSet(h.db, orgID, *req.ChangeSetID, false)
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Change set not found"})
+				return
+			}
+
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set"})
+				return
+			}
+
+			if items, err = queryChangeSetItems(h.db, cs.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set items"})
+				return
+			}
+		}
+
+		previews, err := h.previewChangeSet(h.db, orgID, items)
+		if errors.Is(err, errChangeConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 与同步一样按实例检查，errors 汇总全部实例的错误
+		instances = make([]instanceCheckResult, len(previews))
+		for i, preview := range previews {
+			errs := checkRendered(preview.proposed)
+			if errs == nil {
+				errs = []prometheus.CheckError{}
+			}
+			instances[i] = instanceCheckResult{
+				InstanceID: preview.instance.InstanceRef(),
+				Name:       preview.instance.Name,
+				Valid:      len(errs) == 0,
+				Errors:     errs,
+			}
+			checkErrs = append(checkErrs, errs...)
+		}
+	}
+
+	response := gin.H{"valid": len(checkErrs) == 0, "errors": checkErrs}
+	if instances != nil {
+		response["instances"] = instances
+	}
+	if len(checkErrs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, response)
+		return
+	}
+
+	response["errors"] = []prometheus.CheckError{}
+	c.JSON(http.StatusOK, response)
+}
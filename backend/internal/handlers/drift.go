@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 实例的运行状态，来自 /-/healthy 和 status 接口
+func (h *Handlers) instanceStatus(ctx context.Context, instance *models.PrometheusInstance) models.PrometheusStatus {
+	status := models.PrometheusStatus{InstanceID: instance.InstanceRef(), Name: instance.Name, URL: instance.URL}
+
+	client, err := h.instanceClient(instance)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, instanceRequestTimeout)
+	defer cancel()
+
+	if err := client.Healthy(ctx); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Healthy = true
+
+	buildInfo, err := client.BuildInfo(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Version = buildInfo.Version
+
+	runtimeInfo, err := client.RuntimeInfo(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.StartTime = &runtimeInfo.StartTime
+	status.ReloadConfigSuccess = &runtimeInfo.ReloadConfigSuccess
+	status.LastConfigTime = &runtimeInfo.LastConfigTime
+
+	return status
+}
+
+// 获取全部实例的状态
+func (h *Handlers) GetPrometheusStatus(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	instances, err := h.orgInstances(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Prometheus instances"})
+		return
+	}
+
+	statuses := make([]models.PrometheusStatus, len(instances))
+	forEachInstance(instances, func(i int) {
+		statuses[i] = h.instanceStatus(c.Request.Context(), &instances[i])
+	})
+
+	c.JSON(http.StatusOK, statuses)
+}
+
+func (h *Handlers) GetPrometheusInstanceStatus(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	instance := loadInstance(c, h.db, orgID, false)
+	if instance == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, h.instanceStatus(c.Request.Context(), instance))
+}
+
+// 实例最近一次同步的revision，没有时返回 sql.ErrNoRows；instanceID 为nil时是默认实例，不包括已删除实例的revision
+func latestInstanceRevision(q querier, orgID uuid.UUID, instanceID *uuid.UUID) (*models.ConfigRevision, error) {
+	var revisionID uuid.UUID
+	err := q.QueryRow(`
+		SELECT id FROM config_revisions
+		WHERE org_id = $1 AND instance_id IS NOT DISTINCT FROM $2 AND ($2::uuid IS NOT NULL OR instance_name = '')
+		ORDER BY created_at DESC LIMIT 1`, orgID, instanceID).Scan(&revisionID)
+	if err != nil {
+		return nil, err
+	}
+	return getRevision(q, orgID, revisionID)
+}
+
+// 检查实例的配置漂移：
+// 当前配置与最近一次同步是否一致、输出目录中的文件是否被修改、Prometheus是否已加载最近一次同步的配置
+func (h *Handlers) instanceDrift(ctx context.Context, snapshot models.ConfigSnapshot, instance *models.PrometheusInstance) models.ConfigDrift {
+	drift := models.ConfigDrift{InstanceID: instance.InstanceRef(), Name: instance.Name}
+
+	current, err := renderSnapshot(filterSnapshot(snapshot, instance))
+	if err != nil {
+		drift.Error = "Failed to render config: " + err.Error()
+		return drift
+	}
+
+	lastName := "empty"
+	last := &renderedConfig{}
+	revision, err := latestInstanceRevision(h.db, instance.OrgID, instance.InstanceRef())
+	if err != nil && err != sql.ErrNoRows {
+		drift.Error = "Failed to get revision"
+		return drift
+	}
+	if revision != nil {
+		drift.LastRevisionID = &revision.ID
+		lastName = revision.ID.String()
+		last = &renderedConfig{[]byte(revision.PrometheusConfig), []byte(revision.AlertRulesConfig), nil}
+	}
+
+	if drift.PendingDiff, err = diffRendered(lastName, "current", last, current); err != nil {
+		drift.Error = "Failed to generate diff"
+		return drift
+	}
+	drift.PendingChanges = drift.PendingDiff != ""
+
+	files, err := prometheus.ReadFiles(h.instanceDir(instance), prometheus.ConfigFileName, prometheus.RulesFileName)
+	if err != nil {
+		drift.Error = err.Error()
+		return drift
+	}
+	onDisk := &renderedConfig{files[prometheus.ConfigFileName], files[prometheus.RulesFileName], nil}
+	if drift.FilesDiff, err = diffRendered(lastName, "files", last, onDisk); err != nil {
+		drift.Error = "Failed to generate diff"
+		return drift
+	}
+	drift.FilesModified = drift.FilesDiff != ""
+
+	// 最近一次加载配置的时间早于同步时间，说明同步后还没有重载
+	if revision != nil && instance.ReloadMode == "http" {
+		client, err := h.instanceClient(instance)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(ctx, instanceRequestTimeout)
+			defer cancel()
+
+			var info *prometheus.RuntimeInfo
+			if info, err = client.RuntimeInfo(ctx); err == nil {
+				pending := info.LastConfigTime.Before(revision.CreatedAt) || !info.ReloadConfigSuccess
+				drift.ReloadPending = &pending
+			}
+		}
+		if err != nil {
+			drift.Error = err.Error()
+		}
+	}
+
+	drift.InSync = !drift.PendingChanges && !drift.FilesModified && (drift.ReloadPending == nil || !*drift.ReloadPending)
+	return drift
+}
+
+// 检查全部实例的配置漂移
+func (h *Handlers) GetPrometheusDrift(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRender) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	instances, err := h.orgInstances(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Prometheus instances"})
+		return
+	}
+
+	snapshot, err := loadSnapshot(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load current config"})
+		return
+	}
+
+	drifts := make([]models.ConfigDrift, len(instances))
+	forEachInstance(instances, func(i int) {
+		drifts[i] = h.instanceDrift(c.Request.Context(), snapshot, &instances[i])
+	})
+
+	c.JSON(http.StatusOK, drifts)
+}
+
+func (h *Handlers) GetPrometheusInstanceDrift(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRender) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	instance := loadInstance(c, h.db, orgID, false)
+	if instance == nil {
+		return
+	}
+
+	snapshot, err := loadSnapshot(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load current config"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.instanceDrift(c.Request.Context(), snapshot, instance))
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+const globalConfigColumns = `instance_id, scrape_interval, scrape_timeout, evaluation_interval,
+	external_labels, query_log_file, rule_files, alert_relabel_configs, updated_at`
+
+func scanGlobalConfig(row rowScanner) (*models.GlobalConfig, error) {
+	var global models.GlobalConfig
+	var externalLabels, ruleFiles, alertRelabelConfigs []byte
+	err := row.Scan(&global.InstanceID, &global.ScrapeInterval, &global.ScrapeTimeout, &global.EvaluationInterval,
+		&externalLabels, &global.QueryLogFile, &ruleFiles, &alertRelabelConfigs, &global.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(externalLabels, &global.ExternalLabels); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(ruleFiles, &global.RuleFiles); err != nil {
+		return nil, err
+	}
+	global.AlertRelabelConfigs = alertRelabelConfigs
+	return &global, nil
+}
+
+// 查询组织全部实例的全局配置
+func queryGlobalConfigs(q querier, orgID uuid.UUID) ([]models.GlobalConfig, error) {
+	rows, err := q.Query(`SELECT `+globalConfigColumns+` FROM prometheus_global_configs
+		WHERE org_id = $1 ORDER BY created_at, id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	globals := []models.GlobalConfig{}
+	for rows.Next() {
+		global, err := scanGlobalConfig(rows)
+		if err != nil {
+			return nil, err
+		}
+		globals = append(globals, *global)
+	}
+	return globals, rows.Err()
+}
+
+// 实例的全局配置，没有设置时返回nil
+func findGlobalConfig(globals []models.GlobalConfig, instanceID *uuid.UUID) *models.GlobalConfig {
+	for i := range globals {
+		current := globals[i].InstanceID
+		if (current == nil && instanceID == nil) || (current != nil && instanceID != nil && *current == *instanceID) {
+			return &globals[i]
+		}
+	}
+	return nil
+}
+
+// 写入实例的全局配置，已有时更新
+func saveGlobalConfig(q querier, orgID uuid.UUID, global models.GlobalConfig) (*models.GlobalConfig, error) {
+	externalLabels, err := json.Marshal(global.ExternalLabels)
+	if err != nil {
+		return nil, err
+	}
+	ruleFiles, err := json.Marshal(global.RuleFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := scanGlobalConfig(q.QueryRow(`
+		UPDATE prometheus_global_configs
+		SET scrape_interval = $1, scrape_timeout = $2, evaluation_interval = $3,
+		    external_labels = $4, query_log_file = $5, rule_files = $6, alert_relabel_configs = $7
+		WHERE org_id = $8 AND instance_id IS NOT DISTINCT FROM $9
+		RETURNING `+globalConfigColumns,
+		global.ScrapeInterval, global.ScrapeTimeout, global.EvaluationInterval, externalLabels,
+		global.QueryLogFile, ruleFiles, nullableJSON(global.AlertRelabelConfigs), orgID, global.InstanceID))
+	if err != sql.ErrNoRows {
+		return saved, err
+	}
+
+	return scanGlobalConfig(q.QueryRow(`
+		INSERT INTO prometheus_global_configs (org_id, instance_id, scrape_interval, scrape_timeout, evaluation_interval,
+		                                       external_labels, query_log_file, rule_files, alert_relabel_configs)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+globalConfigColumns,
+		orgID, global.InstanceID, global.ScrapeInterval, global.ScrapeTimeout, global.EvaluationInterval,
+		externalLabels, global.QueryLogFile, ruleFiles, nullableJSON(global.AlertRelabelConfigs)))
+}
+
+// 按路径中的ID加载实例，default 表示默认实例（只有运维组织有），返回nil时已写入响应
+func (h *Handlers) loadInstanceOrDefault(c *gin.Context, q querier, orgID uuid.UUID) *models.PrometheusInstance {
+	if c.Param("id") == "default" {
+		if !h.isOperatorOrg(orgID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Prometheus instance not found"})
+			return nil
+		}
+		instance := h.defaultInstance(orgID)
+		return &instance
+	}
+	return loadInstance(c, q, orgID, false)
+}
+
+// 获取实例的全局配置，:id 为 default 时是默认实例的配置
+func (h *Handlers) GetGlobalConfig(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	instance := h.loadInstanceOrDefault(c, h.db, orgID)
+	if instance == nil {
+		return
+	}
+
+	global, err := scanGlobalConfig(h.db.QueryRow(`SELECT `+globalConfigColumns+` FROM prometheus_global_configs
+		WHERE org_id = $1 AND instance_id IS NOT DISTINCT FROM $2`, orgID, instance.InstanceRef()))
+	if err == sql.ErrNoRows {
+		global = &models.GlobalConfig{
+			InstanceID:     instance.InstanceRef(),
+			ExternalLabels: map[string]string{},
+			RuleFiles:      []string{},
+		}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get global config"})
+		return
+	}
+
+	c.JSON(http.StatusOK, global)
+}
+
+// 保存实例的全局配置，保存前用Prometheus的配置解析检查，同步后生效
+func (h *Handlers) SaveGlobalConfig(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
+		return
+	}
+
+	var req models.SaveGlobalConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	global := models.GlobalConfig{
+		ScrapeInterval:      req.ScrapeInterval,
+		ScrapeTimeout:       req.ScrapeTimeout,
+		EvaluationInterval:  req.EvaluationInterval,
+		ExternalLabels:      req.ExternalLabels,
+		QueryLogFile:        req.QueryLogFile,
+		RuleFiles:           req.RuleFiles,
+		AlertRelabelConfigs: req.AlertRelabelConfigs,
+	}
+	if global.ExternalLabels == nil {
+		global.ExternalLabels = map[string]string{}
+	}
+	if global.RuleFiles == nil {
+		global.RuleFiles = []string{}
+	}
+
+	if checkErrs := prometheus.CheckGlobalConfig(&global); len(checkErrs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid global config", "errors": checkErrs})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	instance := h.loadInstanceOrDefault(c, tx, orgID)
+	if instance == nil {
+		return
+	}
+	global.InstanceID = instance.InstanceRef()
+
+	var before interface{}
+	existing, err := scanGlobalConfig(tx.QueryRow(`SELECT `+globalConfigColumns+` FROM prometheus_global_configs
+		WHERE org_id = $1 AND instance_id IS NOT DISTINCT FROM $2 FOR UPDATE`, orgID, global.InstanceID))
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save global config"})
+		return
+	}
+	if existing != nil {
+		before = existing
+	}
+
+	saved, err := saveGlobalConfig(tx, orgID, global)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save global config"})
+		return
+	}
+
+	entityID := "default"
+	if global.InstanceID != nil {
+		entityID = global.InstanceID.String()
+	}
+	if err := recordAudit(tx, c, auditEntry{
+		Action:     auditUpdate,
+		EntityType: "prometheus_global_config",
+		EntityID:   entityID,
+		Before:     before,
+		After:      saved,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save global config"})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
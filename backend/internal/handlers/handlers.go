@@ -3,6 +3,8 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -10,17 +12,97 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"promeconfig-backend/internal/ai"
+	"promeconfig-backend/internal/auth"
 	"promeconfig-backend/internal/config"
+	"promeconfig-backend/internal/mail"
 	"promeconfig-backend/internal/middleware"
 	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+	"promeconfig-backend/internal/secrets"
 )
 
 type Handlers struct {
-	db *sql.DB
+	db             *sql.DB
+	cfg            *config.Config
+	oidc           *auth.OIDC
+	authenticators []auth.Authenticator
+	mailer         mail.Sender
+	prom           *prometheus.Client
+	clients        *clientCache
+	queries        *queryLimiter
+	keyring        *secrets.Keyring
 }
 
-func New(db *sql.DB) *Handlers {
-	return &Handlers{db: db}
+func New(db *sql.DB, cfg *config.Config, keyring *secrets.Keyring) (*Handlers, error) {
+	mailer, err := newMailSender(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handlers{
+		db:      db,
+		cfg:     cfg,
+		mailer:  mailer,
+		clients: newClientCache(),
+		queries: newQueryLimiter(cfg.PrometheusQueryMaxConcurrent),
+		keyring: keyring,
+	}
+
+	if cfg.OIDCIssuer != "" {
+		h.oidc = auth.NewOIDC(auth.OIDCConfig{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+			GroupsClaim:  cfg.OIDCGroupsClaim,
+			TrustEmail:   cfg.OIDCTrustEmail,
+		})
+	}
+
+	// 登录时按顺序尝试各认证方式
+	if cfg.LocalAuthEnabled {
+		h.authenticators = append(h.authenticators, auth.NewLocalAuthenticator(db))
+	}
+
+	if cfg.LDAPURL != "" {
+		ldapAuth, err := auth.NewLDAPAuthenticator(auth.LDAPConfig{
+			URL:                cfg.LDAPURL,
+			StartTLS:           cfg.LDAPStartTLS,
+			InsecureSkipVerify: cfg.LDAPInsecureSkipVerify,
+			CAFile:             cfg.LDAPCAFile,
+			BindDN:             cfg.LDAPBindDN,
+			BindPassword:       cfg.LDAPBindPassword,
+			BaseDN:             cfg.LDAPBaseDN,
+			UserFilter:         cfg.LDAPUserFilter,
+			EmailAttribute:     cfg.LDAPEmailAttribute,
+			SubjectAttribute:   cfg.LDAPSubjectAttribute,
+			GroupAttribute:     cfg.LDAPGroupAttribute,
+			GroupBaseDN:        cfg.LDAPGroupBaseDN,
+			GroupFilter:        cfg.LDAPGroupFilter,
+		})
+		if err != nil {
+			return nil, err
+		}
+		h.authenticators = append(h.authenticators, ldapAuth)
+	}
+
+	if cfg.PrometheusURL != "" {
+		h.prom, err = prometheus.NewClient(prometheus.ClientConfig{
+			URL:                cfg.PrometheusURL,
+			Username:           cfg.PrometheusUsername,
+			Password:           cfg.PrometheusPassword,
+			InsecureSkipVerify: cfg.PrometheusInsecureSkipVerify,
+			CAFile:             cfg.PrometheusCAFile,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
 }
 
 // 生成JWT Token
@@ -36,6 +118,11 @@ func (h *Handlers) generateToken(userID uuid.UUID) (string, error) {
 
 // 用户注册
 func (h *Handlers) SignUp(c *gin.Context) {
+	if !h.cfg.LocalAuthEnabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Local authentication is disabled"})
+		return
+	}
+
 	var req models.SignUpRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -62,9 +149,16 @@ func (h *Handlers) SignUp(c *gin.Context) {
 		return
 	}
 
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
 	// 创建用户
 	var user models.User
-	err = h.db.QueryRow(`
+	err = tx.QueryRow(`
 		INSERT INTO users (email, password_hash) 
 		VALUES ($1, $2) 
 		RETURNING id, email, created_at, updated_at`,
@@ -75,48 +169,113 @@ func (h *Handlers) SignUp(c *gin.Context) {
 		return
 	}
 
-	// 生成token
-	token, err := h.generateToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	// 创建个人组织，注册用户为管理员
+	if err := createPersonalOrganization(tx, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.AuthResponse{
-		User:        user,
-		AccessToken: token,
-		TokenType:   "Bearer",
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	// 邮箱验证通过后才能登录，发送失败时用户可以重新发送
+	if err := h.sendVerificationEmail(c.Request.Context(), user.ID, user.Email); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"user":    user,
+		"message": "Verification email sent, please verify your email before signing in",
 	})
 }
 
 // 用户登录
 func (h *Handlers) SignIn(c *gin.Context) {
+	if len(h.authenticators) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Password authentication is disabled"})
+		return
+	}
+
 	var req models.SignInRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 查找用户
-	var user models.User
-	err := h.db.QueryRow(`
-		SELECT id, email, password_hash, created_at, updated_at 
-		FROM users WHERE email = $1`, req.Email).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	// 依次尝试各认证方式，凭据无效时继续下一个
+	var identity *auth.Identity
+	var err error
+	for _, authenticator := range h.authenticators {
+		identity, err = authenticator.Authenticate(c.Request.Context(), req.Email, req.Password)
+		if err == nil || !errors.Is(err, auth.ErrInvalidCredentials) {
+			break
+		}
+	}
 
-	if err == sql.ErrNoRows {
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		h.recordSignIn(c, uuid.Nil, req.Email, "password", false)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
+	if err != nil {
+		h.recordSignIn(c, uuid.Nil, req.Email, "password", false)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Authentication error"})
+		return
+	}
+
+	// 本地用户直接加载，外部身份首次登录时创建用户
+	var user models.User
+	if identity.Provider == "local" {
+		err = h.db.QueryRow(`
+			SELECT id, email, created_at, updated_at 
+			FROM users WHERE id = $1`, identity.Subject).Scan(
+			&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	} else {
+		user, err = h.provisionExternalUser(identity)
+	}
+
+	if errors.Is(err, errUnverifiedAccount) {
+		h.recordSignIn(c, uuid.Nil, req.Email, identity.Provider, false)
+		c.JSON(http.StatusConflict, gin.H{"error": "An unverified account with this email already exists, verify it before signing in with LDAP"})
+		return
+	}
+	if errors.Is(err, errNoVerifiedEmail) {
+		h.recordSignIn(c, uuid.Nil, req.Email, identity.Provider, false)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Directory entry has no email"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
 
-	// 验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	var verified, totpEnabled bool
+	if err := h.db.QueryRow("SELECT verified, totp_enabled FROM users WHERE id = $1", user.ID).Scan(&verified, &totpEnabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if !verified {
+		h.recordSignIn(c, user.ID, user.Email, identity.Provider, false)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email not verified"})
+		return
+	}
+
+	// 开启两步验证时先返回challenge token，验证码通过后再签发token
+	if totpEnabled {
+		challenge, err := h.generateMFAChallenge(user.ID, identity.Provider)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, models.MFAChallengeResponse{
+			MFARequired:    true,
+			ChallengeToken: challenge,
+			TokenType:      "mfa_required",
+		})
 		return
 	}
 
@@ -127,6 +286,8 @@ func (h *Handlers) SignIn(c *gin.Context) {
 		return
 	}
 
+	h.recordSignIn(c, user.ID, user.Email, identity.Provider, true)
+
 	c.JSON(http.StatusOK, models.AuthResponse{
 		User:        user,
 		AccessToken: token,
@@ -170,46 +331,16 @@ func (h *Handlers) RefreshToken(c *gin.Context) {
 
 // Targets相关处理器
 func (h *Handlers) GetTargets(c *gin.Context) {
-	userID, ok := middleware.GetUserID(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
 		return
 	}
+	orgID, _ := middleware.GetOrgID(c)
 
-	rows, err := h.db.Query(`
-		SELECT id, user_id, job_name, targets, scrape_interval, metrics_path, 
-		       relabel_configs, metric_relabel_configs, created_at, updated_at
-		FROM targets WHERE user_id = $1 ORDER BY created_at DESC`, userID)
-
+	targets, err := queryTargets(h.db, orgID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get targets"})
 		return
 	}
-	defer rows.Close()
-
-	var targets []models.Target
-	for rows.Next() {
-		var target models.Target
-		var relabelConfigs, metricRelabelConfigs sql.NullString
-
-		err := rows.Scan(&target.ID, &target.UserID, &target.JobName, &target.Targets,
-			&target.ScrapeInterval, &target.MetricsPath, &relabelConfigs, &metricRelabelConfigs,
-			&target.CreatedAt, &target.UpdatedAt)
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan target"})
-			return
-		}
-
-		if relabelConfigs.Valid {
-			target.RelabelConfigs = json.RawMessage(relabelConfigs.String)
-		}
-		if metricRelabelConfigs.Valid {
-			target.MetricRelabelConfigs = json.RawMessage(metricRelabelConfigs.String)
-		}
-
-		targets = append(targets, target)
-	}
 
 	c.JSON(http.StatusOK, targets)
 }
@@ -220,6 +351,13 @@ func (h *Handlers) CreateTarget(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
 	}
+	if !middleware.Authorize(c, rbac.PermTargetsWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
+		return
+	}
 
 	var req models.CreateTargetRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -227,35 +365,43 @@ func (h *Handlers) CreateTarget(c *gin.Context) {
 		return
 	}
 
-	// 设置默认值
-	if req.ScrapeInterval == "" {
-		req.ScrapeInterval = "15s"
-	}
-	if req.MetricsPath == "" {
-		req.MetricsPath = "/metrics"
+	applyTargetDefaults(&req)
+	if !validateInstanceIDs(c, h.db, orgID, req.InstanceIDs) {
+		return
 	}
 
-	var target models.Target
-	err := h.db.QueryRow(`
-		INSERT INTO targets (user_id, job_name, targets, scrape_interval, metrics_path, relabel_configs, metric_relabel_configs)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, user_id, job_name, targets, scrape_interval, metrics_path, relabel_configs, metric_relabel_configs, created_at, updated_at`,
-		userID, req.JobName, req.Targets, req.ScrapeInterval, req.MetricsPath, req.RelabelConfigs, req.MetricRelabelConfigs).Scan(
-		&target.ID, &target.UserID, &target.JobName, &target.Targets, &target.ScrapeInterval,
-		&target.MetricsPath, &target.RelabelConfigs, &target.MetricRelabelConfigs, &target.CreatedAt, &target.UpdatedAt)
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
 
+	target, err := insertTarget(tx, orgID, userID, req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create target"})
 		return
 	}
 
+	if err := recordChange(tx, c, auditCreate, "target", target.ID, nil, target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create target"})
+		return
+	}
+
 	c.JSON(http.StatusCreated, target)
 }
 
 func (h *Handlers) UpdateTarget(c *gin.Context) {
-	userID, ok := middleware.GetUserID(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	if !middleware.Authorize(c, rbac.PermTargetsWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
 		return
 	}
 
@@ -271,18 +417,29 @@ func (h *Handlers) UpdateTarget(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !validateInstanceIDs(c, h.db, orgID, req.InstanceIDs) {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := getTargetForUpdate(tx, orgID, targetUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
+		return
+	}
 
-	var target models.Target
-	err = h.db.QueryRow(`
-		UPDATE targets 
-		SET job_name = $1, targets = $2, scrape_interval = $3, metrics_path = $4, 
-		    relabel_configs = $5, metric_relabel_configs = $6
-		WHERE id = $7 AND user_id = $8
-		RETURNING id, user_id, job_name, targets, scrape_interval, metrics_path, relabel_configs, metric_relabel_configs, created_at, updated_at`,
-		req.JobName, req.Targets, req.ScrapeInterval, req.MetricsPath, req.RelabelConfigs, req.MetricRelabelConfigs, targetUUID, userID).Scan(
-		&target.ID, &target.UserID, &target.JobName, &target.Targets, &target.ScrapeInterval,
-		&target.MetricsPath, &target.RelabelConfigs, &target.MetricRelabelConfigs, &target.CreatedAt, &target.UpdatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update target"})
+		return
+	}
 
+	target, err := updateTarget(tx, orgID, targetUUID, req)
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
 		return
@@ -293,13 +450,25 @@ func (h *Handlers) UpdateTarget(c *gin.Context) {
 		return
 	}
 
+	if err := recordChange(tx, c, auditUpdate, "target", target.ID, before, target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update target"})
+		return
+	}
+
 	c.JSON(http.StatusOK, target)
 }
 
 func (h *Handlers) DeleteTarget(c *gin.Context) {
-	userID, ok := middleware.GetUserID(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	if !middleware.Authorize(c, rbac.PermTargetsWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
 		return
 	}
 
@@ -310,54 +479,73 @@ func (h *Handlers) DeleteTarget(c *gin.Context) {
 		return
 	}
 
-	result, err := h.db.Exec("DELETE FROM targets WHERE id = $1 AND user_id = $2", targetUUID, userID)
+	tx, err := h.db.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete target"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
+	defer tx.Rollback()
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	before, err := getTargetForUpdate(tx, orgID, targetUUID)
+	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
 		return
 	}
 
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete target"})
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM targets WHERE id = $1 AND org_id = $2", targetUUID, orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete target"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditDelete, "target", targetUUID, before, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete target"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Target deleted successfully"})
 }
 
 // Alert Rules相关处理器
 func (h *Handlers) GetAlertRules(c *gin.Context) {
-	userID, ok := middleware.GetUserID(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
 		return
 	}
+	orgID, _ := middleware.GetOrgID(c)
 
-	rows, err := h.db.Query(`
-		SELECT id, user_id, alert_name, expr, for_duration, labels, annotations, created_at, updated_at
-		FROM alert_rules WHERE user_id = $1 ORDER BY created_at DESC`, userID)
-
+	alertRules, err := queryAlertRules(h.db, orgID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rules"})
 		return
 	}
-	defer rows.Close()
 
-	var alertRules []models.AlertRule
-	for rows.Next() {
-		var rule models.AlertRule
-		err := rows.Scan(&rule.ID, &rule.UserID, &rule.AlertName, &rule.Expr,
-			&rule.ForDuration, &rule.Labels, &rule.Annotations, &rule.CreatedAt, &rule.UpdatedAt)
+	// state=true 时附带各规则在Prometheus中的运行状态
+	if c.Query("state") != "true" {
+		c.JSON(http.StatusOK, alertRules)
+		return
+	}
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan alert rule"})
-			return
-		}
+	states, err := h.alertRuleStates(c.Request.Context(), orgID, alertRules)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rule state"})
+		return
+	}
 
-		alertRules = append(alertRules, rule)
+	result := make([]models.AlertRuleWithState, 0, len(alertRules))
+	for _, rule := range alertRules {
+		result = append(result, models.AlertRuleWithState{AlertRule: rule, State: states[rule.ID]})
 	}
 
-	c.JSON(http.StatusOK, alertRules)
+	c.JSON(http.StatusOK, result)
 }
 
 func (h *Handlers) CreateAlertRule(c *gin.Context) {
@@ -366,6 +554,13 @@ func (h *Handlers) CreateAlertRule(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
 	}
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
+		return
+	}
 
 	var req models.CreateAlertRuleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -377,6 +572,9 @@ func (h *Handlers) CreateAlertRule(c *gin.Context) {
 	if req.ForDuration == "" {
 		req.ForDuration = "5m"
 	}
+	if req.GroupName == "" {
+		req.GroupName = prometheus.DefaultRuleGroup
+	}
 	if req.Labels == nil {
 		req.Labels = json.RawMessage("{}")
 	}
@@ -384,27 +582,48 @@ func (h *Handlers) CreateAlertRule(c *gin.Context) {
 		req.Annotations = json.RawMessage("{}")
 	}
 
-	var rule models.AlertRule
-	err := h.db.QueryRow(`
-		INSERT INTO alert_rules (user_id, alert_name, expr, for_duration, labels, annotations)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, user_id, alert_name, expr, for_duration, labels, annotations, created_at, updated_at`,
-		userID, req.AlertName, req.Expr, req.ForDuration, req.Labels, req.Annotations).Scan(
-		&rule.ID, &rule.UserID, &rule.AlertName, &rule.Expr, &rule.ForDuration,
-		&rule.Labels, &rule.Annotations, &rule.CreatedAt, &rule.UpdatedAt)
+	if err := validateAlertRuleTemplates(req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	warnings, ok := h.lintAlertRule(c, h.db, orgID, req)
+	if !ok {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
 
+	rule, err := insertAlertRule(tx, orgID, userID, req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert rule"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, rule)
+	if err := recordChange(tx, c, auditCreate, "alert_rule", rule.ID, nil, rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.AlertRuleWithWarnings{AlertRule: *rule, Warnings: warnings})
 }
 
 func (h *Handlers) UpdateAlertRule(c *gin.Context) {
-	userID, ok := middleware.GetUserID(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
 		return
 	}
 
@@ -420,17 +639,28 @@ func (h *Handlers) UpdateAlertRule(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.GroupName == "" {
+		req.GroupName = prometheus.DefaultRuleGroup
+	}
 
-	var rule models.AlertRule
-	err = h.db.QueryRow(`
-		UPDATE alert_rules 
-		SET alert_name = $1, expr = $2, for_duration = $3, labels = $4, annotations = $5
-		WHERE id = $6 AND user_id = $7
-		RETURNING id, user_id, alert_name, expr, for_duration, labels, annotations, created_at, updated_at`,
-		req.AlertName, req.Expr, req.ForDuration, req.Labels, req.Annotations, ruleUUID, userID).Scan(
-		&rule.ID, &rule.UserID, &rule.AlertName, &rule.Expr, &rule.ForDuration,
-		&rule.Labels, &rule.Annotations, &rule.CreatedAt, &rule.UpdatedAt)
+	if err := validateAlertRuleTemplates(req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
 
+	warnings, ok := h.lintAlertRule(c, h.db, orgID, req)
+	if !ok {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := getAlertRuleForUpdate(tx, orgID, ruleUUID)
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
 		return
@@ -441,13 +671,36 @@ func (h *Handlers) UpdateAlertRule(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, rule)
+	rule, err := updateAlertRule(tx, orgID, ruleUUID, req)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert rule"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditUpdate, "alert_rule", rule.ID, before, rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AlertRuleWithWarnings{AlertRule: *rule, Warnings: warnings})
 }
 
 func (h *Handlers) DeleteAlertRule(c *gin.Context) {
-	userID, ok := middleware.GetUserID(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
 		return
 	}
 
@@ -458,18 +711,39 @@ func (h *Handlers) DeleteAlertRule(c *gin.Context) {
 		return
 	}
 
-	result, err := h.db.Exec("DELETE FROM alert_rules WHERE id = $1 AND user_id = $2", ruleUUID, userID)
+	tx, err := h.db.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
+	defer tx.Rollback()
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	before, err := getAlertRuleForUpdate(tx, orgID, ruleUUID)
+	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
 		return
 	}
 
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM alert_rules WHERE id = $1 AND org_id = $2", ruleUUID, orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditDelete, "alert_rule", ruleUUID, before, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Alert rule deleted successfully"})
 }
 
@@ -481,13 +755,7 @@ func (h *Handlers) GetAISettings(c *gin.Context) {
 		return
 	}
 
-	var settings models.AISettings
-	err := h.db.QueryRow(`
-		SELECT id, user_id, provider, api_key, base_url, model, temperature, created_at, updated_at
-		FROM ai_settings WHERE user_id = $1`, userID).Scan(
-		&settings.ID, &settings.UserID, &settings.Provider, &settings.APIKey,
-		&settings.BaseURL, &settings.Model, &settings.Temperature, &settings.CreatedAt, &settings.UpdatedAt)
-
+	settings, err := getAISettings(h.db, userID)
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusOK, nil)
 		return
@@ -514,29 +782,65 @@ func (h *Handlers) SaveAISettings(c *gin.Context) {
 		return
 	}
 
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := getAISettingsForUpdate(tx, userID)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save AI settings"})
+		return
+	}
+
+	apiKey, encrypted, err := h.resolveAIAPIKey(userID, &req, before)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save AI settings"})
+		return
+	}
+	if err := ai.Validate(aiConfig(req.Provider, apiKey, req.BaseURL, req.Model, req.APIVersion, req.Temperature)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 尝试更新现有设置
-	var settings models.AISettings
-	err := h.db.QueryRow(`
+	settings, err := scanAISettings(tx.QueryRow(`
 		UPDATE ai_settings 
-		SET provider = $1, api_key = $2, base_url = $3, model = $4, temperature = $5
-		WHERE user_id = $6
-		RETURNING id, user_id, provider, api_key, base_url, model, temperature, created_at, updated_at`,
-		req.Provider, req.APIKey, req.BaseURL, req.Model, req.Temperature, userID).Scan(
-		&settings.ID, &settings.UserID, &settings.Provider, &settings.APIKey,
-		&settings.BaseURL, &settings.Model, &settings.Temperature, &settings.CreatedAt, &settings.UpdatedAt)
+		SET provider = $1, api_key = $2, api_key_version = $3, api_key_hint = $4, base_url = $5, model = $6,
+		    api_version = $7, temperature = $8
+		WHERE user_id = $9
+		RETURNING `+aiSettingsColumns,
+		req.Provider, encrypted.Ciphertext, encrypted.Version, encrypted.Hint, req.BaseURL, req.Model,
+		req.APIVersion, req.Temperature, userID))
 
 	if err == sql.ErrNoRows {
 		// 创建新设置
-		err = h.db.QueryRow(`
-			INSERT INTO ai_settings (user_id, provider, api_key, base_url, model, temperature)
-			VALUES ($1, $2, $3, $4, $5, $6)
-			RETURNING id, user_id, provider, api_key, base_url, model, temperature, created_at, updated_at`,
-			userID, req.Provider, req.APIKey, req.BaseURL, req.Model, req.Temperature).Scan(
-			&settings.ID, &settings.UserID, &settings.Provider, &settings.APIKey,
-			&settings.BaseURL, &settings.Model, &settings.Temperature, &settings.CreatedAt, &settings.UpdatedAt)
+		settings, err = scanAISettings(tx.QueryRow(`
+			INSERT INTO ai_settings (user_id, provider, api_key, api_key_version, api_key_hint, base_url, model, api_version, temperature)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING `+aiSettingsColumns,
+			userID, req.Provider, encrypted.Ciphertext, encrypted.Version, encrypted.Hint, req.BaseURL, req.Model,
+			req.APIVersion, req.Temperature))
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save AI settings"})
+		return
 	}
 
+	if before == nil {
+		err = recordChange(tx, c, auditCreate, "ai_settings", settings.ID, nil, settings)
+	} else {
+		err = recordChange(tx, c, auditUpdate, "ai_settings", settings.ID, before, settings)
+	}
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save AI settings"})
 		return
 	}
@@ -551,39 +855,38 @@ func (h *Handlers) DeleteAISettings(c *gin.Context) {
 		return
 	}
 
-	result, err := h.db.Exec("DELETE FROM ai_settings WHERE user_id = $1", userID)
+	tx, err := h.db.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete AI settings"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
+	defer tx.Rollback()
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	before, err := getAISettingsForUpdate(tx, userID)
+	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "AI settings not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "AI settings deleted successfully"})
-}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete AI settings"})
+		return
+	}
 
-// Prometheus配置管理
-func (h *Handlers) SyncPrometheusConfig(c *gin.Context) {
-	// 这里实现配置同步逻辑
-	// 可以调用外部服务或直接写入文件系统
-	c.JSON(http.StatusOK, gin.H{"message": "Configuration synced successfully"})
-}
+	if _, err := tx.Exec("DELETE FROM ai_settings WHERE user_id = $1", userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete AI settings"})
+		return
+	}
 
-func (h *Handlers) ReloadPrometheusConfig(c *gin.Context) {
-	// 这里实现Prometheus重载逻辑
-	// 调用Prometheus的reload API
-	c.JSON(http.StatusOK, gin.H{"message": "Configuration reloaded successfully"})
-}
+	if err := recordChange(tx, c, auditDelete, "ai_settings", before.ID, before, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
 
-func (h *Handlers) GetPrometheusStatus(c *gin.Context) {
-	// 这里实现获取Prometheus状态逻辑
-	c.JSON(http.StatusOK, gin.H{
-		"status": "healthy",
-		"version": "2.45.0",
-		"uptime": "2h 15m",
-	})
-}
\ No newline at end of file
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete AI settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "AI settings deleted successfully"})
+}
@@ -0,0 +1,538 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 默认实例没有配置 PROMETHEUS_URL，或实例的API不可用
+var errPrometheusNotConfigured = errors.New("Prometheus API is not configured")
+
+const instanceColumns = `id, org_id, name, url, username, password, bearer_token, insecure_skip_verify,
+	ca_cert, output_dir, reload_mode, created_at, updated_at`
+
+func scanInstance(row rowScanner) (*models.PrometheusInstance, error) {
+	var instance models.PrometheusInstance
+	err := row.Scan(&instance.ID, &instance.OrgID, &instance.Name, &instance.URL, &instance.Username,
+		&instance.Password, &instance.BearerToken, &instance.InsecureSkipVerify, &instance.CACert,
+		&instance.OutputDir, &instance.ReloadMode, &instance.CreatedAt, &instance.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	instance.HasPassword = instance.Password != ""
+	instance.HasBearerToken = instance.BearerToken != ""
+	return &instance, nil
+}
+
+// 查询组织添加的Prometheus实例，按名称排序
+func queryInstances(q querier, orgID uuid.UUID) ([]models.PrometheusInstance, error) {
+	rows, err := q.Query(`SELECT `+instanceColumns+` FROM prometheus_instances WHERE org_id = $1 ORDER BY name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	instances := []models.PrometheusInstance{}
+	for rows.Next() {
+		instance, err := scanInstance(rows)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, *instance)
+	}
+	return instances, rows.Err()
+}
+
+func getInstance(q querier, orgID, instanceID uuid.UUID, lock bool) (*models.PrometheusInstance, error) {
+	query := `SELECT ` + instanceColumns + ` FROM prometheus_instances WHERE id = $1 AND org_id = $2`
+	if lock {
+		query += " FOR UPDATE"
+	}
+	return scanInstance(q.QueryRow(query, instanceID, orgID))
+}
+
+// 是否是 OPERATOR_ORGANIZATION_ID 指定的、可以使用环境变量中默认连接的组织
+func (h *Handlers) isOperatorOrg(orgID uuid.UUID) bool {
+	return h.cfg.OperatorOrganizationID != "" && h.cfg.OperatorOrganizationID == orgID.String()
+}
+
+// 由环境变量配置的默认实例，运维组织未添加实例时使用，配置写入组织目录
+func (h *Handlers) defaultInstance(orgID uuid.UUID) models.PrometheusInstance {
+	instance := models.PrometheusInstance{
+		OrgID:              orgID,
+		Name:               "default",
+		URL:                h.cfg.PrometheusURL,
+		Username:           h.cfg.PrometheusUsername,
+		HasPassword:        h.cfg.PrometheusPassword != "",
+		InsecureSkipVerify: h.cfg.PrometheusInsecureSkipVerify,
+		ReloadMode:         "none",
+		Implicit:           true,
+	}
+	if h.cfg.PrometheusURL != "" {
+		instance.ReloadMode = "http"
+	}
+	return instance
+}
+
+// 组织的全部实例，运维组织未添加实例时只有默认实例，其他组织未添加实例时为空
+func (h *Handlers) orgInstances(q querier, orgID uuid.UUID) ([]models.PrometheusInstance, error) {
+	instances, err := queryInstances(q, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if len(instances) == 0 && h.isOperatorOrg(orgID) {
+		instances = append(instances, h.defaultInstance(orgID))
+	}
+	return instances, nil
+}
+
+// 实例配置文件的输出目录，位于组织目录之下
+func (h *Handlers) instanceDir(instance *models.PrometheusInstance) string {
+	if instance.Implicit {
+		return h.configDir(instance.OrgID)
+	}
+	return filepath.Join(h.configDir(instance.OrgID), instance.OutputDir)
+}
+
+// 按实例缓存的API客户端，实例修改后重新创建
+type clientCache struct {
+	mu      sync.Mutex
+	clients map[uuid.UUID]cachedClient
+}
+
+type cachedClient struct {
+	updatedAt time.Time
+	client    *prometheus.Client
+}
+
+func newClientCache() *clientCache {
+	return &clientCache{clients: make(map[uuid.UUID]cachedClient)}
+}
+
+func instanceClientConfig(instance *models.PrometheusInstance) prometheus.ClientConfig {
+	return prometheus.ClientConfig{
+		URL:                instance.URL,
+		Username:           instance.Username,
+		Password:           instance.Password,
+		BearerToken:        instance.BearerToken,
+		InsecureSkipVerify: instance.InsecureSkipVerify,
+		CACert:             instance.CACert,
+	}
+}
+
+// 实例的API客户端，默认实例使用由环境变量创建的客户端
+func (h *Handlers) instanceClient(instance *models.PrometheusInstance) (*prometheus.Client, error) {
+	if instance.Implicit {
+		if h.prom == nil || !h.isOperatorOrg(instance.OrgID) {
+			return nil, errPrometheusNotConfigured
+		}
+		return h.prom, nil
+	}
+
+	h.clients.mu.Lock()
+	defer h.clients.mu.Unlock()
+
+	if cached, ok := h.clients.clients[instance.ID]; ok && cached.updatedAt.Equal(instance.UpdatedAt) {
+		return cached.client, nil
+	}
+
+	client, err := prometheus.NewClient(instanceClientConfig(instance))
+	if err != nil {
+		return nil, err
+	}
+	h.clients.clients[instance.ID] = cachedClient{updatedAt: instance.UpdatedAt, client: client}
+	return client, nil
+}
+
+// 只保留分配到实例的targets、告警规则、远程存储和Alertmanager，并选出实例的全局配置，默认实例使用全部配置
+// 规则按所在规则组分配，没有分配记录的规则组属于全部实例
+func filterSnapshot(snapshot models.ConfigSnapshot, instance *models.PrometheusInstance) models.ConfigSnapshot {
+	snapshot.Global = findGlobalConfig(snapshot.GlobalConfigs, instance.InstanceRef())
+	if instance.Implicit {
+		return snapshot
+	}
+
+	groups := make(map[string]models.UUIDList, len(snapshot.RuleGroups))
+	for _, group := range snapshot.RuleGroups {
+		groups[group.Name] = group.InstanceIDs
+	}
+
+	filtered := models.ConfigSnapshot{
+		Targets:        []models.Target{},
+		AlertRules:     []models.AlertRule{},
+		RuleGroups:     snapshot.RuleGroups,
+		GlobalConfigs:  snapshot.GlobalConfigs,
+		RemoteStorages: []models.RemoteStorage{},
+		Alertmanagers:  []models.Alertmanager{},
+		Global:         snapshot.Global,
+	}
+	for _, target := range snapshot.Targets {
+		if target.InstanceIDs.Includes(instance.ID) {
+			filtered.Targets = append(filtered.Targets, target)
+		}
+	}
+	for _, remote := range snapshot.RemoteStorages {
+		if remote.InstanceIDs.Includes(instance.ID) {
+			filtered.RemoteStorages = append(filtered.RemoteStorages, remote)
+		}
+	}
+	for _, am := range snapshot.Alertmanagers {
+		if am.InstanceIDs.Includes(instance.ID) {
+			filtered.Alertmanagers = append(filtered.Alertmanagers, am)
+		}
+	}
+	for _, rule := range snapshot.AlertRules {
+		groupName := rule.GroupName
+		if groupName == "" {
+			groupName = prometheus.DefaultRuleGroup
+		}
+		if groups[groupName].Includes(instance.ID) {
+			filtered.AlertRules = append(filtered.AlertRules, rule)
+		}
+	}
+	return filtered
+}
+
+// 校验分配的实例都属于组织，返回false时已写入响应
+func validateInstanceIDs(c *gin.Context, q querier, orgID uuid.UUID, ids models.UUIDList) bool {
+	for _, id := range ids {
+		var exists bool
+		err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM prometheus_instances WHERE id = $1 AND org_id = $2)",
+			id, orgID).Scan(&exists)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return false
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Prometheus instance %s not found", id)})
+			return false
+		}
+	}
+	return true
+}
+
+// 按路径中的ID加载实例，返回nil时已写入响应
+func loadInstance(c *gin.Context, q querier, orgID uuid.UUID, lock bool) *models.PrometheusInstance {
+	instanceUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid instance ID"})
+		return nil
+	}
+
+	instance, err := getInstance(q, orgID, instanceUUID, lock)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prometheus instance not found"})
+		return nil
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Prometheus instance"})
+		return nil
+	}
+	return instance
+}
+
+// 查询使用的Prometheus客户端，instance_id 参数指定实例，未指定时使用按名称排序的第一个实例
+// 返回nil时已写入响应
+func (h *Handlers) prometheusClient(c *gin.Context, orgID uuid.UUID) *prometheus.Client {
+	var instance *models.PrometheusInstance
+	if value := c.Request.FormValue("instance_id"); value != "" {
+		instanceUUID, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid instance_id"})
+			return nil
+		}
+
+		instance, err = getInstance(h.db, orgID, instanceUUID, false)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Prometheus instance not found"})
+			return nil
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Prometheus instance"})
+			return nil
+		}
+	} else {
+		instances, err := h.orgInstances(h.db, orgID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Prometheus instances"})
+			return nil
+		}
+		if len(instances) == 0 {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errPrometheusNotConfigured.Error()})
+			return nil
+		}
+		instance = &instances[0]
+	}
+
+	client, err := h.instanceClient(instance)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return nil
+	}
+	return client
+}
+
+// 把请求应用到实例上并校验，返回false时已写入响应
+func prepareInstance(c *gin.Context, instance *models.PrometheusInstance, req models.SavePrometheusInstanceRequest) bool {
+	instance.Name = req.Name
+	instance.URL = req.URL
+	instance.Username = req.Username
+	instance.InsecureSkipVerify = req.InsecureSkipVerify
+	instance.CACert = req.CACert
+	instance.ReloadMode = req.ReloadMode
+	if instance.ReloadMode == "" {
+		instance.ReloadMode = "http"
+	}
+	if req.Password != nil {
+		instance.Password = *req.Password
+	}
+	if req.BearerToken != nil {
+		instance.BearerToken = *req.BearerToken
+	}
+	instance.HasPassword = instance.Password != ""
+	instance.HasBearerToken = instance.BearerToken != ""
+
+	// 输出目录是组织目录下的相对路径，未填写时使用实例ID
+	instance.OutputDir = req.OutputDir
+	if instance.OutputDir == "" {
+		instance.OutputDir = instance.ID.String()
+	}
+	instance.OutputDir = filepath.Clean(instance.OutputDir)
+	if !filepath.IsLocal(instance.OutputDir) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "output_dir must be a relative path inside the organization config directory"})
+		return false
+	}
+
+	// 创建客户端以校验URL和CA证书
+	if _, err := prometheus.NewClient(instanceClientConfig(instance)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// 名称和输出目录在组织内不能重复，输出目录也不能与Alertmanager的重合，返回false时已写入响应
+func checkInstanceConflict(c *gin.Context, q querier, instance *models.PrometheusInstance) bool {
+	var exists bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM prometheus_instances
+			WHERE org_id = $1 AND id <> $2 AND (name = $3 OR output_dir = $4)
+		) OR $4 = COALESCE((SELECT output_dir FROM alertmanager_settings WHERE org_id = $1), $5)`,
+		instance.OrgID, instance.ID, instance.Name, instance.OutputDir, defaultAlertmanagerOutputDir).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "A Prometheus instance with this name or output directory already exists, or the output directory is used by Alertmanager"})
+		return false
+	}
+	return true
+}
+
+// 获取实例列表，运维组织未添加实例时返回默认实例
+func (h *Handlers) GetPrometheusInstances(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	instances, err := h.orgInstances(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Prometheus instances"})
+		return
+	}
+
+	c.JSON(http.StatusOK, instances)
+}
+
+func (h *Handlers) GetPrometheusInstance(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	instance := loadInstance(c, h.db, orgID, false)
+	if instance == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, instance)
+}
+
+func (h *Handlers) CreatePrometheusInstance(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.SavePrometheusInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	instance := &models.PrometheusInstance{ID: uuid.New(), OrgID: orgID}
+	if !prepareInstance(c, instance, req) {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkInstanceConflict(c, tx, instance) {
+		return
+	}
+
+	instance, err = scanInstance(tx.QueryRow(`
+		INSERT INTO prometheus_instances (id, org_id, name, url, username, password, bearer_token,
+		                                  insecure_skip_verify, ca_cert, output_dir, reload_mode)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING `+instanceColumns,
+		instance.ID, orgID, instance.Name, instance.URL, instance.Username, instance.Password, instance.BearerToken,
+		instance.InsecureSkipVerify, instance.CACert, instance.OutputDir, instance.ReloadMode))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create Prometheus instance"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditCreate, "prometheus_instance", instance.ID, nil, instance); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create Prometheus instance"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, instance)
+}
+
+func (h *Handlers) UpdatePrometheusInstance(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.SavePrometheusInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before := loadInstance(c, tx, orgID, true)
+	if before == nil {
+		return
+	}
+
+	instance := *before
+	if !prepareInstance(c, &instance, req) || !checkInstanceConflict(c, tx, &instance) {
+		return
+	}
+
+	after, err := scanInstance(tx.QueryRow(`
+		UPDATE prometheus_instances
+		SET name = $1, url = $2, username = $3, password = $4, bearer_token = $5,
+		    insecure_skip_verify = $6, ca_cert = $7, output_dir = $8, reload_mode = $9
+		WHERE id = $10 AND org_id = $11
+		RETURNING `+instanceColumns,
+		instance.Name, instance.URL, instance.Username, instance.Password, instance.BearerToken,
+		instance.InsecureSkipVerify, instance.CACert, instance.OutputDir, instance.ReloadMode, instance.ID, orgID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update Prometheus instance"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditUpdate, "prometheus_instance", after.ID, before, after); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update Prometheus instance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, after)
+}
+
+// 删除实例，实例的revision一并删除，输出目录中的文件保留
+// 仍有target、规则组、远程存储或Alertmanager分配到该实例时不能删除
+func (h *Handlers) DeletePrometheusInstance(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before := loadInstance(c, tx, orgID, true)
+	if before == nil {
+		return
+	}
+
+	var assigned bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM targets WHERE org_id = $1 AND instance_ids ? $2)
+		    OR EXISTS (SELECT 1 FROM rule_groups WHERE org_id = $1 AND instance_ids ? $2)
+		    OR EXISTS (SELECT 1 FROM remote_storages WHERE org_id = $1 AND instance_ids ? $2)
+		    OR EXISTS (SELECT 1 FROM alertmanagers WHERE org_id = $1 AND instance_ids ? $2)`,
+		orgID, before.ID.String()).Scan(&assigned)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete Prometheus instance"})
+		return
+	}
+	if assigned {
+		c.JSON(http.StatusConflict, gin.H{"error": "Prometheus instance is still assigned to targets, rule groups, remote storages or alertmanagers"})
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM prometheus_instances WHERE id = $1 AND org_id = $2", before.ID, orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete Prometheus instance"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditDelete, "prometheus_instance", before.ID, before, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete Prometheus instance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Prometheus instance deleted successfully"})
+}
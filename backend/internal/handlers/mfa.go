@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/auth"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/secrets"
+)
+
+const (
+	// 连续输错验证码或恢复码的次数达到上限后锁定
+	maxMFAFailedAttempts = 5
+	mfaLockoutDuration   = 15 * time.Minute
+)
+
+var errMFALocked = errors.New("too many failed verification attempts")
+
+// 生成两步验证的challenge token，只能使用一次，用于换取正式token；新的challenge会使旧的失效
+// provider 为第一步登录的方式（local、ldap、oidc），验证码通过后按该方式记录登录
+func (h *Handlers) generateMFAChallenge(userID uuid.UUID, provider string) (string, error) {
+	return h.issueUserToken(userID, tokenPurposeMFAChallenge, provider, mfaChallengeTTL)
+}
+
+// 使用challenge token，无论验证码是否正确都会失效，返回用户和第一步登录的方式；token无效时返回 sql.ErrNoRows
+func (h *Handlers) consumeMFAChallenge(token string) (uuid.UUID, string, error) {
+	var userID uuid.UUID
+	var provider string
+	err := h.db.QueryRow(`
+		UPDATE user_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id, provider`, auth.HashOneTimeToken(token), tokenPurposeMFAChallenge).Scan(&userID, &provider)
+	return userID, provider, err
+}
+
+// 校验TOTP验证码或恢复码，验证码和恢复码都只能使用一次
+// 每次校验先计入失败次数（并发请求在行锁上排队），成功后清零，达到上限时锁定，锁定期间返回 errMFALocked
+func (h *Handlers) verifyMFACode(userID uuid.UUID, code string) (bool, error) {
+	var secret sql.NullString
+	var lastStep int64
+	err := h.db.QueryRow(`
+		UPDATE users SET
+			mfa_failed_attempts = (mfa_failed_attempts + 1) % $2,
+			mfa_locked_until = CASE WHEN mfa_failed_attempts + 1 >= $2
+				THEN NOW() + make_interval(secs => $3) ELSE mfa_locked_until END
+		WHERE id = $1 AND totp_enabled = TRUE AND (mfa_locked_until IS NULL OR mfa_locked_until <= NOW())
+		RETURNING totp_secret, totp_last_step`,
+		userID, maxMFAFailedAttempts, int(mfaLockoutDuration.Seconds())).Scan(&secret, &lastStep)
+	if err == sql.ErrNoRows {
+		var enabled bool
+		if err := h.db.QueryRow("SELECT totp_enabled FROM users WHERE id = $1", userID).Scan(&enabled); err != nil && err != sql.ErrNoRows {
+			return false, err
+		}
+		if enabled {
+			return false, errMFALocked
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	plaintext, err := h.totpSecret(userID, secret.String)
+	if err != nil {
+		return false, err
+	}
+
+	valid := false
+	if step, ok := auth.VerifyTOTP(plaintext, code, lastStep, time.Now()); ok {
+		// 条件更新防止同一验证码被并发重放
+		result, err := h.db.Exec(`
+			UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1`, step, userID)
+		if err != nil {
+			return false, err
+		}
+		rowsAffected, _ := result.RowsAffected()
+		valid = rowsAffected == 1
+	} else {
+		result, err := h.db.Exec(`
+			UPDATE user_recovery_codes SET used_at = NOW()
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, auth.HashRecoveryCode(code))
+		if err != nil {
+			return false, err
+		}
+		rowsAffected, _ := result.RowsAffected()
+		valid = rowsAffected > 0
+	}
+
+	if valid {
+		if _, err := h.db.Exec(`
+			UPDATE users SET mfa_failed_attempts = 0, mfa_locked_until = NULL WHERE id = $1`, userID); err != nil {
+			return false, err
+		}
+	}
+	return valid, nil
+}
+
+// 重新生成恢复码，旧的恢复码全部失效
+func (h *Handlers) replaceRecoveryCodes(tx *sql.Tx, userID uuid.UUID) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		if _, err := tx.Exec(`
+			INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`,
+			userID, auth.HashRecoveryCode(code)); err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+// 获取两步验证状态
+func (h *Handlers) GetMFAStatus(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var enabled bool
+	var remaining int
+	err := h.db.QueryRow(`
+		SELECT u.totp_enabled,
+		       (SELECT COUNT(*) FROM user_recovery_codes r WHERE r.user_id = u.id AND r.used_at IS NULL)
+		FROM users u WHERE u.id = $1`, userID).Scan(&enabled, &remaining)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get MFA status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "recovery_codes_remaining": remaining})
+}
+
+// 开始绑定TOTP，返回密钥和otpauth://地址，需调用激活接口确认后才生效
+func (h *Handlers) EnrollMFA(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var email string
+	var enabled bool
+	err := h.db.QueryRow("SELECT email, totp_enabled FROM users WHERE id = $1", userID).Scan(&email, &enabled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+		return
+	}
+
+	key, err := auth.GenerateTOTPKey(h.cfg.TOTPIssuer, email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	ciphertext, err := h.keyring.Encrypt(key.Secret(), secrets.TOTPSecretAAD(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt secret"})
+		return
+	}
+
+	if _, err := h.db.Exec(`
+		UPDATE users SET totp_secret = $1, totp_secret_version = $2, totp_last_step = 0 WHERE id = $3`,
+		ciphertext, h.keyring.ActiveVersion(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFAEnrollResponse{
+		Secret:          key.Secret(),
+		ProvisioningURI: key.URL(),
+	})
+}
+
+// 使用验证器中的验证码确认绑定，成功后返回恢复码（仅显示一次）
+func (h *Handlers) ActivateMFA(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var secret sql.NullString
+	var enabled bool
+	err := h.db.QueryRow("SELECT totp_secret, totp_enabled FROM users WHERE id = $1", userID).Scan(&secret, &enabled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+		return
+	}
+
+	if !secret.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA enrollment not started"})
+		return
+	}
+
+	plaintext, err := h.totpSecret(userID, secret.String)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt secret"})
+		return
+	}
+
+	step, ok := auth.VerifyTOTP(plaintext, req.Code, 0, time.Now())
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE users SET totp_enabled = TRUE, totp_last_step = $1 WHERE id = $2`, step, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable MFA"})
+		return
+	}
+
+	codes, err := h.replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable MFA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "recovery_codes": codes})
+}
+
+// 关闭两步验证，需要提供验证码或恢复码
+func (h *Handlers) DisableMFA(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	valid, err := h.verifyMFACode(userID, req.Code)
+	if errors.Is(err, errMFALocked) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE users SET totp_enabled = FALSE, totp_secret = NULL, totp_secret_version = 0, totp_last_step = 0 WHERE id = $1`, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable MFA"})
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable MFA"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable MFA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled successfully"})
+}
+
+// 重新生成恢复码
+func (h *Handlers) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	valid, err := h.verifyMFACode(userID, req.Code)
+	if errors.Is(err, errMFALocked) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	codes, err := h.replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// 用challenge token和验证码换取正式token
+func (h *Handlers) VerifyMFAChallenge(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, provider, err := h.consumeMFAChallenge(req.ChallengeToken)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+
+	valid, err := h.verifyMFACode(userID, req.Code)
+	if errors.Is(err, errMFALocked) {
+		h.recordSignIn(c, userID, "", provider, false)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !valid {
+		h.recordSignIn(c, userID, "", provider, false)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	var user models.User
+	err = h.db.QueryRow(`
+		SELECT id, email, created_at, updated_at
+		FROM users WHERE id = $1`, userID).Scan(
+		&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	token, err := h.generateToken(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	h.recordSignIn(c, user.ID, user.Email, provider, true)
+
+	c.JSON(http.StatusOK, models.AuthResponse{
+		User:        user,
+		AccessToken: token,
+		TokenType:   "Bearer",
+	})
+}
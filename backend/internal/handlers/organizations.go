@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 为新用户创建个人组织，并设为管理员
+func createPersonalOrganization(tx *sql.Tx, user models.User) error {
+	var orgID uuid.UUID
+	if err := tx.QueryRow(`
+		INSERT INTO organizations (name) VALUES ($1) RETURNING id`, user.Email).Scan(&orgID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, $3)`,
+		orgID, user.ID, rbac.RoleAdmin)
+	return err
+}
+
+// 修改或移除成员前锁定组织的管理员行，并发降级或移除管理员时串行执行，countAdmins 才能看到其他事务的修改
+func lockAdmins(tx *sql.Tx, orgID uuid.UUID) error {
+	_, err := tx.Exec(`
+		SELECT user_id FROM organization_members WHERE org_id = $1 AND role = $2 FOR UPDATE`,
+		orgID, rbac.RoleAdmin)
+	return err
+}
+
+// 统计组织中的管理员数量，防止移除最后一个管理员
+func countAdmins(tx *sql.Tx, orgID uuid.UUID) (int, error) {
+	var count int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM organization_members WHERE org_id = $1 AND role = $2`,
+		orgID, rbac.RoleAdmin).Scan(&count)
+	return count, err
+}
+
+// 获取当前用户所属的组织
+func (h *Handlers) GetOrganizations(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT o.id, o.name, m.role, o.require_approval, o.created_at, o.updated_at
+		FROM organizations o JOIN organization_members m ON m.org_id = o.id
+		WHERE m.user_id = $1 ORDER BY m.created_at ASC`, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get organizations"})
+		return
+	}
+	defer rows.Close()
+
+	var orgs []models.Organization
+	for rows.Next() {
+		var org models.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.Role, &org.RequireApproval, &org.CreatedAt, &org.UpdatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan organization"})
+			return
+		}
+		orgs = append(orgs, org)
+	}
+
+	c.JSON(http.StatusOK, orgs)
+}
+
+func (h *Handlers) UpdateOrganization(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var org models.Organization
+	err := h.db.QueryRow(`
+		UPDATE organizations SET name = $1, require_approval = COALESCE($2, require_approval) WHERE id = $3
+		RETURNING id, name, require_approval, created_at, updated_at`, req.Name, req.RequireApproval, orgID).Scan(
+		&org.ID, &org.Name, &org.RequireApproval, &org.CreatedAt, &org.UpdatedAt)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// 组织成员管理
+func (h *Handlers) GetMembers(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	rows, err := h.db.Query(`
+		SELECT m.org_id, m.user_id, u.email, m.role, m.created_at, m.updated_at
+		FROM organization_members m JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = $1 ORDER BY m.created_at ASC`, orgID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get members"})
+		return
+	}
+	defer rows.Close()
+
+	var members []models.OrganizationMember
+	for rows.Next() {
+		var member models.OrganizationMember
+		if err := rows.Scan(&member.OrgID, &member.UserID, &member.Email, &member.Role,
+			&member.CreatedAt, &member.UpdatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan member"})
+			return
+		}
+		members = append(members, member)
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+func (h *Handlers) AddMember(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermMembersManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !rbac.Role(req.Role).Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	var member models.OrganizationMember
+	err := h.db.QueryRow(`
+		INSERT INTO organization_members (org_id, user_id, role)
+		SELECT $1, id, $2 FROM users WHERE email = $3
+		ON CONFLICT (org_id, user_id) DO NOTHING
+		RETURNING org_id, user_id, role, created_at, updated_at`,
+		orgID, req.Role, req.Email).Scan(
+		&member.OrgID, &member.UserID, &member.Role, &member.CreatedAt, &member.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "User not found or already a member"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+
+	member.Email = req.Email
+	c.JSON(http.StatusCreated, member)
+}
+
+func (h *Handlers) UpdateMember(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermMembersManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	memberUUID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !rbac.Role(req.Role).Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := lockAdmins(tx, orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	var member models.OrganizationMember
+	err = tx.QueryRow(`
+		UPDATE organization_members SET role = $1
+		WHERE org_id = $2 AND user_id = $3
+		RETURNING org_id, user_id, role, created_at, updated_at`,
+		req.Role, orgID, memberUUID).Scan(
+		&member.OrgID, &member.UserID, &member.Role, &member.CreatedAt, &member.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	admins, err := countAdmins(tx, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+	if admins == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization must keep at least one admin"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+func (h *Handlers) RemoveMember(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermMembersManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	memberUUID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := lockAdmins(tx, orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	result, err := tx.Exec("DELETE FROM organization_members WHERE org_id = $1 AND user_id = $2", orgID, memberUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	admins, err := countAdmins(tx, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	if admins == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization must keep at least one admin"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/common/model"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 限制每个用户同时执行的查询数
+type queryLimiter struct {
+	mu       sync.Mutex
+	max      int
+	inflight map[uuid.UUID]int
+}
+
+func newQueryLimiter(max int) *queryLimiter {
+	return &queryLimiter{max: max, inflight: make(map[uuid.UUID]int)}
+}
+
+func (l *queryLimiter) acquire(userID uuid.UUID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.max > 0 && l.inflight[userID] >= l.max {
+		return false
+	}
+	l.inflight[userID]++
+	return true
+}
+
+func (l *queryLimiter) release(userID uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inflight[userID] <= 1 {
+		delete(l.inflight, userID)
+		return
+	}
+	l.inflight[userID]--
+}
+
+// 与Prometheus一致，时间可以是RFC3339或unix时间戳（秒）
+func parsePrometheusTime(value string) (time.Time, error) {
+	if t, err := strconv.ParseFloat(value, 64); err == nil {
+		sec, frac := math.Modf(t)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// 与Prometheus一致，时长可以是秒数或 30s、1m 这样的写法
+func parsePrometheusDuration(value string) (time.Duration, error) {
+	if d, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(d * float64(time.Second)), nil
+	}
+	d, err := model.ParseDuration(value)
+	return time.Duration(d), err
+}
+
+// 代理请求的参数，只转发白名单中的参数
+type proxyRequest struct {
+	method   string
+	endpoint string
+	params   url.Values
+	// labels 和 label values 的结果在服务端按 q 过滤，用于自动补全
+	filter string
+	// 大于0时截断列表结果
+	limit int
+}
+
+// 从查询参数或表单中读取白名单中的参数，返回nil时已写入响应
+func newProxyRequest(c *gin.Context, method, endpoint string, allowed ...string) *proxyRequest {
+	if err := c.Request.ParseForm(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		return nil
+	}
+
+	req := &proxyRequest{method: method, endpoint: endpoint, params: url.Values{}}
+	for _, name := range allowed {
+		if values, ok := c.Request.Form[name]; ok {
+			req.params[name] = values
+		}
+	}
+	return req
+}
+
+// 查询超时不能超过配置的上限，未指定时使用上限
+func (h *Handlers) limitTimeout(c *gin.Context, req *proxyRequest) bool {
+	timeout := h.cfg.PrometheusQueryTimeout
+	if value := req.params.Get("timeout"); value != "" {
+		d, err := parsePrometheusDuration(value)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timeout"})
+			return false
+		}
+		timeout = min(timeout, d)
+	}
+
+	req.params.Set("timeout", strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+	return true
+}
+
+// 时间范围不能超过配置的上限，未指定 start 时只查询最大范围内的数据
+func (h *Handlers) limitTimeRange(c *gin.Context, req *proxyRequest) bool {
+	end := time.Now().UTC()
+	if value := req.params.Get("end"); value != "" {
+		t, err := parsePrometheusTime(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end"})
+			return false
+		}
+		end = t
+	}
+
+	start := end.Add(-h.cfg.PrometheusQueryMaxRange)
+	if value := req.params.Get("start"); value != "" {
+		t, err := parsePrometheusTime(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start"})
+			return false
+		}
+		start = t
+	} else {
+		req.params.Set("start", start.Format(time.RFC3339Nano))
+	}
+
+	if end.Sub(start) > h.cfg.PrometheusQueryMaxRange {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Query range exceeds the maximum of " + model.Duration(h.cfg.PrometheusQueryMaxRange).String(),
+		})
+		return false
+	}
+	return true
+}
+
+// 列表结果的条数不能超过配置的上限
+func (h *Handlers) limitResults(c *gin.Context, req *proxyRequest) bool {
+	req.limit = h.cfg.PrometheusQueryMaxResults
+	if value := req.params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return false
+		}
+		if limit > 0 && (req.limit <= 0 || limit < req.limit) {
+			req.limit = limit
+		}
+	}
+
+	req.params.Del("limit")
+	if req.limit > 0 {
+		req.params.Set("limit", strconv.Itoa(req.limit))
+	}
+	return true
+}
+
+// 转发到Prometheus，Prometheus的响应（包括错误）原样返回
+func (h *Handlers) proxyPrometheus(c *gin.Context, client *prometheus.Client, req *proxyRequest) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !h.queries.acquire(userID) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many concurrent queries"})
+		return
+	}
+	defer h.queries.release(userID)
+
+	// 过滤时需要完整的列表，由服务端截断
+	if req.filter != "" {
+		req.params.Del("limit")
+	}
+
+	// 比查询超时多留一些时间，让Prometheus先返回自己的超时错误
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.cfg.PrometheusQueryTimeout+5*time.Second)
+	defer cancel()
+
+	status, body, err := client.Forward(ctx, req.method, req.endpoint, req.params)
+	if errors.Is(err, context.DeadlineExceeded) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Prometheus query timed out"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach Prometheus"})
+		return
+	}
+
+	if status == http.StatusOK && (req.filter != "" || req.limit > 0) {
+		if filtered, err := filterListResponse(body, req.filter, req.limit); err == nil {
+			body = filtered
+		}
+	}
+
+	c.Data(status, "application/json", body)
+}
+
+// 列表类响应（series、labels、label values）
+type listResponse struct {
+	Status   string            `json:"status"`
+	Data     []json.RawMessage `json:"data"`
+	Warnings []string          `json:"warnings,omitempty"`
+	Infos    []string          `json:"infos,omitempty"`
+}
+
+// 按 q 过滤字符串列表（不区分大小写），并截断到 limit 条
+func filterListResponse(body []byte, filter string, limit int) ([]byte, error) {
+	var resp listResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+
+	if filter != "" {
+		filter = strings.ToLower(filter)
+		data := resp.Data[:0]
+		for _, item := range resp.Data {
+			var value string
+			if err := json.Unmarshal(item, &value); err != nil {
+				return nil, err
+			}
+			if strings.Contains(strings.ToLower(value), filter) {
+				data = append(data, item)
+			}
+		}
+		resp.Data = data
+	}
+
+	if limit > 0 && len(resp.Data) > limit {
+		resp.Data = resp.Data[:limit]
+		resp.Warnings = append(resp.Warnings, "results truncated due to limit")
+	}
+	if resp.Data == nil {
+		resp.Data = []json.RawMessage{}
+	}
+
+	return json.Marshal(resp)
+}
+
+// 代理 /api/v1/query
+func (h *Handlers) PrometheusQuery(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	client := h.prometheusClient(c, orgID)
+	if client == nil {
+		return
+	}
+
+	req := newProxyRequest(c, http.MethodPost, "query", "query", "time", "timeout")
+	if req == nil || !h.limitTimeout(c, req) {
+		return
+	}
+	if req.params.Get("query") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+		return
+	}
+
+	h.proxyPrometheus(c, client, req)
+}
+
+// 代理 /api/v1/query_range
+func (h *Handlers) PrometheusQueryRange(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	client := h.prometheusClient(c, orgID)
+	if client == nil {
+		return
+	}
+
+	req := newProxyRequest(c, http.MethodPost, "query_range", "query", "start", "end", "step", "timeout")
+	if req == nil {
+		return
+	}
+	if req.params.Get("query") == "" || req.params.Get("start") == "" || req.params.Get("end") == "" || req.params.Get("step") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query, start, end and step are required"})
+		return
+	}
+	if !h.limitTimeout(c, req) || !h.limitTimeRange(c, req) {
+		return
+	}
+
+	h.proxyPrometheus(c, client, req)
+}
+
+// 代理 /api/v1/series
+func (h *Handlers) PrometheusSeries(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	client := h.prometheusClient(c, orgID)
+	if client == nil {
+		return
+	}
+
+	req := newProxyRequest(c, http.MethodPost, "series", "match[]", "start", "end", "limit")
+	if req == nil {
+		return
+	}
+	if len(req.params["match[]"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "match[] is required"})
+		return
+	}
+	if !h.limitTimeRange(c, req) || !h.limitResults(c, req) {
+		return
+	}
+
+	h.proxyPrometheus(c, client, req)
+}
+
+// 代理 /api/v1/labels，q 用于按名称过滤
+func (h *Handlers) PrometheusLabels(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	client := h.prometheusClient(c, orgID)
+	if client == nil {
+		return
+	}
+
+	req := newProxyRequest(c, http.MethodPost, "labels", "match[]", "start", "end", "limit")
+	if req == nil || !h.limitTimeRange(c, req) || !h.limitResults(c, req) {
+		return
+	}
+	req.filter = c.Query("q")
+
+	h.proxyPrometheus(c, client, req)
+}
+
+// 代理 /api/v1/label/:name/values，name 为 __name__ 时返回指标名，q 用于按值过滤
+func (h *Handlers) PrometheusLabelValues(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	client := h.prometheusClient(c, orgID)
+	if client == nil {
+		return
+	}
+
+	// 只接受传统的标签名，作为路径的一部分无需转义
+	name := c.Param("name")
+	if !model.LabelName(name).IsValidLegacy() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label name"})
+		return
+	}
+
+	req := newProxyRequest(c, http.MethodGet, "label/"+name+"/values", "match[]", "start", "end", "limit")
+	if req == nil || !h.limitTimeRange(c, req) || !h.limitResults(c, req) {
+		return
+	}
+	req.filter = c.Query("q")
+
+	h.proxyPrometheus(c, client, req)
+}
@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+const remoteStorageColumns = `id, org_id, kind, name, url, instance_ids, remote_timeout, headers, username, password,
+	bearer_token, tls_ca_cert, tls_cert, tls_key, tls_server_name, insecure_skip_verify, write_relabel_configs,
+	queue_config, metadata_config, read_recent, required_matchers, created_at, updated_at`
+
+func scanRemoteStorage(row rowScanner) (*models.RemoteStorage, error) {
+	var remote models.RemoteStorage
+	var headers, requiredMatchers []byte
+	var writeRelabelConfigs, queueConfig, metadataConfig []byte
+	err := row.Scan(&remote.ID, &remote.OrgID, &remote.Kind, &remote.Name, &remote.URL, &remote.InstanceIDs,
+		&remote.RemoteTimeout, &headers, &remote.Username, &remote.Password, &remote.BearerToken, &remote.TLSCACert,
+		&remote.TLSCert, &remote.TLSKey, &remote.TLSServerName, &remote.InsecureSkipVerify, &writeRelabelConfigs,
+		&queueConfig, &metadataConfig, &remote.ReadRecent, &requiredMatchers, &remote.CreatedAt, &remote.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(headers, &remote.Headers); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(requiredMatchers, &remote.RequiredMatchers); err != nil {
+		return nil, err
+	}
+	remote.WriteRelabelConfigs = writeRelabelConfigs
+	remote.QueueConfig = queueConfig
+	remote.MetadataConfig = metadataConfig
+	setClientAuthFlags(&remote.ClientAuth)
+	return &remote, nil
+}
+
+// 查询组织的远程存储，kind 为空时返回全部，按名称排序
+func queryRemoteStorages(q querier, orgID uuid.UUID, kind string) ([]models.RemoteStorage, error) {
+	rows, err := q.Query(`SELECT `+remoteStorageColumns+` FROM remote_storages
+		WHERE org_id = $1 AND ($2 = '' OR kind = $2) ORDER BY kind DESC, name`, orgID, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	remotes := []models.RemoteStorage{}
+	for rows.Next() {
+		remote, err := scanRemoteStorage(rows)
+		if err != nil {
+			return nil, err
+		}
+		remotes = append(remotes, *remote)
+	}
+	return remotes, rows.Err()
+}
+
+func insertRemoteStorage(q querier, remote *models.RemoteStorage) (*models.RemoteStorage, error) {
+	if remote.Headers == nil {
+		remote.Headers = map[string]string{}
+	}
+	if remote.RequiredMatchers == nil {
+		remote.RequiredMatchers = map[string]string{}
+	}
+	headers, err := json.Marshal(remote.Headers)
+	if err != nil {
+		return nil, err
+	}
+	requiredMatchers, err := json.Marshal(remote.RequiredMatchers)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRemoteStorage(q.QueryRow(`
+		INSERT INTO remote_storages (id, org_id, kind, name, url, instance_ids, remote_timeout, headers, username,
+		                             password, bearer_token, tls_ca_cert, tls_cert, tls_key, tls_server_name,
+		                             insecure_skip_verify, write_relabel_configs, queue_config, metadata_config,
+		                             read_recent, required_matchers)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+		RETURNING `+remoteStorageColumns,
+		remote.ID, remote.OrgID, remote.Kind, remote.Name, remote.URL, remote.InstanceIDs, remote.RemoteTimeout,
+		headers, remote.Username, remote.Password, remote.BearerToken, remote.TLSCACert, remote.TLSCert,
+		remote.TLSKey, remote.TLSServerName, remote.InsecureSkipVerify, nullableJSON(remote.WriteRelabelConfigs),
+		nullableJSON(remote.QueueConfig), nullableJSON(remote.MetadataConfig), remote.ReadRecent, requiredMatchers))
+}
+
+// 把请求中的认证设置应用到已有设置上，密钥为null时保留原值
+func applyClientAuth(auth *models.ClientAuth, req models.ClientAuthRequest) {
+	auth.Username = req.Username
+	auth.TLSCACert = req.TLSCACert
+	auth.TLSCert = req.TLSCert
+	auth.TLSServerName = req.TLSServerName
+	auth.InsecureSkipVerify = req.InsecureSkipVerify
+	if req.Password != nil {
+		auth.Password = *req.Password
+	}
+	if req.BearerToken != nil {
+		auth.BearerToken = *req.BearerToken
+	}
+	if req.TLSKey != nil {
+		auth.TLSKey = *req.TLSKey
+	}
+	setClientAuthFlags(auth)
+}
+
+func setClientAuthFlags(auth *models.ClientAuth) {
+	auth.HasPassword = auth.Password != ""
+	auth.HasBearerToken = auth.BearerToken != ""
+	auth.HasTLSKey = auth.TLSKey != ""
+}
+
+// 恢复快照时沿用当前的密钥，快照中不含密钥
+func keepSecrets(auth *models.ClientAuth, current models.ClientAuth) {
+	auth.Password = current.Password
+	auth.BearerToken = current.BearerToken
+	auth.TLSKey = current.TLSKey
+	setClientAuthFlags(auth)
+}
+
+// 路径中的 :kind 为 write 或 read，返回空字符串时已写入响应
+func remoteKind(c *gin.Context) string {
+	kind := c.Param("kind")
+	if kind != "write" && kind != "read" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown remote storage kind"})
+		return ""
+	}
+	return kind
+}
+
+// 按路径中的ID加载远程存储，返回nil时已写入响应
+func loadRemoteStorage(c *gin.Context, q querier, orgID uuid.UUID, kind string, lock bool) *models.RemoteStorage {
+	remoteUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid remote storage ID"})
+		return nil
+	}
+
+	query := `SELECT ` + remoteStorageColumns + ` FROM remote_storages WHERE id = $1 AND org_id = $2 AND kind = $3`
+	if lock {
+		query += " FOR UPDATE"
+	}
+	remote, err := scanRemoteStorage(q.QueryRow(query, remoteUUID, orgID, kind))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Remote storage not found"})
+		return nil
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get remote storage"})
+		return nil
+	}
+	return remote
+}
+
+// 把请求应用到远程存储上，并用Prometheus的配置解析检查，返回false时已写入响应
+func prepareRemoteStorage(c *gin.Context, remote *models.RemoteStorage, req models.SaveRemoteStorageRequest) bool {
+	if remote.Kind == "read" && (len(req.WriteRelabelConfigs) > 0 || len(req.QueueConfig) > 0 || len(req.MetadataConfig) > 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "write_relabel_configs, queue_config and metadata_config are only supported for remote_write"})
+		return false
+	}
+	if remote.Kind == "write" && (req.ReadRecent || len(req.RequiredMatchers) > 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "read_recent and required_matchers are only supported for remote_read"})
+		return false
+	}
+
+	remote.Name = req.Name
+	remote.URL = req.URL
+	remote.InstanceIDs = req.InstanceIDs
+	remote.RemoteTimeout = req.RemoteTimeout
+	remote.Headers = req.Headers
+	remote.WriteRelabelConfigs = req.WriteRelabelConfigs
+	remote.QueueConfig = req.QueueConfig
+	remote.MetadataConfig = req.MetadataConfig
+	remote.ReadRecent = req.ReadRecent
+	remote.RequiredMatchers = req.RequiredMatchers
+	applyClientAuth(&remote.ClientAuth, req.ClientAuthRequest)
+
+	if remote.InstanceIDs == nil {
+		remote.InstanceIDs = models.UUIDList{}
+	}
+	if remote.Headers == nil {
+		remote.Headers = map[string]string{}
+	}
+	if remote.RequiredMatchers == nil {
+		remote.RequiredMatchers = map[string]string{}
+	}
+
+	if checkErrs := prometheus.CheckRemoteStorage(remote); len(checkErrs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid remote_" + remote.Kind + " config", "errors": checkErrs})
+		return false
+	}
+	return true
+}
+
+// 名称在组织的同类远程存储中不能重复，返回false时已写入响应
+func checkRemoteStorageConflict(c *gin.Context, q querier, remote *models.RemoteStorage) bool {
+	var exists bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM remote_storages WHERE org_id = $1 AND kind = $2 AND id <> $3 AND name = $4
+		)`, remote.OrgID, remote.Kind, remote.ID, remote.Name).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "A remote_" + remote.Kind + " with this name already exists"})
+		return false
+	}
+	return true
+}
+
+// 获取 remote_write 或 remote_read 列表
+func (h *Handlers) GetRemoteStorages(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	kind := remoteKind(c)
+	if kind == "" {
+		return
+	}
+
+	remotes, err := queryRemoteStorages(h.db, orgID, kind)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get remote storages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, remotes)
+}
+
+func (h *Handlers) GetRemoteStorage(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	kind := remoteKind(c)
+	if kind == "" {
+		return
+	}
+
+	remote := loadRemoteStorage(c, h.db, orgID, kind, false)
+	if remote == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, remote)
+}
+
+func (h *Handlers) CreateRemoteStorage(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
+		return
+	}
+
+	kind := remoteKind(c)
+	if kind == "" {
+		return
+	}
+
+	var req models.SaveRemoteStorageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	remote := &models.RemoteStorage{ID: uuid.New(), OrgID: orgID, Kind: kind}
+	if !prepareRemoteStorage(c, remote, req) {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if !validateInstanceIDs(c, tx, orgID, remote.InstanceIDs) || !checkRemoteStorageConflict(c, tx, remote) {
+		return
+	}
+
+	remote, err = insertRemoteStorage(tx, remote)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create remote storage"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditCreate, "remote_"+kind, remote.ID, nil, remote); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create remote storage"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, remote)
+}
+
+func (h *Handlers) UpdateRemoteStorage(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
+		return
+	}
+
+	kind := remoteKind(c)
+	if kind == "" {
+		return
+	}
+
+	var req models.SaveRemoteStorageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before := loadRemoteStorage(c, tx, orgID, kind, true)
+	if before == nil {
+		return
+	}
+
+	remote := *before
+	if !prepareRemoteStorage(c, &remote, req) ||
+		!validateInstanceIDs(c, tx, orgID, remote.InstanceIDs) ||
+		!checkRemoteStorageConflict(c, tx, &remote) {
+		return
+	}
+
+	headers, err := json.Marshal(remote.Headers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	requiredMatchers, err := json.Marshal(remote.RequiredMatchers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	after, err := scanRemoteStorage(tx.QueryRow(`
+		UPDATE remote_storages
+		SET name = $1, url = $2, instance_ids = $3, remote_timeout = $4, headers = $5, username = $6,
+		    password = $7, bearer_token = $8, tls_ca_cert = $9, tls_cert = $10, tls_key = $11,
+		    tls_server_name = $12, insecure_skip_verify = $13, write_relabel_configs = $14,
+		    queue_config = $15, metadata_config = $16, read_recent = $17, required_matchers = $18
+		WHERE id = $19 AND org_id = $20
+		RETURNING `+remoteStorageColumns,
+		remote.Name, remote.URL, remote.InstanceIDs, remote.RemoteTimeout, headers, remote.Username,
+		remote.Password, remote.BearerToken, remote.TLSCACert, remote.TLSCert, remote.TLSKey,
+		remote.TLSServerName, remote.InsecureSkipVerify, nullableJSON(remote.WriteRelabelConfigs),
+		nullableJSON(remote.QueueConfig), nullableJSON(remote.MetadataConfig), remote.ReadRecent,
+		requiredMatchers, remote.ID, orgID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update remote storage"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditUpdate, "remote_"+kind, after.ID, before, after); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update remote storage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, after)
+}
+
+func (h *Handlers) DeleteRemoteStorage(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
+		return
+	}
+
+	kind := remoteKind(c)
+	if kind == "" {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before := loadRemoteStorage(c, tx, orgID, kind, true)
+	if before == nil {
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM remote_storages WHERE id = $1 AND org_id = $2", before.ID, orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete remote storage"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditDelete, "remote_"+kind, before.ID, before, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete remote storage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Remote storage deleted successfully"})
+}
@@ -0,0 +1,512 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 渲染后的配置文件，Secrets 为 prometheus.yml 引用的密钥文件，不记录在revision中
+type renderedConfig struct {
+	PrometheusConfig []byte
+	AlertRulesConfig []byte
+	Secrets          map[string][]byte
+}
+
+func renderSnapshot(snapshot models.ConfigSnapshot) (*renderedConfig, error) {
+	promConfig, err := prometheus.RenderConfig(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	rulesConfig, err := prometheus.RenderRules(snapshot.AlertRules)
+	if err != nil {
+		return nil, err
+	}
+
+	return &renderedConfig{
+		PrometheusConfig: promConfig,
+		AlertRulesConfig: rulesConfig,
+		Secrets:          prometheus.SecretFiles(snapshot),
+	}, nil
+}
+
+// 读取组织当前的配置数据
+func loadSnapshot(q querier, orgID uuid.UUID) (models.ConfigSnapshot, error) {
+	var snapshot models.ConfigSnapshot
+	var err error
+
+	if snapshot.Targets, err = queryTargets(q, orgID); err != nil {
+		return snapshot, err
+	}
+	if snapshot.AlertRules, err = queryAlertRules(q, orgID); err != nil {
+		return snapshot, err
+	}
+	if snapshot.RuleGroups, err = queryRuleGroups(q, orgID); err != nil {
+		return snapshot, err
+	}
+	if snapshot.GlobalConfigs, err = queryGlobalConfigs(q, orgID); err != nil {
+		return snapshot, err
+	}
+	if snapshot.RemoteStorages, err = queryRemoteStorages(q, orgID, ""); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Alertmanagers, err = queryAlertmanagers(q, orgID); err != nil {
+		return snapshot, err
+	}
+
+	return snapshot, nil
+}
+
+// 组织配置文件的输出目录
+func (h *Handlers) configDir(orgID uuid.UUID) string {
+	return filepath.Join(h.cfg.PrometheusConfigDir, orgID.String())
+}
+
+// 两份渲染结果中各配置文件的unified diff
+func diffRendered(fromName, toName string, from, to *renderedConfig) (string, error) {
+	configDiff, err := prometheus.UnifiedDiff(fromName+"/"+prometheus.ConfigFileName, toName+"/"+prometheus.ConfigFileName,
+		from.PrometheusConfig, to.PrometheusConfig)
+	if err != nil {
+		return "", err
+	}
+
+	rulesDiff, err := prometheus.UnifiedDiff(fromName+"/"+prometheus.RulesFileName, toName+"/"+prometheus.RulesFileName,
+		from.AlertRulesConfig, to.AlertRulesConfig)
+	if err != nil {
+		return "", err
+	}
+
+	return configDiff + rulesDiff, nil
+}
+
+// 查询单个revision，包含完整内容
+func getRevision(q querier, orgID, revisionID uuid.UUID) (*models.ConfigRevision, error) {
+	var revision models.ConfigRevision
+	err := q.QueryRow(`
+		SELECT r.id, r.org_id, r.instance_id, r.instance_name, r.author_id, COALESCE(u.email, ''), r.message,
+		       r.prometheus_config, r.alert_rules_config, r.snapshot, r.created_at
+		FROM config_revisions r LEFT JOIN users u ON u.id = r.author_id
+		WHERE r.id = $1 AND r.org_id = $2`, revisionID, orgID).Scan(
+		&revision.ID, &revision.OrgID, &revision.InstanceID, &revision.InstanceName, &revision.AuthorID, &revision.AuthorEmail, &revision.Message,
+		&revision.PrometheusConfig, &revision.AlertRulesConfig, &revision.Snapshot, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// 获取revision列表，不包含配置内容，instance_id 参数只返回该实例的revision
+func (h *Handlers) GetRevisions(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var instanceID *uuid.UUID
+	if value := c.Query("instance_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid instance_id"})
+			return
+		}
+		instanceID = &id
+	}
+
+	rows, err := h.db.Query(`
+		SELECT r.id, r.org_id, r.instance_id, r.instance_name, r.author_id, COALESCE(u.email, ''), r.message, r.created_at
+		FROM config_revisions r LEFT JOIN users u ON u.id = r.author_id
+		WHERE r.org_id = $1 AND ($2::uuid IS NULL OR r.instance_id = $2)
+		ORDER BY r.created_at DESC`, orgID, instanceID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revisions"})
+		return
+	}
+	defer rows.Close()
+
+	revisions := []models.ConfigRevision{}
+	for rows.Next() {
+		var revision models.ConfigRevision
+		if err := rows.Scan(&revision.ID, &revision.OrgID, &revision.InstanceID, &revision.InstanceName, &revision.AuthorID, &revision.AuthorEmail,
+			&revision.Message, &revision.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan revision"})
+			return
+		}
+		revisions = append(revisions, revision)
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+func (h *Handlers) GetRevision(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	revisionUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision ID"})
+		return
+	}
+
+	revision, err := getRevision(h.db, orgID, revisionUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revision"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
+
+// 比较两个revision的配置文件
+// against 可以是revision ID或 current（当前数据库中的配置），省略时与同一实例的上一个revision比较
+func (h *Handlers) DiffRevision(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRender) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	revisionUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision ID"})
+		return
+	}
+
+	revision, err := getRevision(h.db, orgID, revisionUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revision"})
+		return
+	}
+
+	var fromName string
+	var from renderedConfig
+
+	switch against := c.Query("against"); against {
+	case "":
+		// 实例已删除时按保留的名称查找同一实例之前的revision
+		var previousID uuid.UUID
+		err = h.db.QueryRow(`
+			SELECT id FROM config_revisions
+			WHERE org_id = $1 AND instance_id IS NOT DISTINCT FROM $2 AND ($2::uuid IS NOT NULL OR instance_name = $3)
+			  AND created_at < $4
+			ORDER BY created_at DESC LIMIT 1`, orgID, revision.InstanceID, revision.InstanceName, revision.CreatedAt).Scan(&previousID)
+		if err == sql.ErrNoRows {
+			fromName = "empty"
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revision"})
+			return
+		}
+		previous, err := getRevision(h.db, orgID, previousID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revision"})
+			return
+		}
+		fromName = previous.ID.String()
+		from = renderedConfig{[]byte(previous.PrometheusConfig), []byte(previous.AlertRulesConfig), nil}
+
+	case "current":
+		snapshot, err := loadSnapshot(h.db, orgID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load current config"})
+			return
+		}
+
+		// 只比较分配到revision所属实例的配置
+		if revision.InstanceID == nil && revision.InstanceName != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Prometheus instance of this revision has been deleted"})
+			return
+		}
+		instance := h.defaultInstance(orgID)
+		if revision.InstanceID != nil {
+			found, err := getInstance(h.db, orgID, *revision.InstanceID, false)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Prometheus instance"})
+				return
+			}
+			instance = *found
+		}
+
+		current, err := renderSnapshot(filterSnapshot(snapshot, &instance))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		fromName = "current"
+		from = *current
+
+	default:
+		againstUUID, err := uuid.Parse(against)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid against revision ID"})
+			return
+		}
+		other, err := getRevision(h.db, orgID, againstUUID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Against revision not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revision"})
+			return
+		}
+		fromName = other.ID.String()
+		from = renderedConfig{[]byte(other.PrometheusConfig), []byte(other.AlertRulesConfig), nil}
+	}
+
+	toName := revision.ID.String()
+	diff, err := diffRendered(fromName, toName, &from,
+		&renderedConfig{[]byte(revision.PrometheusConfig), []byte(revision.AlertRulesConfig), nil})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate diff"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from": fromName,
+		"to":   toName,
+		"diff": diff,
+	})
+}
+
+// 回滚到指定revision：恢复targets、告警规则和规则组分配后重新同步
+func (h *Handlers) RollbackRevision(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	for _, perm := range []rbac.Permission{rbac.PermTargetsWrite, rbac.PermRulesWrite, rbac.PermPrometheusSync} {
+		if !middleware.Authorize(c, perm) {
+			return
+		}
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
+		return
+	}
+
+	revisionUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision ID"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	revision, err := getRevision(tx, orgID, revisionUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revision"})
+		return
+	}
+
+	var snapshot models.ConfigSnapshot
+	if err := json.Unmarshal(revision.Snapshot, &snapshot); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid revision snapshot"})
+		return
+	}
+
+	if err := restoreSnapshot(tx, orgID, userID, snapshot); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+
+	// 快照包含整个组织的配置，恢复后同步全部实例，任一实例未通过检查则回滚失败
+	instances, err := h.orgInstances(tx, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Prometheus instances"})
+		return
+	}
+	if len(instances) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "No Prometheus instance to sync, add an instance first"})
+		return
+	}
+
+	results, err := h.syncInstances(tx, orgID, userID, instances, fmt.Sprintf("Rollback to revision %s", revision.ID), true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync configuration"})
+		return
+	}
+	defer discardSyncResults(results)
+
+	if syncedCount(results) == 0 {
+		writeSyncResults(c, results)
+		return
+	}
+
+	if err := recordSyncAudit(tx, c, auditRollback, gin.H{"revision_id": revision.ID}, results); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+
+	if err := publishSyncResults(results); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration rolled back but failed to write files: " + err.Error(), "results": results})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Configuration rolled back successfully",
+		"results": results,
+	})
+}
+
+// 用快照替换组织当前的targets和告警规则，保留原有ID
+// 原作者已被删除时归属到执行回滚的用户；较早的快照没有规则组分配或全局配置时保留当前的设置
+func restoreSnapshot(tx *sql.Tx, orgID, actorID uuid.UUID, snapshot models.ConfigSnapshot) error {
+	if _, err := tx.Exec("DELETE FROM targets WHERE org_id = $1", orgID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM alert_rules WHERE org_id = $1", orgID); err != nil {
+		return err
+	}
+
+	for _, target := range snapshot.Targets {
+		if _, err := tx.Exec(`
+			INSERT INTO targets (id, user_id, org_id, job_name, targets, scrape_interval, metrics_path,
+			                     relabel_configs, metric_relabel_configs, instance_ids, created_at)
+			VALUES ($1, COALESCE((SELECT id FROM users WHERE id = $2), $12), $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			target.ID, target.UserID, orgID, target.JobName, target.Targets, target.ScrapeInterval,
+			target.MetricsPath, nullableJSON(target.RelabelConfigs), nullableJSON(target.MetricRelabelConfigs),
+			target.InstanceIDs, target.CreatedAt, actorID); err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range snapshot.AlertRules {
+		if _, err := tx.Exec(`
+			INSERT INTO alert_rules (id, user_id, org_id, alert_name, group_name, expr, for_duration, labels, annotations, created_at)
+			VALUES ($1, COALESCE((SELECT id FROM users WHERE id = $2), $11), $3, $4, COALESCE(NULLIF($5, ''), $6),
+			        $7, $8, $9, $10, $12)`,
+			rule.ID, rule.UserID, orgID, rule.AlertName, rule.GroupName, prometheus.DefaultRuleGroup,
+			rule.Expr, rule.ForDuration, rule.Labels, rule.Annotations, actorID, rule.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if snapshot.RuleGroups != nil {
+		if _, err := tx.Exec("DELETE FROM rule_groups WHERE org_id = $1", orgID); err != nil {
+			return err
+		}
+		for _, group := range snapshot.RuleGroups {
+			if _, err := tx.Exec("INSERT INTO rule_groups (org_id, name, instance_ids) VALUES ($1, $2, $3)",
+				orgID, group.Name, group.InstanceIDs); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 已删除实例的全局配置不再恢复
+	if snapshot.GlobalConfigs != nil {
+		if _, err := tx.Exec("DELETE FROM prometheus_global_configs WHERE org_id = $1", orgID); err != nil {
+			return err
+		}
+		for _, global := range snapshot.GlobalConfigs {
+			if global.InstanceID != nil {
+				var exists bool
+				err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM prometheus_instances WHERE id = $1 AND org_id = $2)",
+					*global.InstanceID, orgID).Scan(&exists)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					continue
+				}
+			}
+			if _, err := saveGlobalConfig(tx, orgID, global); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 快照中没有密钥，仍存在的远程存储和Alertmanager保留当前的密钥，已删除的恢复后需要重新填写
+	if snapshot.RemoteStorages != nil {
+		current, err := queryRemoteStorages(tx, orgID, "")
+		if err != nil {
+			return err
+		}
+		secrets := make(map[uuid.UUID]models.ClientAuth, len(current))
+		for _, remote := range current {
+			secrets[remote.ID] = remote.ClientAuth
+		}
+
+		if _, err := tx.Exec("DELETE FROM remote_storages WHERE org_id = $1", orgID); err != nil {
+			return err
+		}
+		for _, remote := range snapshot.RemoteStorages {
+			remote.OrgID = orgID
+			keepSecrets(&remote.ClientAuth, secrets[remote.ID])
+			if _, err := insertRemoteStorage(tx, &remote); err != nil {
+				return err
+			}
+		}
+	}
+
+	if snapshot.Alertmanagers != nil {
+		current, err := queryAlertmanagers(tx, orgID)
+		if err != nil {
+			return err
+		}
+		secrets := make(map[uuid.UUID]models.ClientAuth, len(current))
+		for _, am := range current {
+			secrets[am.ID] = am.ClientAuth
+		}
+
+		if _, err := tx.Exec("DELETE FROM alertmanagers WHERE org_id = $1", orgID); err != nil {
+			return err
+		}
+		for _, am := range snapshot.Alertmanagers {
+			am.OrgID = orgID
+			keepSecrets(&am.ClientAuth, secrets[am.ID])
+			if _, err := insertAlertmanager(tx, &am); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// 空的JSON字段按NULL写入
+func nullableJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return []byte(raw)
+}
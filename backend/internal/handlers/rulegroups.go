@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 查询组织的规则组分配记录，按名称排序
+func queryRuleGroups(q querier, orgID uuid.UUID) ([]models.RuleGroup, error) {
+	rows, err := q.Query("SELECT name, instance_ids FROM rule_groups WHERE org_id = $1 ORDER BY name", orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []models.RuleGroup{}
+	for rows.Next() {
+		var group models.RuleGroup
+		if err := rows.Scan(&group.Name, &group.InstanceIDs); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// 获取规则组列表，包括告警规则中出现的规则组和已有分配记录的规则组
+func (h *Handlers) GetRuleGroups(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	assigned, err := queryRuleGroups(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rule groups"})
+		return
+	}
+
+	groups := make(map[string]*models.RuleGroup, len(assigned))
+	for i := range assigned {
+		groups[assigned[i].Name] = &assigned[i]
+	}
+
+	rows, err := h.db.Query("SELECT group_name, COUNT(*) FROM alert_rules WHERE org_id = $1 GROUP BY group_name", orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rule groups"})
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan rule group"})
+			return
+		}
+		if group, ok := groups[name]; ok {
+			group.RuleCount = count
+		} else {
+			groups[name] = &models.RuleGroup{Name: name, InstanceIDs: models.UUIDList{}, RuleCount: count}
+		}
+	}
+
+	result := make([]models.RuleGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	c.JSON(http.StatusOK, result)
+}
+
+// 修改规则组分配到的实例，instance_ids 为空表示全部实例
+func (h *Handlers) UpdateRuleGroup(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+	if !h.allowDirectChange(c, orgID) {
+		return
+	}
+
+	name := c.Param("name")
+
+	var req models.UpdateRuleGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.InstanceIDs == nil {
+		req.InstanceIDs = models.UUIDList{}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if !validateInstanceIDs(c, tx, orgID, req.InstanceIDs) {
+		return
+	}
+
+	var before interface{}
+	var previous models.UUIDList
+	err = tx.QueryRow("SELECT instance_ids FROM rule_groups WHERE org_id = $1 AND name = $2 FOR UPDATE",
+		orgID, name).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rule group"})
+		return
+	}
+	if err == nil {
+		before = models.RuleGroup{Name: name, InstanceIDs: previous}
+	}
+
+	group := models.RuleGroup{Name: name}
+	err = tx.QueryRow(`
+		INSERT INTO rule_groups (org_id, name, instance_ids) VALUES ($1, $2, $3)
+		ON CONFLICT (org_id, name) DO UPDATE SET instance_ids = EXCLUDED.instance_ids
+		RETURNING instance_ids`, orgID, name, req.InstanceIDs).Scan(&group.InstanceIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rule group"})
+		return
+	}
+
+	if err := recordAudit(tx, c, auditEntry{
+		Action:     auditUpdate,
+		EntityType: "rule_group",
+		EntityID:   name,
+		Before:     before,
+		After:      group,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rule group"})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
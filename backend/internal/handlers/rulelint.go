@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/common/model"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+const ruleLintSettingsColumns = `org_id, strict, allowed_severities, required_annotations, check_for,
+	rate_window_scrapes, check_aggregations, updated_at`
+
+func scanRuleLintSettings(row rowScanner) (*models.RuleLintSettings, error) {
+	var settings models.RuleLintSettings
+	var allowedSeverities, requiredAnnotations []byte
+	err := row.Scan(&settings.OrgID, &settings.Strict, &allowedSeverities, &requiredAnnotations, &settings.CheckFor,
+		&settings.RateWindowScrapes, &settings.CheckAggregations, &settings.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(allowedSeverities, &settings.AllowedSeverities); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(requiredAnnotations, &settings.RequiredAnnotations); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// 默认的检查设置，组织未保存设置时使用
+func defaultRuleLintSettings(orgID uuid.UUID) models.RuleLintSettings {
+	return models.RuleLintSettings{
+		OrgID:               orgID,
+		AllowedSeverities:   []string{"critical", "warning", "info"},
+		RequiredAnnotations: []string{"summary", "description", "runbook_url"},
+		CheckFor:            true,
+		RateWindowScrapes:   4,
+		CheckAggregations:   true,
+		Implicit:            true,
+	}
+}
+
+// 组织的检查设置，没有保存过时返回默认设置
+func getRuleLintSettings(q querier, orgID uuid.UUID) (*models.RuleLintSettings, error) {
+	settings, err := scanRuleLintSettings(q.QueryRow(`SELECT `+ruleLintSettingsColumns+`
+		FROM rule_lint_settings WHERE org_id = $1`, orgID))
+	if err == sql.ErrNoRows {
+		defaults := defaultRuleLintSettings(orgID)
+		return &defaults, nil
+	}
+	return settings, err
+}
+
+func parseScrapeInterval(value string) time.Duration {
+	d, err := model.ParseDuration(value)
+	if err != nil || d <= 0 {
+		d, _ = model.ParseDuration(prometheus.DefaultScrapeInterval)
+	}
+	return time.Duration(d)
+}
+
+// 规则的检查项，抓取间隔取规则组分配到的实例中最大的全局抓取间隔
+func (h *Handlers) ruleLintOptions(q querier, orgID uuid.UUID, settings *models.RuleLintSettings, groupName string) (prometheus.LintOptions, error) {
+	opts := prometheus.LintOptions{
+		AllowedSeverities:   settings.AllowedSeverities,
+		RequiredAnnotations: settings.RequiredAnnotations,
+		CheckFor:            settings.CheckFor,
+		RateWindowScrapes:   settings.RateWindowScrapes,
+		CheckAggregations:   settings.CheckAggregations,
+		JobScrapeIntervals:  map[string]time.Duration{},
+	}
+	if settings.RateWindowScrapes == 0 {
+		return opts, nil
+	}
+
+	instances, err := h.orgInstances(q, orgID)
+	if err != nil {
+		return opts, err
+	}
+	ruleGroups, err := queryRuleGroups(q, orgID)
+	if err != nil {
+		return opts, err
+	}
+	globals, err := queryGlobalConfigs(q, orgID)
+	if err != nil {
+		return opts, err
+	}
+
+	var assigned models.UUIDList
+	for _, group := range ruleGroups {
+		if group.Name == groupName {
+			assigned = group.InstanceIDs
+		}
+	}
+	for i := range instances {
+		if !instances[i].Implicit && !assigned.Includes(instances[i].ID) {
+			continue
+		}
+		interval := parseScrapeInterval("")
+		if global := findGlobalConfig(globals, instances[i].InstanceRef()); global != nil {
+			interval = parseScrapeInterval(global.ScrapeInterval)
+		}
+		if interval > opts.ScrapeInterval {
+			opts.ScrapeInterval = interval
+		}
+	}
+
+	targets, err := queryTargets(q, orgID)
+	if err != nil {
+		return opts, err
+	}
+	for _, target := range targets {
+		if target.ScrapeInterval == "" {
+			continue
+		}
+		interval := parseScrapeInterval(target.ScrapeInterval)
+		if interval > opts.JobScrapeIntervals[target.JobName] {
+			opts.JobScrapeIntervals[target.JobName] = interval
+		}
+	}
+	return opts, nil
+}
+
+// 按组织的设置检查告警规则，严格模式下问题的级别为 error
+func (h *Handlers) runRuleLint(q querier, orgID uuid.UUID, req models.CreateAlertRuleRequest) ([]models.LintIssue, bool, error) {
+	settings, err := getRuleLintSettings(q, orgID)
+	if err != nil {
+		return nil, false, err
+	}
+	opts, err := h.ruleLintOptions(q, orgID, settings, req.GroupName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	issues := prometheus.LintRule(req, opts)
+	if settings.Strict {
+		for i := range issues {
+			issues[i].Level = "error"
+		}
+	}
+	return issues, settings.Strict, nil
+}
+
+// 保存告警规则前检查，严格模式下有问题时返回422，返回false时已写入响应
+func (h *Handlers) lintAlertRule(c *gin.Context, q querier, orgID uuid.UUID, req models.CreateAlertRuleRequest) ([]models.LintIssue, bool) {
+	issues, strict, err := h.runRuleLint(q, orgID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lint alert rule"})
+		return nil, false
+	}
+	if strict && len(issues) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Alert rule does not follow the lint rules", "issues": issues})
+		return nil, false
+	}
+	return issues, true
+}
+
+func (h *Handlers) GetRuleLintSettings(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	settings, err := getRuleLintSettings(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rule lint settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *Handlers) SaveRuleLintSettings(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermSettingsManage) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.SaveRuleLintSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.AllowedSeverities == nil {
+		req.AllowedSeverities = []string{}
+	}
+	if req.RequiredAnnotations == nil {
+		req.RequiredAnnotations = []string{}
+	}
+	allowedSeverities, err := json.Marshal(req.AllowedSeverities)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid allowed_severities"})
+		return
+	}
+	requiredAnnotations, err := json.Marshal(req.RequiredAnnotations)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid required_annotations"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := getRuleLintSettings(tx, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rule lint settings"})
+		return
+	}
+
+	saved, err := scanRuleLintSettings(tx.QueryRow(`
+		INSERT INTO rule_lint_settings (org_id, strict, allowed_severities, required_annotations, check_for,
+		                                rate_window_scrapes, check_aggregations)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (org_id) DO UPDATE SET
+			strict = EXCLUDED.strict, allowed_severities = EXCLUDED.allowed_severities,
+			required_annotations = EXCLUDED.required_annotations, check_for = EXCLUDED.check_for,
+			rate_window_scrapes = EXCLUDED.rate_window_scrapes, check_aggregations = EXCLUDED.check_aggregations
+		RETURNING `+ruleLintSettingsColumns,
+		orgID, req.Strict, allowedSeverities, requiredAnnotations, req.CheckFor, req.RateWindowScrapes, req.CheckAggregations))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rule lint settings"})
+		return
+	}
+
+	if err := recordAudit(tx, c, auditEntry{
+		Action:     auditUpdate,
+		EntityType: "rule_lint_settings",
+		EntityID:   orgID.String(),
+		Before:     before,
+		After:      saved,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rule lint settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// 按组织的设置检查告警规则而不保存，用于编辑时提示
+func (h *Handlers) LintAlertRule(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.CreateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.GroupName == "" {
+		req.GroupName = prometheus.DefaultRuleGroup
+	}
+
+	issues, strict, err := h.runRuleLint(h.db, orgID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lint alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"strict": strict, "issues": issues})
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 告警规则状态的严重程度，用于汇总各实例的状态
+var alertStateRank = map[string]int{"inactive": 1, "pending": 2, "firing": 3}
+
+// 一个实例上 /api/v1/rules 的查询结果
+type instanceRules struct {
+	groups []prometheus.RuleGroupStatus
+	err    error
+}
+
+// 查询规则分配到的各实例，返回每个规则的运行状态
+// 每个实例只请求一次 /api/v1/rules，单个实例不可用时只影响该实例上的状态
+func (h *Handlers) alertRuleStates(ctx context.Context, orgID uuid.UUID, rules []models.AlertRule) (map[uuid.UUID]*models.AlertRuleState, error) {
+	instances, err := h.orgInstances(h.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	ruleGroups, err := queryRuleGroups(h.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	assigned := make(map[string]models.UUIDList, len(ruleGroups))
+	for _, group := range ruleGroups {
+		assigned[group.Name] = group.InstanceIDs
+	}
+
+	groupNames := make([]string, len(rules))
+	for i, rule := range rules {
+		groupNames[i] = rule.GroupName
+		if groupNames[i] == "" {
+			groupNames[i] = prometheus.DefaultRuleGroup
+		}
+	}
+
+	// 默认实例加载全部规则，其余实例只加载分配给它的规则组
+	deployed := func(instance *models.PrometheusInstance, groupName string) bool {
+		return instance.Implicit || assigned[groupName].Includes(instance.ID)
+	}
+
+	results := make([]instanceRules, len(instances))
+	forEachInstance(instances, func(i int) {
+		instance := &instances[i]
+		seen := map[string]bool{}
+		filter := []string{}
+		for _, name := range groupNames {
+			if !seen[name] && deployed(instance, name) {
+				seen[name] = true
+				filter = append(filter, name)
+			}
+		}
+		if len(filter) == 0 {
+			return
+		}
+
+		client, err := h.instanceClient(instance)
+		if err != nil {
+			results[i].err = err
+			return
+		}
+		ctx, cancel := context.WithTimeout(ctx, instanceRequestTimeout)
+		defer cancel()
+		results[i].groups, results[i].err = client.AlertingRules(ctx, filter)
+	})
+
+	states := make(map[uuid.UUID]*models.AlertRuleState, len(rules))
+	for i, rule := range rules {
+		var ruleLabels map[string]string
+		if len(rule.Labels) > 0 && string(rule.Labels) != "null" {
+			if err := json.Unmarshal(rule.Labels, &ruleLabels); err != nil {
+				return nil, err
+			}
+		}
+
+		state := &models.AlertRuleState{RuleID: rule.ID, State: "unknown", Instances: []models.AlertRuleInstanceState{}}
+		for j := range instances {
+			instance := &instances[j]
+			if !deployed(instance, groupNames[i]) {
+				continue
+			}
+
+			instanceState := models.AlertRuleInstanceState{
+				InstanceID: instance.InstanceRef(),
+				Name:       instance.Name,
+				Alerts:     []models.ActiveAlert{},
+			}
+			if results[j].err != nil {
+				instanceState.Error = results[j].err.Error()
+				state.Instances = append(state.Instances, instanceState)
+				continue
+			}
+
+			found, current := prometheus.FindAlertingRule(results[j].groups, groupNames[i], rule.AlertName, rule.Expr, ruleLabels)
+			if found == nil {
+				instanceState.Error = "Rule is not loaded, sync and reload the configuration"
+				state.Instances = append(state.Instances, instanceState)
+				continue
+			}
+
+			instanceState.State = found.State
+			instanceState.Health = found.Health
+			instanceState.LastError = found.LastError
+			instanceState.EvaluationTime = found.EvaluationTime
+			instanceState.Current = current
+			if !found.LastEvaluation.IsZero() {
+				lastEvaluation := found.LastEvaluation
+				instanceState.LastEvaluation = &lastEvaluation
+			}
+			for _, alert := range found.Alerts {
+				instanceState.Alerts = append(instanceState.Alerts, models.ActiveAlert{
+					Labels:      alert.Labels,
+					Annotations: alert.Annotations,
+					State:       alert.State,
+					ActiveAt:    alert.ActiveAt,
+					Value:       alert.Value,
+				})
+			}
+
+			state.ActiveAlerts += len(instanceState.Alerts)
+			if alertStateRank[found.State] > alertStateRank[state.State] {
+				state.State = found.State
+			}
+			state.Instances = append(state.Instances, instanceState)
+		}
+		states[rule.ID] = state
+	}
+
+	return states, nil
+}
+
+// 获取告警规则的运行状态：状态、活跃告警、最近评估时间和耗时以及评估错误
+func (h *Handlers) GetAlertRuleState(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	ruleUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	rule, err := getAlertRule(h.db, orgID, ruleUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rule"})
+		return
+	}
+
+	states, err := h.alertRuleStates(c.Request.Context(), orgID, []models.AlertRule{*rule})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rule state"})
+		return
+	}
+
+	c.JSON(http.StatusOK, states[rule.ID])
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 预览注解时模板中 query 函数最多执行的次数
+const maxTemplateQueries = 10
+
+// 解析告警规则的标签和注解，值必须是字符串
+func ruleTemplateValues(labelsJSON, annotationsJSON json.RawMessage) (map[string]string, map[string]string, error) {
+	var ruleLabels, annotations map[string]string
+	if len(labelsJSON) > 0 && string(labelsJSON) != "null" {
+		if err := json.Unmarshal(labelsJSON, &ruleLabels); err != nil {
+			return nil, nil, fmt.Errorf("labels must be an object of strings")
+		}
+	}
+	if len(annotationsJSON) > 0 && string(annotationsJSON) != "null" {
+		if err := json.Unmarshal(annotationsJSON, &annotations); err != nil {
+			return nil, nil, fmt.Errorf("annotations must be an object of strings")
+		}
+	}
+	return ruleLabels, annotations, nil
+}
+
+// 保存前用Prometheus的模板引擎解析标签和注解，避免到告警触发时才发现模板错误
+func validateAlertRuleTemplates(req models.CreateAlertRuleRequest) error {
+	ruleLabels, annotations, err := ruleTemplateValues(req.Labels, req.Annotations)
+	if err != nil {
+		return err
+	}
+	return prometheus.CheckTemplates(req.AlertName, ruleLabels, annotations)
+}
+
+// 用样本标签和值渲染告警规则的注解，查看告警中的最终文本
+func (h *Handlers) PreviewAlertRuleAnnotations(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	ruleUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var req models.AnnotationsPreviewRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	rule, err := getAlertRule(h.db, orgID, ruleUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rule"})
+		return
+	}
+
+	ruleLabels, annotations, err := ruleTemplateValues(rule.Labels, rule.Annotations)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid alert rule: " + err.Error()})
+		return
+	}
+	if req.Annotations != nil {
+		annotations = req.Annotations
+	}
+
+	// 模板中的 query 函数查询 instance_id 指定的实例，未指定时使用第一个实例，没有可用实例时 query 返回错误
+	var client *prometheus.Client
+	if c.Query("instance_id") != "" {
+		if client = h.prometheusClient(c, orgID); client == nil {
+			return
+		}
+	} else if instances, err := h.orgInstances(h.db, orgID); err == nil && len(instances) > 0 {
+		client, _ = h.instanceClient(&instances[0])
+	}
+
+	// query 函数与查询接口共用每个用户的并发限制和查询超时
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !h.queries.acquire(userID) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many concurrent queries"})
+		return
+	}
+	defer h.queries.release(userID)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.cfg.PrometheusQueryTimeout)
+	defer cancel()
+
+	result, err := prometheus.RenderTemplates(ctx, client, rule.AlertName, ruleLabels, annotations, prometheus.TemplateSample{
+		Labels:         req.Labels,
+		Value:          req.Value,
+		ExternalLabels: req.ExternalLabels,
+		ExternalURL:    req.ExternalURL,
+	}, maxTemplateQueries)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
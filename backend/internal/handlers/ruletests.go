@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+const alertRuleTestColumns = `id, org_id, user_id, rule_id, group_name, name, spec, created_at, updated_at`
+
+func scanAlertRuleTest(row rowScanner) (*models.AlertRuleTest, error) {
+	var test models.AlertRuleTest
+	var spec []byte
+	err := row.Scan(&test.ID, &test.OrgID, &test.UserID, &test.RuleID, &test.GroupName, &test.Name,
+		&spec, &test.CreatedAt, &test.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	test.Spec = spec
+	return &test, nil
+}
+
+// 查询组织下的测试，ruleID 不为空时只返回该规则的测试
+func queryAlertRuleTests(q querier, orgID uuid.UUID, ruleID *uuid.UUID) ([]models.AlertRuleTest, error) {
+	query := "SELECT " + alertRuleTestColumns + " FROM alert_rule_tests WHERE org_id = $1"
+	args := []interface{}{orgID}
+	if ruleID != nil {
+		query += " AND rule_id = $2"
+		args = append(args, *ruleID)
+	}
+	query += " ORDER BY created_at, id"
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tests := []models.AlertRuleTest{}
+	for rows.Next() {
+		test, err := scanAlertRuleTest(rows)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, *test)
+	}
+	return tests, rows.Err()
+}
+
+// 用例未写 alertname 时使用所属规则的告警名
+func fillAlertnames(spec *models.RuleTestSpec, alertName string) {
+	for i := range spec.AlertRuleTests {
+		if spec.AlertRuleTests[i].Alertname == "" {
+			spec.AlertRuleTests[i].Alertname = alertName
+		}
+	}
+}
+
+// 检查要保存的测试，关联规则时补全 alertname，返回nil时已写入响应
+func prepareAlertRuleTest(c *gin.Context, q querier, orgID uuid.UUID) *models.SaveAlertRuleTestRequest {
+	var req models.SaveAlertRuleTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil
+	}
+
+	if req.RuleID != nil {
+		var alertName string
+		err := q.QueryRow("SELECT alert_name FROM alert_rules WHERE id = $1 AND org_id = $2",
+			*req.RuleID, orgID).Scan(&alertName)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Alert rule not found"})
+			return nil
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return nil
+		}
+		fillAlertnames(&req.Spec, alertName)
+	}
+
+	if err := prometheus.ValidateRuleTestSpec(req.Spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil
+	}
+
+	return &req
+}
+
+func (h *Handlers) GetAlertRuleTests(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var ruleID *uuid.UUID
+	if value := c.Query("rule_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+			return
+		}
+		ruleID = &id
+	}
+
+	tests, err := queryAlertRuleTests(h.db, orgID, ruleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rule tests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tests)
+}
+
+func (h *Handlers) CreateAlertRuleTest(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	req := prepareAlertRuleTest(c, h.db, orgID)
+	if req == nil {
+		return
+	}
+
+	spec, err := json.Marshal(req.Spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid test spec"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	test, err := scanAlertRuleTest(tx.QueryRow(`
+		INSERT INTO alert_rule_tests (org_id, user_id, rule_id, group_name, name, spec)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+alertRuleTestColumns,
+		orgID, userID, req.RuleID, req.GroupName, req.Name, spec))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert rule test"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditCreate, "alert_rule_test", test.ID, nil, test); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert rule test"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, test)
+}
+
+func (h *Handlers) UpdateAlertRuleTest(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	testUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid test ID"})
+		return
+	}
+
+	req := prepareAlertRuleTest(c, h.db, orgID)
+	if req == nil {
+		return
+	}
+
+	spec, err := json.Marshal(req.Spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid test spec"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := scanAlertRuleTest(tx.QueryRow(
+		"SELECT "+alertRuleTestColumns+" FROM alert_rule_tests WHERE id = $1 AND org_id = $2 FOR UPDATE",
+		testUUID, orgID))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule test not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rule test"})
+		return
+	}
+
+	test, err := scanAlertRuleTest(tx.QueryRow(`
+		UPDATE alert_rule_tests SET rule_id = $1, group_name = $2, name = $3, spec = $4
+		WHERE id = $5 AND org_id = $6 RETURNING `+alertRuleTestColumns,
+		req.RuleID, req.GroupName, req.Name, spec, testUUID, orgID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert rule test"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditUpdate, "alert_rule_test", test.ID, before, test); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert rule test"})
+		return
+	}
+
+	c.JSON(http.StatusOK, test)
+}
+
+func (h *Handlers) DeleteAlertRuleTest(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	testUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid test ID"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := scanAlertRuleTest(tx.QueryRow(
+		"SELECT "+alertRuleTestColumns+" FROM alert_rule_tests WHERE id = $1 AND org_id = $2 FOR UPDATE",
+		testUUID, orgID))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule test not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rule test"})
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM alert_rule_tests WHERE id = $1 AND org_id = $2", testUUID, orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule test"})
+		return
+	}
+
+	if err := recordChange(tx, c, auditDelete, "alert_rule_test", before.ID, before, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule test"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule test deleted successfully"})
+}
+
+// 在内存中执行告警规则单元测试，不依赖运行中的Prometheus
+// 规则使用组织当前的告警规则，指定 change_set_id 时使用变更集应用后的规则
+func (h *Handlers) RunAlertRuleTests(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRender) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.RunRuleTestsRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var items []models.ChangeSetItem
+	if req.ChangeSetID != nil {
+		cs, err := getChangeSet(h.db, orgID, *req.ChangeSetID, false)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Change set not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set"})
+			return
+		}
+
+		if items, err = queryChangeSetItems(h.db, cs.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set items"})
+			return
+		}
+	}
+
+	snapshot, err := loadSnapshot(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load configuration"})
+		return
+	}
+
+	snapshot, err = applyItemsToSnapshot(snapshot, items)
+	if errors.Is(err, errChangeConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	rulesConfig, err := prometheus.RenderRules(snapshot.AlertRules)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render alert rules"})
+		return
+	}
+	ruleFiles := map[string][]byte{prometheus.RulesFileName: rulesConfig}
+
+	alertNames := make(map[uuid.UUID]string, len(snapshot.AlertRules))
+	for _, rule := range snapshot.AlertRules {
+		alertNames[rule.ID] = rule.AlertName
+	}
+
+	// 请求中直接提供的测试与保存的测试使用同样的结构执行
+	type pendingTest struct {
+		result    models.RuleTestResult
+		groupName string
+		spec      models.RuleTestSpec
+	}
+	var tests []pendingTest
+	if len(req.Tests) > 0 {
+		for _, test := range req.Tests {
+			tests = append(tests, pendingTest{
+				result:    models.RuleTestResult{RuleID: test.RuleID, Name: test.Name},
+				groupName: test.GroupName,
+				spec:      test.Spec,
+			})
+		}
+	} else {
+		stored, err := queryAlertRuleTests(h.db, orgID, req.RuleID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rule tests"})
+			return
+		}
+
+		selected := make(map[uuid.UUID]bool, len(req.TestIDs))
+		for _, id := range req.TestIDs {
+			selected[id] = true
+		}
+
+		for _, test := range stored {
+			if len(selected) > 0 && !selected[test.ID] {
+				continue
+			}
+
+			var spec models.RuleTestSpec
+			if err := json.Unmarshal(test.Spec, &spec); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid stored test spec"})
+				return
+			}
+
+			testID := test.ID
+			tests = append(tests, pendingTest{
+				result:    models.RuleTestResult{TestID: &testID, RuleID: test.RuleID, Name: test.Name},
+				groupName: test.GroupName,
+				spec:      spec,
+			})
+		}
+	}
+
+	passed := true
+	results := make([]models.RuleTestResult, 0, len(tests))
+	for _, test := range tests {
+		result, spec := test.result, test.spec
+		result.Cases = []models.RuleTestCaseResult{}
+
+		if result.RuleID != nil {
+			alertName, ok := alertNames[*result.RuleID]
+			if !ok {
+				result.Error = "Alert rule not found"
+				passed = false
+				results = append(results, result)
+				continue
+			}
+			fillAlertnames(&spec, alertName)
+		}
+
+		cases, err := prometheus.RunRuleTest(ruleFiles, test.groupName, spec)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Cases = cases
+			result.Passed = true
+			for _, tc := range cases {
+				result.Passed = result.Passed && tc.Passed
+			}
+		}
+
+		passed = passed && result.Passed
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"passed": passed, "results": results})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/secrets"
+)
+
+// 加密后保存的API Key，Key为空时各字段都为空
+type encryptedAPIKey struct {
+	Ciphertext *string
+	Version    int
+	Hint       *string
+}
+
+func (h *Handlers) encryptAIAPIKey(userID uuid.UUID, apiKey string) (encryptedAPIKey, error) {
+	if apiKey == "" {
+		return encryptedAPIKey{}, nil
+	}
+	ciphertext, err := h.keyring.Encrypt(apiKey, secrets.AIAPIKeyAAD(userID))
+	if err != nil {
+		return encryptedAPIKey{}, err
+	}
+	hint := secrets.Mask(apiKey)
+	return encryptedAPIKey{Ciphertext: &ciphertext, Version: h.keyring.ActiveVersion(), Hint: &hint}, nil
+}
+
+// 保存AI设置时使用的API Key：提供了api_key时加密新的Key，否则在提供商和base_url都未改变时保留已保存的Key，
+// 改变时丢弃已保存的Key，避免把它发送到新的地址
+func (h *Handlers) resolveAIAPIKey(userID uuid.UUID, req *models.SaveAISettingsRequest, saved *models.AISettings) (string, encryptedAPIKey, error) {
+	if req.APIKey != nil {
+		encrypted, err := h.encryptAIAPIKey(userID, *req.APIKey)
+		return *req.APIKey, encrypted, err
+	}
+	if !sameAIEndpoint(saved, req.Provider, req.BaseURL) {
+		return "", encryptedAPIKey{}, nil
+	}
+	plaintext, err := h.aiAPIKey(saved)
+	return plaintext, encryptedAPIKey{Ciphertext: saved.EncryptedAPIKey, Version: saved.APIKeyVersion, Hint: saved.APIKeyHint}, err
+}
+
+// 解密用户的TOTP密钥
+func (h *Handlers) totpSecret(userID uuid.UUID, ciphertext string) (string, error) {
+	return h.keyring.Decrypt(ciphertext, secrets.TOTPSecretAAD(userID))
+}
+
+// 解密AI设置中的API Key，未设置时返回空字符串
+func (h *Handlers) aiAPIKey(settings *models.AISettings) (string, error) {
+	if settings.EncryptedAPIKey == nil {
+		return "", nil
+	}
+	return h.keyring.Decrypt(*settings.EncryptedAPIKey, secrets.AIAPIKeyAAD(settings.UserID))
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/common/model"
+	"promeconfig-backend/internal/alertmanager"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 未指定结束时间和时长时静默持续的时间
+const defaultSilenceDuration = 2 * time.Hour
+
+// 组织的Alertmanager客户端，返回nil时已写入响应
+func (h *Handlers) orgAlertmanagerClient(c *gin.Context, orgID uuid.UUID) *alertmanager.Client {
+	settings, err := h.getAlertmanagerSettings(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Alertmanager settings"})
+		return nil
+	}
+
+	client, err := alertmanagerClient(settings)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return nil
+	}
+	return client
+}
+
+// Alertmanager拒绝请求时原样返回其状态码和错误，无法访问时返回502
+func writeAlertmanagerError(c *gin.Context, err error) {
+	var apiErr *alertmanager.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 {
+		c.JSON(apiErr.StatusCode, gin.H{"error": apiErr.Message})
+		return
+	}
+	c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+}
+
+// 静默的 createdBy 末尾标记创建它的组织，多个组织共用一个Alertmanager时只能查看和过期本组织创建的静默
+func silenceCreator(email string, orgID uuid.UUID) string {
+	return email + silenceOrgSuffix(orgID)
+}
+
+func silenceOrgSuffix(orgID uuid.UUID) string {
+	return " (org " + orgID.String() + ")"
+}
+
+func ownSilence(silence *alertmanager.Silence, orgID uuid.UUID) bool {
+	return strings.HasSuffix(silence.CreatedBy, silenceOrgSuffix(orgID))
+}
+
+// 加载本组织创建的静默，返回nil时已写入响应
+func loadOwnSilence(ctx context.Context, c *gin.Context, client *alertmanager.Client, orgID uuid.UUID) *alertmanager.Silence {
+	silence, err := client.Silence(ctx, c.Param("id"))
+	if err != nil {
+		writeAlertmanagerError(c, err)
+		return nil
+	}
+	if !ownSilence(silence, orgID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Silence not found"})
+		return nil
+	}
+	return silence
+}
+
+// 规则的 alertname 和标签对应的matchers，值中含模板的标签在告警中才确定，不能用于匹配
+func ruleSilenceMatchers(rule *models.AlertRule) ([]alertmanager.Matcher, error) {
+	ruleLabels := map[string]string{}
+	if len(rule.Labels) > 0 && string(rule.Labels) != "null" {
+		if err := json.Unmarshal(rule.Labels, &ruleLabels); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(ruleLabels))
+	for name := range ruleLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matchers := []alertmanager.Matcher{{Name: "alertname", Value: rule.AlertName, IsEqual: true}}
+	for _, name := range names {
+		if strings.Contains(ruleLabels[name], "{{") {
+			continue
+		}
+		matchers = append(matchers, alertmanager.Matcher{Name: name, Value: ruleLabels[name], IsEqual: true})
+	}
+	return matchers, nil
+}
+
+// 获取静默列表，filter 参数与Alertmanager相同（如 filter=alertname="HighCPU"），state 按状态过滤
+func (h *Handlers) GetSilences(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	client := h.orgAlertmanagerClient(c, orgID)
+	if client == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), instanceRequestTimeout)
+	defer cancel()
+
+	silences, err := client.Silences(ctx, c.QueryArray("filter"))
+	if err != nil {
+		writeAlertmanagerError(c, err)
+		return
+	}
+
+	state := c.Query("state")
+	filtered := []alertmanager.Silence{}
+	for _, silence := range silences {
+		if !ownSilence(&silence, orgID) {
+			continue
+		}
+		if state == "" || (silence.Status != nil && silence.Status.State == state) {
+			filtered = append(filtered, silence)
+		}
+	}
+	silences = filtered
+
+	c.JSON(http.StatusOK, silences)
+}
+
+func (h *Handlers) GetSilence(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermConfigRead) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	client := h.orgAlertmanagerClient(c, orgID)
+	if client == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), instanceRequestTimeout)
+	defer cancel()
+
+	silence := loadOwnSilence(ctx, c, client, orgID)
+	if silence == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, silence)
+}
+
+// 创建静默，createdBy 为当前用户的邮箱加上组织标记
+func (h *Handlers) CreateSilence(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.CreateSilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	silence := alertmanager.Silence{Comment: req.Comment, StartsAt: time.Now().UTC()}
+	if req.StartsAt != nil {
+		silence.StartsAt = *req.StartsAt
+	}
+	switch {
+	case req.EndsAt != nil:
+		silence.EndsAt = *req.EndsAt
+	case req.Duration != "":
+		d, err := model.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duration"})
+			return
+		}
+		silence.EndsAt = silence.StartsAt.Add(time.Duration(d))
+	default:
+		silence.EndsAt = silence.StartsAt.Add(defaultSilenceDuration)
+	}
+	if !silence.EndsAt.After(silence.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+		return
+	}
+
+	if req.AlertRuleID != nil {
+		rule, err := getAlertRule(h.db, orgID, *req.AlertRuleID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rule"})
+			return
+		}
+		if silence.Matchers, err = ruleSilenceMatchers(rule); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid alert rule labels"})
+			return
+		}
+	}
+	for _, m := range req.Matchers {
+		matcher := alertmanager.Matcher{Name: m.Name, Value: m.Value, IsRegex: m.IsRegex, IsEqual: m.IsEqual == nil || *m.IsEqual}
+		replaced := false
+		for i := range silence.Matchers {
+			if silence.Matchers[i].Name == m.Name {
+				silence.Matchers[i] = matcher
+				replaced = true
+			}
+		}
+		if !replaced {
+			silence.Matchers = append(silence.Matchers, matcher)
+		}
+	}
+	if len(silence.Matchers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "matchers or alert_rule_id is required"})
+		return
+	}
+
+	var email string
+	if err := h.db.QueryRow("SELECT email FROM users WHERE id = $1", userID).Scan(&email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	silence.CreatedBy = silenceCreator(email, orgID)
+
+	client := h.orgAlertmanagerClient(c, orgID)
+	if client == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), instanceRequestTimeout)
+	defer cancel()
+
+	id, err := client.CreateSilence(ctx, silence)
+	if err != nil {
+		writeAlertmanagerError(c, err)
+		return
+	}
+	silence.ID = id
+
+	// 静默已在Alertmanager中生效，审计日志写入失败只记录在响应中
+	if err := recordAudit(h.db, c, auditEntry{
+		Action:     auditCreate,
+		EntityType: "silence",
+		EntityID:   silence.ID,
+		After:      silence,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Silence created but failed to write audit log", "silence": silence})
+		return
+	}
+
+	c.JSON(http.StatusCreated, silence)
+}
+
+// 让静默立即过期
+func (h *Handlers) ExpireSilence(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermRulesWrite) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	client := h.orgAlertmanagerClient(c, orgID)
+	if client == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), instanceRequestTimeout)
+	defer cancel()
+
+	before := loadOwnSilence(ctx, c, client, orgID)
+	if before == nil {
+		return
+	}
+	id := before.ID
+	if err := client.ExpireSilence(ctx, id); err != nil {
+		writeAlertmanagerError(c, err)
+		return
+	}
+
+	if err := recordAudit(h.db, c, auditEntry{
+		Action:     auditExpire,
+		EntityType: "silence",
+		EntityID:   id,
+		Before:     before,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Silence expired but failed to write audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Silence expired successfully"})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/alertmanager"
+	"promeconfig-backend/internal/models"
+)
+
+func TestRuleSilenceMatchers(t *testing.T) {
+	alertname := alertmanager.Matcher{Name: "alertname", Value: "HighCPU", IsEqual: true}
+	tests := []struct {
+		name   string
+		labels string
+		want   []alertmanager.Matcher
+	}{
+		{"no labels", "", []alertmanager.Matcher{alertname}},
+		{"null labels", "null", []alertmanager.Matcher{alertname}},
+		{
+			name:   "labels sorted by name",
+			labels: `{"team": "infra", "severity": "critical"}`,
+			want: []alertmanager.Matcher{
+				alertname,
+				{Name: "severity", Value: "critical", IsEqual: true},
+				{Name: "team", Value: "infra", IsEqual: true},
+			},
+		},
+		{
+			name:   "templated labels skipped",
+			labels: `{"severity": "{{ if gt $value 90.0 }}critical{{ else }}warning{{ end }}", "team": "infra"}`,
+			want:   []alertmanager.Matcher{alertname, {Name: "team", Value: "infra", IsEqual: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &models.AlertRule{AlertName: "HighCPU", Labels: json.RawMessage(tt.labels)}
+			got, err := ruleSilenceMatchers(rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ruleSilenceMatchers(&models.AlertRule{AlertName: "HighCPU", Labels: json.RawMessage(`[1]`)}); err == nil {
+		t.Error("expected an error for invalid labels")
+	}
+}
+
+func TestWriteAlertmanagerError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantError  string
+	}{
+		{"client error passed through", &alertmanager.APIError{StatusCode: http.StatusBadRequest, Message: "invalid matcher"}, http.StatusBadRequest, "invalid matcher"},
+		{"not found passed through", &alertmanager.APIError{StatusCode: http.StatusNotFound, Message: "silence not found"}, http.StatusNotFound, "silence not found"},
+		{"server error", &alertmanager.APIError{StatusCode: http.StatusInternalServerError, Message: "boom"}, http.StatusBadGateway, "alertmanager returned 500: boom"},
+		{"unreachable", errors.New("connection refused"), http.StatusBadGateway, "connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			writeAlertmanagerError(c, tt.err)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["error"] != tt.wantError {
+				t.Errorf("error = %q, want %q", body["error"], tt.wantError)
+			}
+		})
+	}
+}
+
+func TestOwnSilence(t *testing.T) {
+	orgID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	otherID := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+
+	created := &alertmanager.Silence{CreatedBy: silenceCreator("a@example.com", orgID)}
+	if created.CreatedBy != "a@example.com (org 11111111-1111-1111-1111-111111111111)" {
+		t.Errorf("createdBy = %q", created.CreatedBy)
+	}
+	if !ownSilence(created, orgID) {
+		t.Error("silence created by the organization not recognized")
+	}
+	if ownSilence(created, otherID) {
+		t.Error("silence of another organization recognized")
+	}
+	// 不是通过本服务创建的静默不属于任何组织
+	if ownSilence(&alertmanager.Silence{CreatedBy: "admin"}, orgID) {
+		t.Error("untagged silence recognized")
+	}
+}
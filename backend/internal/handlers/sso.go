@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/auth"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 保存OIDC登录中间状态的cookie
+const oidcCookieName = "promeconfig_oidc"
+
+// 外部身份的邮箱已被未验证的本地账号使用
+var errUnverifiedAccount = errors.New("an unverified account with this email already exists")
+
+// 外部身份首次登录时没有已验证的邮箱，无法创建账号
+var errNoVerifiedEmail = errors.New("identity has no verified email")
+
+// 获取可用的登录方式
+func (h *Handlers) GetAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"local": h.cfg.LocalAuthEnabled,
+		"ldap":  h.cfg.LDAPURL != "",
+		"oidc":  h.oidc != nil,
+	})
+}
+
+// 发起OIDC登录，跳转到身份提供方
+func (h *Handlers) OIDCLogin(c *gin.Context) {
+	if h.oidc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	authReq, err := h.oidc.Begin(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to contact identity provider"})
+		return
+	}
+
+	// state、nonce和PKCE verifier签名后存入cookie，回调时校验
+	stateToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"state":    authReq.State,
+		"nonce":    authReq.Nonce,
+		"verifier": authReq.CodeVerifier,
+		"exp":      time.Now().Add(10 * time.Minute).Unix(),
+	}).SignedString([]byte(h.cfg.JWTSecret))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcCookieName, stateToken, 600, "/api/auth/oidc", "", h.cfg.Environment == "production", true)
+	c.Redirect(http.StatusFound, authReq.URL)
+}
+
+// OIDC回调，完成授权码交换并签发本系统的token
+func (h *Handlers) OIDCCallback(c *gin.Context) {
+	if h.oidc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	if errParam := c.Query("error"); errParam != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider returned error: " + errParam})
+		return
+	}
+
+	stateToken, err := c.Cookie(oidcCookieName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login session not found"})
+		return
+	}
+	c.SetCookie(oidcCookieName, "", -1, "/api/auth/oidc", "", h.cfg.Environment == "production", true)
+
+	token, err := jwt.Parse(stateToken, func(token *jwt.Token) (interface{}, error) {
+		return []byte(h.cfg.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login session expired"})
+		return
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	state, _ := claims["state"].(string)
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["verifier"].(string)
+
+	if state == "" || c.Query("state") != state {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state"})
+		return
+	}
+
+	identity, err := h.oidc.Exchange(c.Request.Context(), c.Query("code"), verifier, nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "OIDC authentication failed"})
+		return
+	}
+
+	user, err := h.provisionExternalUser(identity)
+	if errors.Is(err, errUnverifiedAccount) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An unverified account with this email already exists, verify it before signing in with SSO"})
+		return
+	}
+	if errors.Is(err, errNoVerifiedEmail) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Identity provider did not return a verified email"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to provision user"})
+		return
+	}
+
+	var totpEnabled bool
+	if err := h.db.QueryRow("SELECT totp_enabled FROM users WHERE id = $1", user.ID).Scan(&totpEnabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// 关联的账号开启了两步验证时与密码登录相同，先返回challenge token
+	if totpEnabled {
+		challenge, err := h.generateMFAChallenge(user.ID, identity.Provider)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		if h.cfg.OIDCPostLoginRedirect != "" {
+			fragment := url.Values{"challenge_token": {challenge}, "token_type": {"mfa_required"}}
+			c.Redirect(http.StatusFound, h.cfg.OIDCPostLoginRedirect+"#"+fragment.Encode())
+			return
+		}
+		c.JSON(http.StatusOK, models.MFAChallengeResponse{
+			MFARequired:    true,
+			ChallengeToken: challenge,
+			TokenType:      "mfa_required",
+		})
+		return
+	}
+
+	accessToken, err := h.generateToken(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	h.recordSignIn(c, user.ID, user.Email, identity.Provider, true)
+
+	// 配置了前端地址时通过URL fragment把token交给前端
+	if h.cfg.OIDCPostLoginRedirect != "" {
+		fragment := url.Values{"access_token": {accessToken}, "token_type": {"Bearer"}}
+		c.Redirect(http.StatusFound, h.cfg.OIDCPostLoginRedirect+"#"+fragment.Encode())
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AuthResponse{
+		User:        user,
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+	})
+}
+
+// 按外部身份查找或创建用户，并同步其在SSO组织中的角色
+// 优先按 provider + subject 匹配，其次按邮箱关联已有账号
+func (h *Handlers) provisionExternalUser(identity *auth.Identity) (models.User, error) {
+	var user models.User
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return user, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		SELECT id, email, created_at, updated_at FROM users
+		WHERE external_provider = $1 AND external_subject = $2`,
+		identity.Provider, identity.Subject).Scan(&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+
+	// 只关联已验证邮箱的账号，未验证的账号可能是他人抢先用该邮箱注册的
+	if err == sql.ErrNoRows && identity.Email != "" {
+		var verified bool
+		err = tx.QueryRow(`
+			SELECT id, email, verified, created_at, updated_at FROM users WHERE email = $1 FOR UPDATE`,
+			identity.Email).Scan(&user.ID, &user.Email, &verified, &user.CreatedAt, &user.UpdatedAt)
+		if err == nil && !verified {
+			return user, errUnverifiedAccount
+		}
+		if err == nil {
+			_, err = tx.Exec(`
+				UPDATE users SET external_provider = $1, external_subject = $2 WHERE id = $3`,
+				identity.Provider, identity.Subject, user.ID)
+		}
+	}
+
+	if err == sql.ErrNoRows {
+		if identity.Email == "" {
+			return user, errNoVerifiedEmail
+		}
+		err = tx.QueryRow(`
+			INSERT INTO users (email, external_provider, external_subject, verified)
+			VALUES ($1, $2, $3, TRUE)
+			RETURNING id, email, created_at, updated_at`,
+			identity.Email, identity.Provider, identity.Subject).Scan(&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	}
+
+	if err != nil {
+		return user, err
+	}
+
+	orgID, err := h.ensureSSOOrganization(tx)
+	if err != nil {
+		return user, err
+	}
+
+	// 用户组命中映射时以映射为准，否则只为新成员设置默认角色
+	if role, ok := auth.MapGroupsToRole(identity.Groups, h.cfg.SSOGroupRoleMap); ok {
+		_, err = tx.Exec(`
+			INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, $3)
+			ON CONFLICT (org_id, user_id) DO UPDATE SET role = EXCLUDED.role`,
+			orgID, user.ID, role)
+	} else {
+		defaultRole := rbac.Role(h.cfg.SSODefaultRole)
+		if !defaultRole.Valid() {
+			defaultRole = rbac.RoleViewer
+		}
+		_, err = tx.Exec(`
+			INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, $3)
+			ON CONFLICT (org_id, user_id) DO NOTHING`,
+			orgID, user.ID, defaultRole)
+	}
+
+	if err != nil {
+		return user, err
+	}
+
+	return user, tx.Commit()
+}
+
+// 获取SSO用户加入的组织：优先使用 SSO_ORGANIZATION_ID，否则使用标记为SSO的组织，不存在时以 SSO_ORGANIZATION 为名称创建
+// 不按名称查找，组织管理员可以修改自己组织的名称
+func (h *Handlers) ensureSSOOrganization(tx *sql.Tx) (uuid.UUID, error) {
+	if h.cfg.SSOOrganizationID != "" {
+		orgID, err := uuid.Parse(h.cfg.SSOOrganizationID)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("invalid SSO_ORGANIZATION_ID: %w", err)
+		}
+		err = tx.QueryRow("SELECT id FROM organizations WHERE id = $1", orgID).Scan(&orgID)
+		if err == sql.ErrNoRows {
+			return uuid.Nil, fmt.Errorf("SSO organization %s not found", orgID)
+		}
+		return orgID, err
+	}
+
+	var orgID uuid.UUID
+	err := tx.QueryRow("SELECT id FROM organizations WHERE sso").Scan(&orgID)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`
+			INSERT INTO organizations (name, sso) VALUES ($1, TRUE) RETURNING id`, h.cfg.SSOOrganization).Scan(&orgID)
+	}
+
+	return orgID, err
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"promeconfig-backend/internal/auth"
+	"promeconfig-backend/internal/config"
+)
+
+func TestOIDCCallbackState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 身份提供方不可达，通过state校验后在换取token时失败
+	h := &Handlers{
+		cfg:  &config.Config{JWTSecret: "secret"},
+		oidc: auth.NewOIDC(auth.OIDCConfig{Issuer: "http://127.0.0.1:1", ClientID: "promeconfig"}),
+	}
+	session := func(secret string, exp time.Time) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"state":    "expected-state",
+			"nonce":    "nonce",
+			"verifier": "verifier",
+			"exp":      exp.Unix(),
+		}).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := session("secret", time.Now().Add(time.Minute))
+
+	tests := []struct {
+		name       string
+		query      string
+		cookie     string
+		wantStatus int
+		wantError  string
+	}{
+		{"identity provider error", "?error=access_denied", valid, http.StatusUnauthorized, "Identity provider returned error: access_denied"},
+		{"no session cookie", "?state=expected-state&code=c", "", http.StatusBadRequest, "Login session not found"},
+		{"session signed with another secret", "?state=expected-state&code=c", session("other", time.Now().Add(time.Minute)), http.StatusBadRequest, "Login session expired"},
+		{"expired session", "?state=expected-state&code=c", session("secret", time.Now().Add(-time.Minute)), http.StatusBadRequest, "Login session expired"},
+		{"state mismatch", "?state=forged&code=c", valid, http.StatusBadRequest, "Invalid state"},
+		{"missing state", "?code=c", valid, http.StatusBadRequest, "Invalid state"},
+		{"valid state", "?state=expected-state&code=c", valid, http.StatusUnauthorized, "OIDC authentication failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback"+tt.query, nil)
+			if tt.cookie != "" {
+				c.Request.AddCookie(&http.Cookie{Name: oidcCookieName, Value: tt.cookie})
+			}
+			h.OIDCCallback(c)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["error"] != tt.wantError {
+				t.Errorf("error = %q, want %q", body["error"], tt.wantError)
+			}
+		})
+	}
+}
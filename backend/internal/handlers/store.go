@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/models"
+)
+
+// db和tx共用的查询接口
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// 查询组织下的全部targets
+func queryTargets(q querier, orgID uuid.UUID) ([]models.Target, error) {
+	rows, err := q.Query(`
+		SELECT id, user_id, org_id, job_name, targets, scrape_interval, metrics_path, 
+		       relabel_configs, metric_relabel_configs, instance_ids, created_at, updated_at
+		FROM targets WHERE org_id = $1 ORDER BY created_at DESC, id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []models.Target{}
+	for rows.Next() {
+		var target models.Target
+		var relabelConfigs, metricRelabelConfigs sql.NullString
+
+		err := rows.Scan(&target.ID, &target.UserID, &target.OrgID, &target.JobName, &target.Targets,
+			&target.ScrapeInterval, &target.MetricsPath, &relabelConfigs, &metricRelabelConfigs,
+			&target.InstanceIDs, &target.CreatedAt, &target.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if relabelConfigs.Valid {
+			target.RelabelConfigs = json.RawMessage(relabelConfigs.String)
+		}
+		if metricRelabelConfigs.Valid {
+			target.MetricRelabelConfigs = json.RawMessage(metricRelabelConfigs.String)
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets, rows.Err()
+}
+
+// 查询组织下的全部告警规则
+func queryAlertRules(q querier, orgID uuid.UUID) ([]models.AlertRule, error) {
+	rows, err := q.Query(`
+		SELECT id, user_id, org_id, alert_name, group_name, expr, for_duration, labels, annotations, created_at, updated_at
+		FROM alert_rules WHERE org_id = $1 ORDER BY created_at DESC, id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.AlertRule{}
+	for rows.Next() {
+		var rule models.AlertRule
+		err := rows.Scan(&rule.ID, &rule.UserID, &rule.OrgID, &rule.AlertName, &rule.GroupName, &rule.Expr,
+			&rule.ForDuration, &rule.Labels, &rule.Annotations, &rule.CreatedAt, &rule.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// 查询单个target并加行锁，用于修改前记录原值
+func getTargetForUpdate(q querier, orgID, targetID uuid.UUID) (*models.Target, error) {
+	var target models.Target
+	err := q.QueryRow(`
+		SELECT id, user_id, org_id, job_name, targets, scrape_interval, metrics_path,
+		       relabel_configs, metric_relabel_configs, instance_ids, created_at, updated_at
+		FROM targets WHERE id = $1 AND org_id = $2 FOR UPDATE`, targetID, orgID).Scan(
+		&target.ID, &target.UserID, &target.OrgID, &target.JobName, &target.Targets, &target.ScrapeInterval,
+		&target.MetricsPath, &target.RelabelConfigs, &target.MetricRelabelConfigs, &target.InstanceIDs, &target.CreatedAt, &target.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// 查询单个告警规则
+func getAlertRule(q querier, orgID, ruleID uuid.UUID) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	err := q.QueryRow(`
+		SELECT id, user_id, org_id, alert_name, group_name, expr, for_duration, labels, annotations, created_at, updated_at
+		FROM alert_rules WHERE id = $1 AND org_id = $2`, ruleID, orgID).Scan(
+		&rule.ID, &rule.UserID, &rule.OrgID, &rule.AlertName, &rule.GroupName, &rule.Expr, &rule.ForDuration,
+		&rule.Labels, &rule.Annotations, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// 查询单个告警规则并加行锁，用于修改前记录原值
+func getAlertRuleForUpdate(q querier, orgID, ruleID uuid.UUID) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	err := q.QueryRow(`
+		SELECT id, user_id, org_id, alert_name, group_name, expr, for_duration, labels, annotations, created_at, updated_at
+		FROM alert_rules WHERE id = $1 AND org_id = $2 FOR UPDATE`, ruleID, orgID).Scan(
+		&rule.ID, &rule.UserID, &rule.OrgID, &rule.AlertName, &rule.GroupName, &rule.Expr, &rule.ForDuration,
+		&rule.Labels, &rule.Annotations, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+const aiSettingsColumns = `id, user_id, provider, api_key, api_key_version, api_key_hint, base_url, model, api_version,
+	temperature, created_at, updated_at`
+
+func scanAISettings(row rowScanner) (*models.AISettings, error) {
+	var settings models.AISettings
+	err := row.Scan(&settings.ID, &settings.UserID, &settings.Provider, &settings.EncryptedAPIKey, &settings.APIKeyVersion,
+		&settings.APIKeyHint, &settings.BaseURL, &settings.Model, &settings.APIVersion, &settings.Temperature,
+		&settings.CreatedAt, &settings.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// 查询用户的AI设置
+func getAISettings(q querier, userID uuid.UUID) (*models.AISettings, error) {
+	return scanAISettings(q.QueryRow(`SELECT `+aiSettingsColumns+` FROM ai_settings WHERE user_id = $1`, userID))
+}
+
+// 查询用户的AI设置并加行锁
+func getAISettingsForUpdate(q querier, userID uuid.UUID) (*models.AISettings, error) {
+	return scanAISettings(q.QueryRow(`SELECT `+aiSettingsColumns+` FROM ai_settings WHERE user_id = $1 FOR UPDATE`, userID))
+}
+
+func insertTarget(q querier, orgID, userID uuid.UUID, req models.CreateTargetRequest) (*models.Target, error) {
+	var target models.Target
+	err := q.QueryRow(`
+		INSERT INTO targets (user_id, org_id, job_name, targets, scrape_interval, metrics_path, relabel_configs, metric_relabel_configs, instance_ids)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, user_id, org_id, job_name, targets, scrape_interval, metrics_path, relabel_configs, metric_relabel_configs, instance_ids, created_at, updated_at`,
+		userID, orgID, req.JobName, req.Targets, req.ScrapeInterval, req.MetricsPath, req.RelabelConfigs, req.MetricRelabelConfigs, req.InstanceIDs).Scan(
+		&target.ID, &target.UserID, &target.OrgID, &target.JobName, &target.Targets, &target.ScrapeInterval,
+		&target.MetricsPath, &target.RelabelConfigs, &target.MetricRelabelConfigs, &target.InstanceIDs, &target.CreatedAt, &target.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+func updateTarget(q querier, orgID, targetID uuid.UUID, req models.CreateTargetRequest) (*models.Target, error) {
+	var target models.Target
+	err := q.QueryRow(`
+		UPDATE targets 
+		SET job_name = $1, targets = $2, scrape_interval = $3, metrics_path = $4, 
+		    relabel_configs = $5, metric_relabel_configs = $6, instance_ids = $7
+		WHERE id = $8 AND org_id = $9
+		RETURNING id, user_id, org_id, job_name, targets, scrape_interval, metrics_path, relabel_configs, metric_relabel_configs, instance_ids, created_at, updated_at`,
+		req.JobName, req.Targets, req.ScrapeInterval, req.MetricsPath, req.RelabelConfigs, req.MetricRelabelConfigs, req.InstanceIDs, targetID, orgID).Scan(
+		&target.ID, &target.UserID, &target.OrgID, &target.JobName, &target.Targets, &target.ScrapeInterval,
+		&target.MetricsPath, &target.RelabelConfigs, &target.MetricRelabelConfigs, &target.InstanceIDs, &target.CreatedAt, &target.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+func insertAlertRule(q querier, orgID, userID uuid.UUID, req models.CreateAlertRuleRequest) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	err := q.QueryRow(`
+		INSERT INTO alert_rules (user_id, org_id, alert_name, group_name, expr, for_duration, labels, annotations)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, user_id, org_id, alert_name, group_name, expr, for_duration, labels, annotations, created_at, updated_at`,
+		userID, orgID, req.AlertName, req.GroupName, req.Expr, req.ForDuration, req.Labels, req.Annotations).Scan(
+		&rule.ID, &rule.UserID, &rule.OrgID, &rule.AlertName, &rule.GroupName, &rule.Expr, &rule.ForDuration,
+		&rule.Labels, &rule.Annotations, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func updateAlertRule(q querier, orgID, ruleID uuid.UUID, req models.CreateAlertRuleRequest) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	err := q.QueryRow(`
+		UPDATE alert_rules 
+		SET alert_name = $1, group_name = $2, expr = $3, for_duration = $4, labels = $5, annotations = $6
+		WHERE id = $7 AND org_id = $8
+		RETURNING id, user_id, org_id, alert_name, group_name, expr, for_duration, labels, annotations, created_at, updated_at`,
+		req.AlertName, req.GroupName, req.Expr, req.ForDuration, req.Labels, req.Annotations, ruleID, orgID).Scan(
+		&rule.ID, &rule.UserID, &rule.OrgID, &rule.AlertName, &rule.GroupName, &rule.Expr, &rule.ForDuration,
+		&rule.Labels, &rule.Annotations, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
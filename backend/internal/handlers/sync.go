@@ -0,0 +1,570 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/models"
+	"promeconfig-backend/internal/prometheus"
+	"promeconfig-backend/internal/rbac"
+)
+
+// 同步和重载结果的状态
+const (
+	syncStatusSynced   = "synced"
+	syncStatusReloaded = "reloaded"
+	syncStatusFailed   = "failed"
+	syncStatusSkipped  = "skipped"
+)
+
+// 访问Prometheus管理接口的超时
+const instanceRequestTimeout = 10 * time.Second
+
+// 单个实例的同步结果
+type syncResult struct {
+	InstanceID *uuid.UUID              `json:"instance_id"`
+	Name       string                  `json:"name"`
+	Status     string                  `json:"status"`
+	Revision   *models.ConfigRevision  `json:"revision,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+	Errors     []prometheus.CheckError `json:"errors,omitempty"`
+
+	instance models.PrometheusInstance
+	rendered *renderedConfig
+	staged   prometheus.StagedFiles
+}
+
+// 单个实例的重载结果
+type reloadResult struct {
+	InstanceID *uuid.UUID `json:"instance_id"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// 在事务中为每个实例渲染并暂存配置文件，成功的实例各记录一个新的revision
+// 先渲染并检查全部实例再写文件；atomic 为true时任一实例未通过检查则都不写入，
+// 写文件失败时返回错误，调用方应回滚事务。否则各实例的结果互不影响
+// 文件只写入临时文件，调用方提交事务后用 publishSyncResults 替换正式文件，其他情况用 discardSyncResults 删除
+func (h *Handlers) syncInstances(tx *sql.Tx, orgID, authorID uuid.UUID, instances []models.PrometheusInstance, message string, atomic bool) (_ []syncResult, err error) {
+	// 同一组织的同步串行执行，保证revision顺序与文件内容一致
+	if _, err := tx.Exec("SELECT id FROM organizations WHERE id = $1 FOR UPDATE", orgID); err != nil {
+		return nil, err
+	}
+
+	snapshot, err := loadSnapshot(tx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	// revision中保存整个组织的快照，回滚时恢复全部配置
+	snapshotJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]syncResult, len(instances))
+	defer func() {
+		if err != nil {
+			discardSyncResults(results)
+		}
+	}()
+	failed := false
+	for i := range instances {
+		result := &results[i]
+		result.InstanceID = instances[i].InstanceRef()
+		result.Name = instances[i].Name
+		result.instance = instances[i]
+
+		rendered, err := renderSnapshot(filterSnapshot(snapshot, &instances[i]))
+		if err != nil {
+			result.Status = syncStatusFailed
+			result.Error = "Failed to render config: " + err.Error()
+			failed = true
+			continue
+		}
+
+		// 未通过检查的配置不写入文件，也不记录revision
+		if checkErrs := checkRendered(rendered); len(checkErrs) > 0 {
+			result.Status = syncStatusFailed
+			result.Error = "Configuration check failed"
+			result.Errors = checkErrs
+			failed = true
+			continue
+		}
+		result.rendered = rendered
+	}
+
+	for i := range results {
+		result := &results[i]
+		if result.rendered == nil {
+			continue
+		}
+		if atomic && failed {
+			result.Status = syncStatusSkipped
+			continue
+		}
+
+		// 先暂存文件，写入失败时不记录revision；密钥文件先于引用它的配置替换
+		dir := h.instanceDir(&result.instance)
+		err := result.staged.StageSecrets(filepath.Join(dir, prometheus.SecretsDir), result.rendered.Secrets)
+		if err == nil {
+			err = result.staged.Stage(dir, map[string][]byte{
+				prometheus.ConfigFileName: result.rendered.PrometheusConfig,
+				prometheus.RulesFileName:  result.rendered.AlertRulesConfig,
+			})
+		}
+		if err != nil {
+			result.staged.Discard()
+			if atomic {
+				return nil, err
+			}
+			result.Status = syncStatusFailed
+			result.Error = err.Error()
+			continue
+		}
+
+		revision := models.ConfigRevision{
+			InstanceID:       result.InstanceID,
+			PrometheusConfig: string(result.rendered.PrometheusConfig),
+			AlertRulesConfig: string(result.rendered.AlertRulesConfig),
+		}
+		if !result.instance.Implicit {
+			revision.InstanceName = result.instance.Name
+		}
+		err = tx.QueryRow(`
+			INSERT INTO config_revisions (org_id, instance_id, instance_name, author_id, message, prometheus_config, alert_rules_config, snapshot)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id, org_id, author_id, message, created_at`,
+			orgID, revision.InstanceID, revision.InstanceName, authorID, message, revision.PrometheusConfig, revision.AlertRulesConfig, snapshotJSON).Scan(
+			&revision.ID, &revision.OrgID, &revision.AuthorID, &revision.Message, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Status = syncStatusSynced
+		result.Revision = &revision
+	}
+
+	return results, nil
+}
+
+// 同步成功的实例数
+// 事务提交后把各实例暂存的文件替换为正式文件，返回第一个错误
+func publishSyncResults(results []syncResult) error {
+	var firstErr error
+	for i := range results {
+		if err := results[i].staged.Commit(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("instance %s: %w", results[i].Name, err)
+		}
+	}
+	return firstErr
+}
+
+// 删除各实例暂存的文件，已替换的文件不受影响
+func discardSyncResults(results []syncResult) {
+	for i := range results {
+		results[i].staged.Discard()
+	}
+}
+
+func syncedCount(results []syncResult) int {
+	count := 0
+	for _, result := range results {
+		if result.Status == syncStatusSynced {
+			count++
+		}
+	}
+	return count
+}
+
+// 为每个新的revision记录审计日志
+func recordSyncAudit(tx *sql.Tx, c *gin.Context, action string, before interface{}, results []syncResult) error {
+	for _, result := range results {
+		if result.Revision == nil {
+			continue
+		}
+		if err := recordAudit(tx, c, auditEntry{
+			Action:     action,
+			EntityType: "config_revision",
+			EntityID:   result.Revision.ID.String(),
+			Before:     before,
+			After: gin.H{
+				"revision_id": result.Revision.ID,
+				"instance_id": result.InstanceID,
+				"message":     result.Revision.Message,
+			},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 返回各实例的同步结果：全部成功返回200，部分成功返回207
+// 全部失败时有实例未通过检查返回422，否则返回500
+func writeSyncResults(c *gin.Context, results []syncResult) {
+	synced := syncedCount(results)
+	switch {
+	case synced == len(results):
+		c.JSON(http.StatusOK, gin.H{"message": "Configuration synced successfully", "results": results})
+	case synced > 0:
+		c.JSON(http.StatusMultiStatus, gin.H{
+			"message": fmt.Sprintf("Configuration synced to %d of %d instances", synced, len(results)),
+			"results": results,
+		})
+	default:
+		for _, result := range results {
+			if len(result.Errors) > 0 {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Configuration check failed", "results": results})
+				return
+			}
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync configuration", "results": results})
+	}
+}
+
+// 同步全部实例
+// 指定变更集时先应用其中的修改，任一实例未通过检查则变更集和同步都不生效
+func (h *Handlers) SyncPrometheusConfig(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !middleware.Authorize(c, rbac.PermPrometheusSync) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	// 请求体可选，用于填写本次变更说明
+	var req models.SyncConfigRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	required, err := requiresApproval(tx, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if required && req.ChangeSetID == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization requires an approved change set to sync"})
+		return
+	}
+
+	var changeSet *models.ChangeSet
+	if req.ChangeSetID != nil {
+		changeSet, err = getChangeSet(tx, orgID, *req.ChangeSetID, true)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Change set not found"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set"})
+			return
+		}
+
+		if changeSet.Status != changeSetApproved {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Change set is %s, only approved change sets can be applied", changeSet.Status)})
+			return
+		}
+
+		items, err := queryChangeSetItems(tx, changeSet.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get change set items"})
+			return
+		}
+
+		if err := applyChangeSet(tx, c, orgID, userID, changeSet, items); err != nil {
+			if errors.Is(err, errChangeConflict) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply change set"})
+			return
+		}
+
+		if req.Message == "" {
+			req.Message = fmt.Sprintf("Apply change set: %s", changeSet.Title)
+		}
+	}
+
+	instances, err := h.orgInstances(tx, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Prometheus instances"})
+		return
+	}
+	if len(instances) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "No Prometheus instance to sync, add an instance first"})
+		return
+	}
+
+	results, err := h.syncInstances(tx, orgID, userID, instances, req.Message, changeSet != nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync configuration"})
+		return
+	}
+	defer discardSyncResults(results)
+
+	if syncedCount(results) == 0 {
+		writeSyncResults(c, results)
+		return
+	}
+
+	if changeSet != nil {
+		revisionID := results[0].Revision.ID
+		if _, err := tx.Exec(`
+			UPDATE change_sets SET status = $1, applied_revision_id = $2, applied_at = NOW()
+			WHERE id = $3`, changeSetApplied, revisionID, changeSet.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply change set"})
+			return
+		}
+
+		if err := recordAudit(tx, c, auditEntry{
+			Action:     auditApply,
+			EntityType: "change_set",
+			EntityID:   changeSet.ID.String(),
+			Before:     gin.H{"status": changeSet.Status},
+			After:      gin.H{"status": changeSetApplied, "revision_id": revisionID},
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+			return
+		}
+	}
+
+	if err := recordSyncAudit(tx, c, auditSync, nil, results); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync configuration"})
+		return
+	}
+
+	if err := publishSyncResults(results); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration synced but failed to write files: " + err.Error(), "results": results})
+		return
+	}
+
+	writeSyncResults(c, results)
+}
+
+// 只同步一个实例，部署数据库中已有的配置，不接受变更集
+func (h *Handlers) SyncPrometheusInstance(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !middleware.Authorize(c, rbac.PermPrometheusSync) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	var req models.SyncConfigRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.ChangeSetID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Change sets can only be applied when syncing all instances"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// 开启审批的组织只能通过同步全部实例应用已批准的变更集
+	required, err := requiresApproval(tx, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if required {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization requires an approved change set to sync"})
+		return
+	}
+
+	instance := loadInstance(c, tx, orgID, false)
+	if instance == nil {
+		return
+	}
+
+	results, err := h.syncInstances(tx, orgID, userID, []models.PrometheusInstance{*instance}, req.Message, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync configuration"})
+		return
+	}
+	defer discardSyncResults(results)
+
+	if syncedCount(results) == 0 {
+		writeSyncResults(c, results)
+		return
+	}
+
+	if err := recordSyncAudit(tx, c, auditSync, nil, results); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync configuration"})
+		return
+	}
+
+	if err := publishSyncResults(results); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Configuration synced but failed to write files: " + err.Error(), "results": results})
+		return
+	}
+
+	writeSyncResults(c, results)
+}
+
+// 通过 /-/reload 让实例重新加载配置，reload_mode 为none的实例跳过
+func (h *Handlers) reloadInstance(ctx context.Context, instance *models.PrometheusInstance) reloadResult {
+	result := reloadResult{InstanceID: instance.InstanceRef(), Name: instance.Name}
+	if instance.ReloadMode != "http" {
+		result.Status = syncStatusSkipped
+		return result
+	}
+
+	client, err := h.instanceClient(instance)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(ctx, instanceRequestTimeout)
+		defer cancel()
+		err = client.Reload(ctx)
+	}
+	if err != nil {
+		result.Status = syncStatusFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = syncStatusReloaded
+	return result
+}
+
+// 并发地对每个实例执行 fn
+func forEachInstance(instances []models.PrometheusInstance, fn func(i int)) {
+	var wg sync.WaitGroup
+	for i := range instances {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// 返回各实例的重载结果：没有失败返回200，部分失败返回207，全部失败返回502
+func writeReloadResults(c *gin.Context, results []reloadResult) {
+	reloaded, failed := 0, 0
+	for _, result := range results {
+		switch result.Status {
+		case syncStatusReloaded:
+			reloaded++
+		case syncStatusFailed:
+			failed++
+		}
+	}
+
+	switch {
+	case failed == 0:
+		c.JSON(http.StatusOK, gin.H{"message": "Configuration reloaded successfully", "results": results})
+	case reloaded > 0:
+		c.JSON(http.StatusMultiStatus, gin.H{
+			"message": fmt.Sprintf("Configuration reloaded on %d of %d instances", reloaded, reloaded+failed),
+			"results": results,
+		})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reload configuration", "results": results})
+	}
+}
+
+// 重载全部实例
+func (h *Handlers) ReloadPrometheusConfig(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermPrometheusReload) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	instances, err := h.orgInstances(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Prometheus instances"})
+		return
+	}
+
+	results := make([]reloadResult, len(instances))
+	forEachInstance(instances, func(i int) {
+		results[i] = h.reloadInstance(c.Request.Context(), &instances[i])
+	})
+
+	if err := recordAudit(h.db, c, auditEntry{
+		Action:     auditReload,
+		EntityType: "prometheus",
+		EntityID:   orgID.String(),
+		After:      gin.H{"results": results},
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	writeReloadResults(c, results)
+}
+
+func (h *Handlers) ReloadPrometheusInstance(c *gin.Context) {
+	if !middleware.Authorize(c, rbac.PermPrometheusReload) {
+		return
+	}
+	orgID, _ := middleware.GetOrgID(c)
+
+	instance := loadInstance(c, h.db, orgID, false)
+	if instance == nil {
+		return
+	}
+
+	result := h.reloadInstance(c.Request.Context(), instance)
+
+	if err := recordAudit(h.db, c, auditEntry{
+		Action:     auditReload,
+		EntityType: "prometheus_instance",
+		EntityID:   instance.ID.String(),
+		After:      result,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write audit log"})
+		return
+	}
+
+	writeReloadResults(c, []reloadResult{result})
+}
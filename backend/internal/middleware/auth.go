@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"database/sql"
 	"net/http"
 	"strings"
 
@@ -45,6 +44,13 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 两步验证的challenge token等专用token不能用于访问接口
+		if purpose, _ := claims["purpose"].(string); purpose != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
 		userIDStr, ok := claims["user_id"].(string)
 		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
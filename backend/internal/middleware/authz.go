@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"promeconfig-backend/internal/rbac"
+)
+
+// OrgHeader 客户端通过该请求头指定当前操作的组织
+const OrgHeader = "X-Org-ID"
+
+// OrgMiddleware 解析当前组织并加载用户在该组织中的角色
+// 未指定组织时使用用户最早加入的组织
+func OrgMiddleware(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := GetUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+
+		var orgID uuid.UUID
+		var role string
+		var err error
+
+		if orgHeader := c.GetHeader(OrgHeader); orgHeader != "" {
+			orgID, err = uuid.Parse(orgHeader)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+				c.Abort()
+				return
+			}
+			err = db.QueryRow(`
+				SELECT role FROM organization_members
+				WHERE org_id = $1 AND user_id = $2`, orgID, userID).Scan(&role)
+		} else {
+			err = db.QueryRow(`
+				SELECT org_id, role FROM organization_members
+				WHERE user_id = $1 ORDER BY created_at ASC LIMIT 1`, userID).Scan(&orgID, &role)
+		}
+
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this organization"})
+			c.Abort()
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load membership"})
+			c.Abort()
+			return
+		}
+
+		c.Set("org_id", orgID)
+		c.Set("role", rbac.Role(role))
+		c.Next()
+	}
+}
+
+// RequirePermission 路由级别的权限检查
+func RequirePermission(perm rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Authorize(c, perm) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// Authorize 检查当前用户是否拥有指定权限，没有时返回403并中止请求
+func Authorize(c *gin.Context, perm rbac.Permission) bool {
+	role, _ := GetRole(c)
+	if role.Can(perm) {
+		return true
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":      fmt.Sprintf("Missing permission: %s", perm),
+		"permission": perm,
+	})
+	c.Abort()
+	return false
+}
+
+func GetOrgID(c *gin.Context) (uuid.UUID, bool) {
+	orgID, exists := c.Get("org_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+
+	id, ok := orgID.(uuid.UUID)
+	return id, ok
+}
+
+func GetRole(c *gin.Context) (rbac.Role, bool) {
+	role, exists := c.Get("role")
+	if !exists {
+		return "", false
+	}
+
+	r, ok := role.(rbac.Role)
+	return r, ok
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,20 +20,62 @@ type User struct {
 type Target struct {
 	ID                    uuid.UUID       `json:"id" db:"id"`
 	UserID               uuid.UUID       `json:"user_id" db:"user_id"`
+	OrgID                uuid.UUID       `json:"org_id" db:"org_id"`
 	JobName              string          `json:"job_name" db:"job_name"`
 	Targets              json.RawMessage `json:"targets" db:"targets"`
 	ScrapeInterval       string          `json:"scrape_interval" db:"scrape_interval"`
 	MetricsPath          string          `json:"metrics_path" db:"metrics_path"`
 	RelabelConfigs       json.RawMessage `json:"relabel_configs,omitempty" db:"relabel_configs"`
 	MetricRelabelConfigs json.RawMessage `json:"metric_relabel_configs,omitempty" db:"metric_relabel_configs"`
+	InstanceIDs          UUIDList        `json:"instance_ids" db:"instance_ids"`
 	CreatedAt            time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt            time.Time       `json:"updated_at" db:"updated_at"`
 }
 
+// UUIDList 以JSON数组保存在JSONB列中，用于target和规则组分配到的Prometheus实例
+// 为空表示分配到组织的全部实例
+type UUIDList []uuid.UUID
+
+func (l UUIDList) Value() (driver.Value, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(l)
+}
+
+func (l *UUIDList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("cannot scan %T into UUIDList", src)
+	}
+}
+
+// Includes 判断是否分配到某个实例，列表为空时分配到全部实例
+func (l UUIDList) Includes(id uuid.UUID) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, item := range l {
+		if item == id {
+			return true
+		}
+	}
+	return false
+}
+
 type AlertRule struct {
 	ID          uuid.UUID       `json:"id" db:"id"`
 	UserID      uuid.UUID       `json:"user_id" db:"user_id"`
+	OrgID       uuid.UUID       `json:"org_id" db:"org_id"`
 	AlertName   string          `json:"alert_name" db:"alert_name"`
+	GroupName   string          `json:"group_name" db:"group_name"`
 	Expr        string          `json:"expr" db:"expr"`
 	ForDuration string          `json:"for_duration" db:"for_duration"`
 	Labels      json.RawMessage `json:"labels" db:"labels"`
@@ -40,16 +84,657 @@ type AlertRule struct {
 	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
 }
 
+// RuleGroup 规则组分配到的Prometheus实例，规则通过 group_name 归属到规则组
+type RuleGroup struct {
+	Name        string   `json:"name" db:"name"`
+	InstanceIDs UUIDList `json:"instance_ids" db:"instance_ids"`
+	RuleCount   int      `json:"rule_count,omitempty"`
+}
+
+// PrometheusInstance 一个Prometheus服务器，同步时配置写入其输出目录
+// 组织未添加实例时使用由环境变量配置的默认实例（Implicit 为true，ID为空）
+type PrometheusInstance struct {
+	ID                 uuid.UUID `json:"id" db:"id"`
+	OrgID              uuid.UUID `json:"org_id" db:"org_id"`
+	Name               string    `json:"name" db:"name"`
+	URL                string    `json:"url" db:"url"`
+	Username           string    `json:"username" db:"username"`
+	Password           string    `json:"-" db:"password"`
+	BearerToken        string    `json:"-" db:"bearer_token"`
+	HasPassword        bool      `json:"has_password"`
+	HasBearerToken     bool      `json:"has_bearer_token"`
+	InsecureSkipVerify bool      `json:"insecure_skip_verify" db:"insecure_skip_verify"`
+	CACert             string    `json:"ca_cert,omitempty" db:"ca_cert"`
+	OutputDir          string    `json:"output_dir" db:"output_dir"`
+	ReloadMode         string    `json:"reload_mode" db:"reload_mode"`
+	Implicit           bool      `json:"implicit,omitempty"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// InstanceRef 返回实例ID，默认实例返回nil
+func (i *PrometheusInstance) InstanceRef() *uuid.UUID {
+	if i.Implicit {
+		return nil
+	}
+	id := i.ID
+	return &id
+}
+
+// GlobalConfig 实例的全局配置，渲染为prometheus.yml中的 global、rule_files 和 alerting.alert_relabel_configs
+// 间隔为空时使用默认值；RuleFiles 为额外的规则文件glob，生成的规则文件总会被引用
+type GlobalConfig struct {
+	InstanceID          *uuid.UUID        `json:"instance_id" db:"instance_id"`
+	ScrapeInterval      string            `json:"scrape_interval" db:"scrape_interval"`
+	ScrapeTimeout       string            `json:"scrape_timeout" db:"scrape_timeout"`
+	EvaluationInterval  string            `json:"evaluation_interval" db:"evaluation_interval"`
+	ExternalLabels      map[string]string `json:"external_labels" db:"external_labels"`
+	QueryLogFile        string            `json:"query_log_file" db:"query_log_file"`
+	RuleFiles           []string          `json:"rule_files" db:"rule_files"`
+	AlertRelabelConfigs json.RawMessage   `json:"alert_relabel_configs,omitempty" db:"alert_relabel_configs"`
+	UpdatedAt           *time.Time        `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// ClientAuth Prometheus访问远程存储和Alertmanager时的认证和TLS设置
+// 密码、bearer token和TLS私钥不在响应中返回，渲染时写入输出目录中的密钥文件
+type ClientAuth struct {
+	Username           string `json:"username" db:"username"`
+	Password           string `json:"-" db:"password"`
+	BearerToken        string `json:"-" db:"bearer_token"`
+	HasPassword        bool   `json:"has_password"`
+	HasBearerToken     bool   `json:"has_bearer_token"`
+	TLSCACert          string `json:"tls_ca_cert" db:"tls_ca_cert"`
+	TLSCert            string `json:"tls_cert" db:"tls_cert"`
+	TLSKey             string `json:"-" db:"tls_key"`
+	HasTLSKey          bool   `json:"has_tls_key"`
+	TLSServerName      string `json:"tls_server_name" db:"tls_server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" db:"insecure_skip_verify"`
+}
+
+// RemoteStorage remote_write 或 remote_read 配置，Kind 为 write 或 read
+type RemoteStorage struct {
+	ID            uuid.UUID         `json:"id" db:"id"`
+	OrgID         uuid.UUID         `json:"org_id" db:"org_id"`
+	Kind          string            `json:"kind" db:"kind"`
+	Name          string            `json:"name" db:"name"`
+	URL           string            `json:"url" db:"url"`
+	InstanceIDs   UUIDList          `json:"instance_ids" db:"instance_ids"`
+	RemoteTimeout string            `json:"remote_timeout" db:"remote_timeout"`
+	Headers       map[string]string `json:"headers" db:"headers"`
+	ClientAuth
+	WriteRelabelConfigs json.RawMessage   `json:"write_relabel_configs,omitempty" db:"write_relabel_configs"`
+	QueueConfig         json.RawMessage   `json:"queue_config,omitempty" db:"queue_config"`
+	MetadataConfig      json.RawMessage   `json:"metadata_config,omitempty" db:"metadata_config"`
+	ReadRecent          bool              `json:"read_recent" db:"read_recent"`
+	RequiredMatchers    map[string]string `json:"required_matchers" db:"required_matchers"`
+	CreatedAt           time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// Alertmanager Prometheus发送告警的目标，渲染为 alerting.alertmanagers 中的一项
+// 地址来自 StaticTargets 或 SDConfigs（键为 dns_sd_configs 等服务发现配置）
+type Alertmanager struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	OrgID          uuid.UUID       `json:"org_id" db:"org_id"`
+	Name           string          `json:"name" db:"name"`
+	InstanceIDs    UUIDList        `json:"instance_ids" db:"instance_ids"`
+	Scheme         string          `json:"scheme" db:"scheme"`
+	PathPrefix     string          `json:"path_prefix" db:"path_prefix"`
+	APIVersion     string          `json:"api_version" db:"api_version"`
+	Timeout        string          `json:"timeout" db:"timeout"`
+	StaticTargets  []string        `json:"static_targets" db:"static_targets"`
+	SDConfigs      json.RawMessage `json:"sd_configs,omitempty" db:"sd_configs"`
+	RelabelConfigs json.RawMessage `json:"relabel_configs,omitempty" db:"relabel_configs"`
+	ClientAuth
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// AlertmanagerSettings 组织的Alertmanager连接和输出目录设置
+// 未保存过设置时使用由环境变量配置的默认值，Implicit 为true
+type AlertmanagerSettings struct {
+	OrgID              uuid.UUID  `json:"org_id" db:"org_id"`
+	URL                string     `json:"url" db:"url"`
+	Username           string     `json:"username" db:"username"`
+	Password           string     `json:"-" db:"password"`
+	BearerToken        string     `json:"-" db:"bearer_token"`
+	HasPassword        bool       `json:"has_password"`
+	HasBearerToken     bool       `json:"has_bearer_token"`
+	InsecureSkipVerify bool       `json:"insecure_skip_verify" db:"insecure_skip_verify"`
+	CACert             string     `json:"ca_cert,omitempty" db:"ca_cert"`
+	OutputDir          string     `json:"output_dir" db:"output_dir"`
+	ReloadMode         string     `json:"reload_mode" db:"reload_mode"`
+	Implicit           bool       `json:"implicit,omitempty"`
+	UpdatedAt          *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// AlertmanagerGlobal alertmanager.yml 的 global，密钥只在请求中出现，保存后以 has_* 表示是否已设置
+type AlertmanagerGlobal struct {
+	ResolveTimeout      string  `json:"resolve_timeout,omitempty"`
+	SMTPFrom            string  `json:"smtp_from,omitempty"`
+	SMTPSmarthost       string  `json:"smtp_smarthost,omitempty"`
+	SMTPHello           string  `json:"smtp_hello,omitempty"`
+	SMTPAuthUsername    string  `json:"smtp_auth_username,omitempty"`
+	SMTPAuthPassword    *string `json:"smtp_auth_password,omitempty"`
+	HasSMTPAuthPassword bool    `json:"has_smtp_auth_password"`
+	SMTPRequireTLS      *bool   `json:"smtp_require_tls,omitempty"`
+	SlackAPIURL         *string `json:"slack_api_url,omitempty"`
+	HasSlackAPIURL      bool    `json:"has_slack_api_url"`
+	PagerDutyURL        string  `json:"pagerduty_url,omitempty"`
+	OpsGenieAPIURL      string  `json:"opsgenie_api_url,omitempty"`
+	OpsGenieAPIKey      *string `json:"opsgenie_api_key,omitempty"`
+	HasOpsGenieAPIKey   bool    `json:"has_opsgenie_api_key"`
+}
+
+// AlertmanagerRoute 路由树的一个节点，根节点必须指定receiver，子节点未设置的项继承上级
+type AlertmanagerRoute struct {
+	Receiver            string              `json:"receiver,omitempty"`
+	GroupBy             []string            `json:"group_by,omitempty"`
+	Matchers            []string            `json:"matchers,omitempty"`
+	Continue            bool                `json:"continue,omitempty"`
+	GroupWait           string              `json:"group_wait,omitempty"`
+	GroupInterval       string              `json:"group_interval,omitempty"`
+	RepeatInterval      string              `json:"repeat_interval,omitempty"`
+	MuteTimeIntervals   []string            `json:"mute_time_intervals,omitempty"`
+	ActiveTimeIntervals []string            `json:"active_time_intervals,omitempty"`
+	Routes              []AlertmanagerRoute `json:"routes,omitempty"`
+}
+
+// InhibitRule 匹配 SourceMatchers 的告警触发时，抑制匹配 TargetMatchers 且 Equal 中标签相同的告警
+type InhibitRule struct {
+	SourceMatchers []string `json:"source_matchers"`
+	TargetMatchers []string `json:"target_matchers"`
+	Equal          []string `json:"equal,omitempty"`
+}
+
+// TimeInterval 命名的时间段，供路由的 mute_time_intervals 和 active_time_intervals 引用
+type TimeInterval struct {
+	Name          string             `json:"name"`
+	TimeIntervals []TimeIntervalSpec `json:"time_intervals"`
+}
+
+type TimeIntervalSpec struct {
+	Times       []TimeRange `json:"times,omitempty"`
+	Weekdays    []string    `json:"weekdays,omitempty"`
+	DaysOfMonth []string    `json:"days_of_month,omitempty"`
+	Months      []string    `json:"months,omitempty"`
+	Years       []string    `json:"years,omitempty"`
+	Location    string      `json:"location,omitempty"`
+}
+
+type TimeRange struct {
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// AlertmanagerReceiver 通知接收者，各集成的密钥保存在 Secrets 中，键为 "<集成>.<配置ID>.<字段>"
+type AlertmanagerReceiver struct {
+	ID               uuid.UUID         `json:"id" db:"id"`
+	OrgID            uuid.UUID         `json:"org_id" db:"org_id"`
+	Name             string            `json:"name" db:"name"`
+	EmailConfigs     []EmailConfig     `json:"email_configs"`
+	WebhookConfigs   []WebhookConfig   `json:"webhook_configs"`
+	SlackConfigs     []SlackConfig     `json:"slack_configs"`
+	PagerDutyConfigs []PagerDutyConfig `json:"pagerduty_configs"`
+	OpsGenieConfigs  []OpsGenieConfig  `json:"opsgenie_configs"`
+	Secrets          map[string]string `json:"-" db:"secrets"`
+	CreatedAt        time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// 各集成配置的 ID 由服务端分配，修改时原样带回，密钥按ID沿用；没有ID的是新配置
+type EmailConfig struct {
+	ID              string            `json:"id"`
+	SendResolved    *bool             `json:"send_resolved,omitempty"`
+	To              string            `json:"to"`
+	From            string            `json:"from,omitempty"`
+	Smarthost       string            `json:"smarthost,omitempty"`
+	Hello           string            `json:"hello,omitempty"`
+	AuthUsername    string            `json:"auth_username,omitempty"`
+	AuthPassword    *string           `json:"auth_password,omitempty"`
+	HasAuthPassword bool              `json:"has_auth_password"`
+	RequireTLS      *bool             `json:"require_tls,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	HTML            string            `json:"html,omitempty"`
+	Text            string            `json:"text,omitempty"`
+}
+
+// WebhookConfig 的URL可能带有token，按密钥保存
+type WebhookConfig struct {
+	ID           string  `json:"id"`
+	SendResolved *bool   `json:"send_resolved,omitempty"`
+	URL          *string `json:"url,omitempty"`
+	HasURL       bool    `json:"has_url"`
+	MaxAlerts    uint64  `json:"max_alerts,omitempty"`
+	Timeout      string  `json:"timeout,omitempty"`
+}
+
+type SlackConfig struct {
+	ID           string  `json:"id"`
+	SendResolved *bool   `json:"send_resolved,omitempty"`
+	APIURL       *string `json:"api_url,omitempty"`
+	HasAPIURL    bool    `json:"has_api_url"`
+	Channel      string  `json:"channel,omitempty"`
+	Username     string  `json:"username,omitempty"`
+	Color        string  `json:"color,omitempty"`
+	Title        string  `json:"title,omitempty"`
+	TitleLink    string  `json:"title_link,omitempty"`
+	Text         string  `json:"text,omitempty"`
+	IconEmoji    string  `json:"icon_emoji,omitempty"`
+	IconURL      string  `json:"icon_url,omitempty"`
+}
+
+type PagerDutyConfig struct {
+	ID            string            `json:"id"`
+	SendResolved  *bool             `json:"send_resolved,omitempty"`
+	RoutingKey    *string           `json:"routing_key,omitempty"`
+	HasRoutingKey bool              `json:"has_routing_key"`
+	ServiceKey    *string           `json:"service_key,omitempty"`
+	HasServiceKey bool              `json:"has_service_key"`
+	URL           string            `json:"url,omitempty"`
+	Client        string            `json:"client,omitempty"`
+	ClientURL     string            `json:"client_url,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Severity      string            `json:"severity,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	Component     string            `json:"component,omitempty"`
+	Group         string            `json:"group,omitempty"`
+	Details       map[string]string `json:"details,omitempty"`
+}
+
+type OpsGenieConfig struct {
+	ID           string              `json:"id"`
+	SendResolved *bool               `json:"send_resolved,omitempty"`
+	APIKey       *string             `json:"api_key,omitempty"`
+	HasAPIKey    bool                `json:"has_api_key"`
+	APIURL       string              `json:"api_url,omitempty"`
+	Message      string              `json:"message,omitempty"`
+	Description  string              `json:"description,omitempty"`
+	Source       string              `json:"source,omitempty"`
+	Details      map[string]string   `json:"details,omitempty"`
+	Responders   []OpsGenieResponder `json:"responders,omitempty"`
+	Tags         string              `json:"tags,omitempty"`
+	Note         string              `json:"note,omitempty"`
+	Priority     string              `json:"priority,omitempty"`
+}
+
+type OpsGenieResponder struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Username string `json:"username,omitempty"`
+	Type     string `json:"type"`
+}
+
+// AlertmanagerConfig 渲染 alertmanager.yml 所需的全部配置
+type AlertmanagerConfig struct {
+	Global        AlertmanagerGlobal
+	GlobalSecrets map[string]string
+	Route         AlertmanagerRoute
+	Receivers     []AlertmanagerReceiver
+	InhibitRules  []InhibitRule
+	TimeIntervals []TimeInterval
+}
+
+// AlertmanagerRevision 一次同步写入的 alertmanager.yml，不含密钥
+type AlertmanagerRevision struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	OrgID       uuid.UUID  `json:"org_id" db:"org_id"`
+	AuthorID    *uuid.UUID `json:"author_id" db:"author_id"`
+	AuthorEmail string     `json:"author_email" db:"author_email"`
+	Message     string     `json:"message" db:"message"`
+	Config      string     `json:"config,omitempty" db:"config"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// AlertmanagerStatus Alertmanager的运行状态，ConfigInSync 表示已加载最近一次同步的配置
+type AlertmanagerStatus struct {
+	URL            string     `json:"url"`
+	Healthy        bool       `json:"healthy"`
+	Version        string     `json:"version,omitempty"`
+	Uptime         *time.Time `json:"uptime,omitempty"`
+	ClusterStatus  string     `json:"cluster_status,omitempty"`
+	LastRevisionID *uuid.UUID `json:"last_revision_id"`
+	ConfigInSync   *bool      `json:"config_in_sync,omitempty"`
+	Error          string     `json:"error,omitempty"`
+}
+
+// PrometheusStatus Prometheus实例的运行状态
+type PrometheusStatus struct {
+	InstanceID          *uuid.UUID `json:"instance_id"`
+	Name                string     `json:"name"`
+	URL                 string     `json:"url"`
+	Healthy             bool       `json:"healthy"`
+	Version             string     `json:"version,omitempty"`
+	StartTime           *time.Time `json:"start_time,omitempty"`
+	ReloadConfigSuccess *bool      `json:"reload_config_success,omitempty"`
+	LastConfigTime      *time.Time `json:"last_config_time,omitempty"`
+	Error               string     `json:"error,omitempty"`
+}
+
+// ConfigDrift 实例的配置漂移情况
+// PendingDiff 为当前配置与最近一次同步的差异，FilesDiff 为最近一次同步与输出目录中文件的差异
+type ConfigDrift struct {
+	InstanceID     *uuid.UUID `json:"instance_id"`
+	Name           string     `json:"name"`
+	InSync         bool       `json:"in_sync"`
+	LastRevisionID *uuid.UUID `json:"last_revision_id"`
+	PendingChanges bool       `json:"pending_changes"`
+	PendingDiff    string     `json:"pending_diff,omitempty"`
+	FilesModified  bool       `json:"files_modified"`
+	FilesDiff      string     `json:"files_diff,omitempty"`
+	ReloadPending  *bool      `json:"reload_pending,omitempty"`
+	Error          string     `json:"error,omitempty"`
+}
+
 type AISettings struct {
+	ID       uuid.UUID `json:"id" db:"id"`
+	UserID   uuid.UUID `json:"user_id" db:"user_id"`
+	Provider string    `json:"provider" db:"provider"`
+	// 加密后的API Key，不返回给前端，只返回 APIKeyHint
+	EncryptedAPIKey *string   `json:"-" db:"api_key"`
+	APIKeyVersion   int       `json:"-" db:"api_key_version"`
+	APIKeyHint      *string   `json:"api_key_hint,omitempty" db:"api_key_hint"`
+	BaseURL         *string   `json:"base_url,omitempty" db:"base_url"`
+	Model           string    `json:"model" db:"model"`
+	APIVersion      string    `json:"api_version,omitempty" db:"api_version"`
+	Temperature     float64   `json:"temperature" db:"temperature"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Organization 开启 RequireApproval 后targets和告警规则只能通过审批后的变更集修改
+type Organization struct {
+	ID              uuid.UUID `json:"id" db:"id"`
+	Name            string    `json:"name" db:"name"`
+	Role            string    `json:"role,omitempty" db:"role"`
+	RequireApproval bool      `json:"require_approval" db:"require_approval"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type OrganizationMember struct {
+	OrgID     uuid.UUID `json:"org_id" db:"org_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Email     string    `json:"email" db:"email"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ConfigRevision 每次同步生成的不可变配置快照
+type ConfigRevision struct {
+	ID               uuid.UUID       `json:"id" db:"id"`
+	OrgID            uuid.UUID       `json:"org_id" db:"org_id"`
+	InstanceID       *uuid.UUID      `json:"instance_id" db:"instance_id"`
+	// 同步时实例的名称，默认实例为空；实例删除后 instance_id 为空，名称保留
+	InstanceName     string          `json:"instance_name" db:"instance_name"`
+	AuthorID         uuid.UUID       `json:"author_id" db:"author_id"`
+	AuthorEmail      string          `json:"author_email" db:"author_email"`
+	Message          string          `json:"message" db:"message"`
+	PrometheusConfig string          `json:"prometheus_config,omitempty" db:"prometheus_config"`
+	AlertRulesConfig string          `json:"alert_rules_config,omitempty" db:"alert_rules_config"`
+	Snapshot         json.RawMessage `json:"snapshot,omitempty" db:"snapshot"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+}
+
+// ChangeSet 待审批的一组targets和告警规则修改
+// 状态：draft -> submitted -> approved -> applied，submitted 也可被 rejected
+type ChangeSet struct {
+	ID                uuid.UUID          `json:"id" db:"id"`
+	OrgID             uuid.UUID          `json:"org_id" db:"org_id"`
+	AuthorID          uuid.UUID          `json:"author_id" db:"author_id"`
+	AuthorEmail       string             `json:"author_email" db:"author_email"`
+	Title             string             `json:"title" db:"title"`
+	Description       string             `json:"description" db:"description"`
+	Status            string             `json:"status" db:"status"`
+	ReviewerID        *uuid.UUID         `json:"reviewer_id,omitempty" db:"reviewer_id"`
+	ReviewerEmail     string             `json:"reviewer_email,omitempty" db:"reviewer_email"`
+	ReviewedAt        *time.Time         `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	AppliedRevisionID *uuid.UUID         `json:"applied_revision_id,omitempty" db:"applied_revision_id"`
+	AppliedAt         *time.Time         `json:"applied_at,omitempty" db:"applied_at"`
+	Items             []ChangeSetItem    `json:"items,omitempty"`
+	Comments          []ChangeSetComment `json:"comments,omitempty"`
+	CreatedAt         time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+// ChangeSetItem 变更集中的一项修改，payload 为创建或更新时的请求体
+type ChangeSetItem struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	ChangeSetID uuid.UUID       `json:"change_set_id" db:"change_set_id"`
+	EntityType  string          `json:"entity_type" db:"entity_type"`
+	Action      string          `json:"action" db:"action"`
+	EntityID    *uuid.UUID      `json:"entity_id,omitempty" db:"entity_id"`
+	Payload     json.RawMessage `json:"payload,omitempty" db:"payload"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+type ChangeSetComment struct {
 	ID          uuid.UUID `json:"id" db:"id"`
-	UserID      uuid.UUID `json:"user_id" db:"user_id"`
-	Provider    string    `json:"provider" db:"provider"`
-	APIKey      *string   `json:"api_key,omitempty" db:"api_key"`
-	BaseURL     *string   `json:"base_url,omitempty" db:"base_url"`
-	Model       string    `json:"model" db:"model"`
-	Temperature float64   `json:"temperature" db:"temperature"`
+	ChangeSetID uuid.UUID `json:"change_set_id" db:"change_set_id"`
+	AuthorID    uuid.UUID `json:"author_id" db:"author_id"`
+	AuthorEmail string    `json:"author_email" db:"author_email"`
+	Body        string    `json:"body" db:"body"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// AlertRuleTest 保存的告警规则单元测试，RuleID 为空时属于整个规则组
+type AlertRuleTest struct {
+	ID        uuid.UUID       `json:"id" db:"id"`
+	OrgID     uuid.UUID       `json:"org_id" db:"org_id"`
+	UserID    uuid.UUID       `json:"user_id" db:"user_id"`
+	RuleID    *uuid.UUID      `json:"rule_id,omitempty" db:"rule_id"`
+	GroupName string          `json:"group_name" db:"group_name"`
+	Name      string          `json:"name" db:"name"`
+	Spec      json.RawMessage `json:"spec" db:"spec"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// RuleTestSpec 与 promtool test rules 的测试组格式一致
+type RuleTestSpec struct {
+	Interval           string              `json:"interval"`
+	EvaluationInterval string              `json:"evaluation_interval"`
+	ExternalLabels     map[string]string   `json:"external_labels,omitempty"`
+	InputSeries        []RuleTestSeries    `json:"input_series" binding:"required,dive"`
+	AlertRuleTests     []AlertRuleTestCase `json:"alert_rule_test" binding:"required,dive"`
+}
+
+// RuleTestSeries 输入序列，values 使用 promtool 的写法，例如 '1+1x10'
+type RuleTestSeries struct {
+	Series string `json:"series" binding:"required"`
+	Values string `json:"values" binding:"required"`
+}
+
+type AlertRuleTestCase struct {
+	EvalTime  string          `json:"eval_time" binding:"required"`
+	Alertname string          `json:"alertname"`
+	ExpAlerts []ExpectedAlert `json:"exp_alerts"`
+}
+
+type ExpectedAlert struct {
+	ExpLabels      map[string]string `json:"exp_labels"`
+	ExpAnnotations map[string]string `json:"exp_annotations"`
+}
+
+// RuleTestResult 一个测试组的执行结果
+type RuleTestResult struct {
+	TestID *uuid.UUID           `json:"test_id,omitempty"`
+	RuleID *uuid.UUID           `json:"rule_id,omitempty"`
+	Name   string               `json:"name"`
+	Passed bool                 `json:"passed"`
+	Error  string               `json:"error,omitempty"`
+	Cases  []RuleTestCaseResult `json:"cases"`
+}
+
+// RuleTestCaseResult 某个时间点上一个告警的检查结果，不一致时 diff 为期望与实际的unified diff
+type RuleTestCaseResult struct {
+	Alertname string        `json:"alertname"`
+	EvalTime  string        `json:"eval_time"`
+	Passed    bool          `json:"passed"`
+	Expected  []AlertSample `json:"expected"`
+	Got       []AlertSample `json:"got"`
+	Diff      string        `json:"diff,omitempty"`
+}
+
+type AlertSample struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// GenerateRuleRequest 用自然语言描述生成告警规则，GroupName 为生成规则的规则组
+type GenerateRuleRequest struct {
+	Prompt    string `json:"prompt" binding:"required"`
+	GroupName string `json:"group_name"`
+}
+
+// GeneratedAlertRule 生成并通过校验的告警规则，可直接用于创建，Warnings 为检查发现的问题
+type GeneratedAlertRule struct {
+	CreateAlertRuleRequest
+	Attempts int         `json:"attempts"`
+	Warnings []LintIssue `json:"warnings"`
+}
+
+// AnnotationsPreviewRequest 用样本渲染告警规则的注解，Labels 和 Value 为表达式结果中一个序列的标签和值
+// Annotations 不为空时预览这些注解而不是规则中保存的注解
+type AnnotationsPreviewRequest struct {
+	Labels         map[string]string `json:"labels"`
+	Value          float64           `json:"value"`
+	Annotations    map[string]string `json:"annotations"`
+	ExternalLabels map[string]string `json:"external_labels"`
+	ExternalURL    string            `json:"external_url"`
+}
+
+// RuleLintSettings 组织的告警规则检查设置，没有保存过时使用默认设置（Implicit 为true）
+// AllowedSeverities 为空时不要求 severity 标签，RateWindowScrapes 为0时不检查rate的区间
+type RuleLintSettings struct {
+	OrgID               uuid.UUID  `json:"org_id"`
+	Strict              bool       `json:"strict"`
+	AllowedSeverities   []string   `json:"allowed_severities"`
+	RequiredAnnotations []string   `json:"required_annotations"`
+	CheckFor            bool       `json:"check_for"`
+	RateWindowScrapes   int        `json:"rate_window_scrapes"`
+	CheckAggregations   bool       `json:"check_aggregations"`
+	Implicit            bool       `json:"implicit"`
+	UpdatedAt           *time.Time `json:"updated_at,omitempty"`
+}
+
+type SaveRuleLintSettingsRequest struct {
+	Strict              bool     `json:"strict"`
+	AllowedSeverities   []string `json:"allowed_severities"`
+	RequiredAnnotations []string `json:"required_annotations"`
+	CheckFor            bool     `json:"check_for"`
+	RateWindowScrapes   int      `json:"rate_window_scrapes" binding:"min=0"`
+	CheckAggregations   bool     `json:"check_aggregations"`
+}
+
+// LintIssue 告警规则检查发现的问题，Level 为 warning，严格模式下为 error
+type LintIssue struct {
+	Check   string `json:"check"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// AlertRuleWithWarnings 创建和更新告警规则的响应，Warnings 为检查发现的问题
+type AlertRuleWithWarnings struct {
+	AlertRule
+	Warnings []LintIssue `json:"warnings"`
+}
+
+// AlertRuleWithState 带运行状态的告警规则，用于 GET /api/alert-rules?state=true
+type AlertRuleWithState struct {
+	AlertRule
+	State *AlertRuleState `json:"state"`
+}
+
+// AlertRuleState 告警规则在分配到的各实例上的运行状态
+// State 为各实例中最严重的状态（firing > pending > inactive），没有实例加载该规则时为 unknown
+type AlertRuleState struct {
+	RuleID       uuid.UUID                `json:"rule_id"`
+	State        string                   `json:"state"`
+	ActiveAlerts int                      `json:"active_alerts"`
+	Instances    []AlertRuleInstanceState `json:"instances"`
+}
+
+// AlertRuleInstanceState 规则在一个实例上的状态，EvaluationTime 为最近一次评估耗时（秒）
+// Current 为false表示实例加载的规则与当前定义不同，需要同步并重载；查询失败或未加载时 Error 不为空
+type AlertRuleInstanceState struct {
+	InstanceID     *uuid.UUID    `json:"instance_id"`
+	Name           string        `json:"name"`
+	State          string        `json:"state,omitempty"`
+	Health         string        `json:"health,omitempty"`
+	LastError      string        `json:"last_error,omitempty"`
+	LastEvaluation *time.Time    `json:"last_evaluation,omitempty"`
+	EvaluationTime float64       `json:"evaluation_time"`
+	Current        bool          `json:"current"`
+	Alerts         []ActiveAlert `json:"alerts"`
+	Error          string        `json:"error,omitempty"`
+}
+
+// ActiveAlert 规则的一个活跃告警，State 为 pending 或 firing
+type ActiveAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	State       string            `json:"state"`
+	ActiveAt    *time.Time        `json:"active_at"`
+	Value       string            `json:"value"`
+}
+
+// BacktestResult 告警表达式在历史数据上的回测结果
+type BacktestResult struct {
+	RuleID        uuid.UUID        `json:"rule_id"`
+	AlertName     string           `json:"alert_name"`
+	Expr          string           `json:"expr"`
+	ForDuration   string           `json:"for_duration"`
+	Start         time.Time        `json:"start"`
+	End           time.Time        `json:"end"`
+	Step          string           `json:"step"`
+	TotalFirings  int              `json:"total_firings"`
+	FiringsPerDay float64          `json:"firings_per_day"`
+	Series        []BacktestSeries `json:"series"`
+}
+
+// BacktestSeries 一组告警标签在回测区间内的触发情况
+type BacktestSeries struct {
+	Labels        map[string]string `json:"labels"`
+	Count         int               `json:"count"`
+	FiringSeconds float64           `json:"firing_seconds"`
+	Periods       []FiringPeriod    `json:"periods"`
+}
+
+// FiringPeriod 一次触发，Resolved 为false表示到区间结束时仍在触发
+type FiringPeriod struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Resolved bool      `json:"resolved"`
+}
+
+// ConfigSnapshot 同步时的数据库行，用于回滚
+// 较早的快照没有 rule_groups、global_configs、remote_storages 或 alertmanagers，恢复时保留当前的设置
+// Global 为渲染单个实例时使用的全局配置，由 filterSnapshot 设置
+type ConfigSnapshot struct {
+	Targets        []Target        `json:"targets"`
+	AlertRules     []AlertRule     `json:"alert_rules"`
+	RuleGroups     []RuleGroup     `json:"rule_groups"`
+	GlobalConfigs  []GlobalConfig  `json:"global_configs"`
+	RemoteStorages []RemoteStorage `json:"remote_storages"`
+	Alertmanagers  []Alertmanager  `json:"alertmanagers"`
+	Global         *GlobalConfig   `json:"-"`
+}
+
+// AuditLogEntry 审计日志，只追加不修改
+type AuditLogEntry struct {
+	ID         uuid.UUID       `json:"id" db:"id"`
+	OrgID      *uuid.UUID      `json:"org_id" db:"org_id"`
+	ActorID    *uuid.UUID      `json:"actor_id" db:"actor_id"`
+	ActorEmail string          `json:"actor_email" db:"actor_email"`
+	IP         string          `json:"ip" db:"ip"`
+	Action     string          `json:"action" db:"action"`
+	EntityType string          `json:"entity_type" db:"entity_type"`
+	EntityID   string          `json:"entity_id" db:"entity_id"`
+	Before     json.RawMessage `json:"before" db:"before"`
+	After      json.RawMessage `json:"after" db:"after"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
 }
 
 // 请求/响应结构体
@@ -58,8 +743,9 @@ type SignUpRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+// 启用LDAP时email字段也可以填写LDAP用户名
 type SignInRequest struct {
-	Email    string `json:"email" binding:"required,email"`
+	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
@@ -69,6 +755,40 @@ type AuthResponse struct {
 	TokenType   string `json:"token_type"`
 }
 
+// 开启两步验证的用户登录时返回的中间结果
+type MFAChallengeResponse struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"`
+	TokenType      string `json:"token_type"`
+}
+
+type MFAEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
 type CreateTargetRequest struct {
 	JobName              string          `json:"job_name" binding:"required"`
 	Targets              json.RawMessage `json:"targets" binding:"required"`
@@ -76,20 +796,204 @@ type CreateTargetRequest struct {
 	MetricsPath          string          `json:"metrics_path"`
 	RelabelConfigs       json.RawMessage `json:"relabel_configs,omitempty"`
 	MetricRelabelConfigs json.RawMessage `json:"metric_relabel_configs,omitempty"`
+	InstanceIDs          UUIDList        `json:"instance_ids"`
 }
 
 type CreateAlertRuleRequest struct {
 	AlertName   string          `json:"alert_name" binding:"required"`
+	GroupName   string          `json:"group_name"`
 	Expr        string          `json:"expr" binding:"required"`
 	ForDuration string          `json:"for_duration"`
 	Labels      json.RawMessage `json:"labels"`
 	Annotations json.RawMessage `json:"annotations"`
 }
 
+// SavePrometheusInstanceRequest 更新时 password 和 bearer_token 为null表示不修改
+type SavePrometheusInstanceRequest struct {
+	Name               string  `json:"name" binding:"required"`
+	URL                string  `json:"url" binding:"required,url"`
+	Username           string  `json:"username"`
+	Password           *string `json:"password"`
+	BearerToken        *string `json:"bearer_token"`
+	InsecureSkipVerify bool    `json:"insecure_skip_verify"`
+	CACert             string  `json:"ca_cert"`
+	OutputDir          string  `json:"output_dir"`
+	ReloadMode         string  `json:"reload_mode" binding:"omitempty,oneof=http none"`
+}
+
+type SaveGlobalConfigRequest struct {
+	ScrapeInterval      string            `json:"scrape_interval"`
+	ScrapeTimeout       string            `json:"scrape_timeout"`
+	EvaluationInterval  string            `json:"evaluation_interval"`
+	ExternalLabels      map[string]string `json:"external_labels"`
+	QueryLogFile        string            `json:"query_log_file"`
+	RuleFiles           []string          `json:"rule_files"`
+	AlertRelabelConfigs json.RawMessage   `json:"alert_relabel_configs"`
+}
+
+// ClientAuthRequest 密钥字段为null时保留原值，为空字符串时清除
+type ClientAuthRequest struct {
+	Username           string  `json:"username"`
+	Password           *string `json:"password"`
+	BearerToken        *string `json:"bearer_token"`
+	TLSCACert          string  `json:"tls_ca_cert"`
+	TLSCert            string  `json:"tls_cert"`
+	TLSKey             *string `json:"tls_key"`
+	TLSServerName      string  `json:"tls_server_name"`
+	InsecureSkipVerify bool    `json:"insecure_skip_verify"`
+}
+
+// SaveRemoteStorageRequest
+// write_relabel_configs、queue_config、metadata_config 只用于remote_write，read_recent、required_matchers 只用于remote_read
+type SaveRemoteStorageRequest struct {
+	Name          string            `json:"name" binding:"required"`
+	URL           string            `json:"url" binding:"required,url"`
+	InstanceIDs   UUIDList          `json:"instance_ids"`
+	RemoteTimeout string            `json:"remote_timeout"`
+	Headers       map[string]string `json:"headers"`
+	ClientAuthRequest
+	WriteRelabelConfigs json.RawMessage   `json:"write_relabel_configs"`
+	QueueConfig         json.RawMessage   `json:"queue_config"`
+	MetadataConfig      json.RawMessage   `json:"metadata_config"`
+	ReadRecent          bool              `json:"read_recent"`
+	RequiredMatchers    map[string]string `json:"required_matchers"`
+}
+
+type SaveAlertmanagerRequest struct {
+	Name           string          `json:"name" binding:"required"`
+	InstanceIDs    UUIDList        `json:"instance_ids"`
+	Scheme         string          `json:"scheme" binding:"omitempty,oneof=http https"`
+	PathPrefix     string          `json:"path_prefix"`
+	APIVersion     string          `json:"api_version"`
+	Timeout        string          `json:"timeout"`
+	StaticTargets  []string        `json:"static_targets"`
+	SDConfigs      json.RawMessage `json:"sd_configs"`
+	RelabelConfigs json.RawMessage `json:"relabel_configs"`
+	ClientAuthRequest
+}
+
+// SaveAlertmanagerSettingsRequest 的 password、bearer_token 为null时保持不变
+type SaveAlertmanagerSettingsRequest struct {
+	URL                string  `json:"url" binding:"omitempty,url"`
+	Username           string  `json:"username"`
+	Password           *string `json:"password"`
+	BearerToken        *string `json:"bearer_token"`
+	InsecureSkipVerify bool    `json:"insecure_skip_verify"`
+	CACert             string  `json:"ca_cert"`
+	OutputDir          string  `json:"output_dir"`
+	ReloadMode         string  `json:"reload_mode" binding:"omitempty,oneof=http none"`
+}
+
+type SaveAlertmanagerReceiverRequest struct {
+	Name             string            `json:"name" binding:"required"`
+	EmailConfigs     []EmailConfig     `json:"email_configs"`
+	WebhookConfigs   []WebhookConfig   `json:"webhook_configs"`
+	SlackConfigs     []SlackConfig     `json:"slack_configs"`
+	PagerDutyConfigs []PagerDutyConfig `json:"pagerduty_configs"`
+	OpsGenieConfigs  []OpsGenieConfig  `json:"opsgenie_configs"`
+}
+
+// RouteTestRequest 给出 alert_rule_id 时使用该规则的标签和 alertname，labels 中的同名标签覆盖规则中的值
+type RouteTestRequest struct {
+	Labels      map[string]string `json:"labels"`
+	AlertRuleID *uuid.UUID        `json:"alert_rule_id"`
+}
+
+// CreateSilenceRequest 给出 alert_rule_id 时以规则的 alertname 和不含模板的标签生成matchers，
+// matchers 中的同名项覆盖；未给出 ends_at 时持续 duration，默认2小时
+type CreateSilenceRequest struct {
+	AlertRuleID *uuid.UUID       `json:"alert_rule_id"`
+	Matchers    []SilenceMatcher `json:"matchers"`
+	StartsAt    *time.Time       `json:"starts_at"`
+	EndsAt      *time.Time       `json:"ends_at"`
+	Duration    string           `json:"duration"`
+	Comment     string           `json:"comment" binding:"required"`
+}
+
+// SilenceMatcher 的 is_equal 为false时表示 != 或 !~，省略时为true
+type SilenceMatcher struct {
+	Name    string `json:"name" binding:"required"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"is_regex"`
+	IsEqual *bool  `json:"is_equal"`
+}
+
+type UpdateRuleGroupRequest struct {
+	InstanceIDs UUIDList `json:"instance_ids"`
+}
+
 type SaveAISettingsRequest struct {
-	Provider    string  `json:"provider" binding:"required"`
+	Provider    string  `json:"provider" binding:"required,oneof=openai custom azure anthropic ollama"`
 	APIKey      *string `json:"api_key,omitempty"`
 	BaseURL     *string `json:"base_url,omitempty"`
 	Model       string  `json:"model" binding:"required"`
+	APIVersion  string  `json:"api_version,omitempty"`
 	Temperature float64 `json:"temperature"`
-}
\ No newline at end of file
+}
+type UpdateOrganizationRequest struct {
+	Name            string `json:"name" binding:"required"`
+	RequireApproval *bool  `json:"require_approval"`
+}
+
+type AddMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type SyncConfigRequest struct {
+	Message     string     `json:"message"`
+	ChangeSetID *uuid.UUID `json:"change_set_id"`
+}
+
+// ConfigCheckRequest 为空时检查组织当前配置，指定 change_set_id 时检查变更集应用后的配置
+type ConfigCheckRequest struct {
+	PrometheusConfig string            `json:"prometheus_config"`
+	RuleFiles        map[string]string `json:"rule_files"`
+	ChangeSetID      *uuid.UUID        `json:"change_set_id"`
+}
+
+type SaveAlertRuleTestRequest struct {
+	Name      string       `json:"name" binding:"required"`
+	RuleID    *uuid.UUID   `json:"rule_id"`
+	GroupName string       `json:"group_name"`
+	Spec      RuleTestSpec `json:"spec"`
+}
+
+// RunRuleTestsRequest 提供 tests 时直接执行，否则执行保存的测试（可按 test_ids 或 rule_id 过滤）
+// 指定 change_set_id 时针对变更集应用后的规则执行
+type RunRuleTestsRequest struct {
+	TestIDs     []uuid.UUID                `json:"test_ids"`
+	RuleID      *uuid.UUID                 `json:"rule_id"`
+	Tests       []SaveAlertRuleTestRequest `json:"tests" binding:"dive"`
+	ChangeSetID *uuid.UUID                 `json:"change_set_id"`
+}
+
+// BacktestRequest 可选，用于在保存前回测修改后的表达式或持续时间
+type BacktestRequest struct {
+	Expr        string  `json:"expr"`
+	ForDuration *string `json:"for_duration"`
+}
+
+type CreateChangeSetRequest struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+}
+
+type ChangeSetItemRequest struct {
+	EntityType string          `json:"entity_type" binding:"required,oneof=target alert_rule"`
+	Action     string          `json:"action" binding:"required,oneof=create update delete"`
+	EntityID   *uuid.UUID      `json:"entity_id"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+type ChangeSetCommentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+type ReviewChangeSetRequest struct {
+	Comment string `json:"comment"`
+}
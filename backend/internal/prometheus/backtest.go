@@ -0,0 +1,86 @@
+package prometheus
+
+import (
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"promeconfig-backend/internal/models"
+)
+
+// Backtest 按告警规则的 for 语义重放区间查询的结果
+// 每个step视为一次规则评估，序列有值表示表达式成立；中间缺少一个点即视为告警恢复，
+// 连续成立的时间达到 forDuration 后进入firing。ruleLabels 覆盖序列上的同名标签，与Prometheus一致
+func Backtest(matrix model.Matrix, alertName string, ruleLabels map[string]string, forDuration, step time.Duration, end time.Time) []models.BacktestSeries {
+	series := []models.BacktestSeries{}
+
+	for _, stream := range matrix {
+		var periods []models.FiringPeriod
+		var activeAt, lastSeen time.Time
+		firing := false
+
+		closePeriod := func(resolved bool) {
+			if firing {
+				periods[len(periods)-1].End = lastSeen
+				periods[len(periods)-1].Resolved = resolved
+			}
+			firing = false
+		}
+
+		for i, sample := range stream.Values {
+			ts := sample.Timestamp.Time().UTC()
+			// 与上一个点之间超过一个step说明中间有一次评估不成立
+			if i == 0 || ts.Sub(lastSeen) > step {
+				closePeriod(true)
+				activeAt = ts
+			}
+			lastSeen = ts
+
+			if !firing && ts.Sub(activeAt) >= forDuration {
+				firing = true
+				periods = append(periods, models.FiringPeriod{Start: ts})
+			}
+		}
+		// 最后一个点之后的下一次评估仍在区间内时，告警已恢复
+		closePeriod(end.Sub(lastSeen) >= step)
+
+		if len(periods) == 0 {
+			continue
+		}
+
+		result := models.BacktestSeries{
+			Labels:  alertLabels(stream.Metric, alertName, ruleLabels),
+			Count:   len(periods),
+			Periods: periods,
+		}
+		for _, p := range periods {
+			// 触发时间按评估间隔计算，单点触发也算一个step
+			result.FiringSeconds += (p.End.Sub(p.Start) + step).Seconds()
+		}
+		series = append(series, result)
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Count != series[j].Count {
+			return series[i].Count > series[j].Count
+		}
+		return labels.FromMap(series[i].Labels).String() < labels.FromMap(series[j].Labels).String()
+	})
+
+	return series
+}
+
+func alertLabels(metric model.Metric, alertName string, ruleLabels map[string]string) map[string]string {
+	lbls := make(map[string]string, len(metric)+len(ruleLabels)+1)
+	for name, value := range metric {
+		if name != model.MetricNameLabel {
+			lbls[string(name)] = string(value)
+		}
+	}
+	for name, value := range ruleLabels {
+		lbls[name] = value
+	}
+	lbls[labels.AlertName] = alertName
+	return lbls
+}
@@ -0,0 +1,215 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"promeconfig-backend/internal/models"
+)
+
+var backtestStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// 在 backtestStart 之后的第几分钟有值
+func backtestValues(offsets ...int) []model.SamplePair {
+	values := make([]model.SamplePair, len(offsets))
+	for i, offset := range offsets {
+		values[i] = model.SamplePair{
+			Timestamp: model.TimeFromUnixNano(backtestStart.Add(time.Duration(offset) * time.Minute).UnixNano()),
+			Value:     1,
+		}
+	}
+	return values
+}
+
+func backtestAt(offset int) time.Time {
+	return backtestStart.Add(time.Duration(offset) * time.Minute)
+}
+
+func TestBacktest(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []model.SamplePair
+		forDur  time.Duration
+		end     int
+		periods []models.FiringPeriod
+		seconds float64
+	}{
+		{
+			name:    "fires immediately without for and is still firing at the end",
+			values:  backtestValues(0, 1, 2, 3, 4),
+			end:     4,
+			periods: []models.FiringPeriod{{Start: backtestAt(0), End: backtestAt(4)}},
+			seconds: 300,
+		},
+		{
+			name:    "waits for the for duration and resolves before the end",
+			values:  backtestValues(0, 1, 2, 3, 4),
+			forDur:  2 * time.Minute,
+			end:     10,
+			periods: []models.FiringPeriod{{Start: backtestAt(2), End: backtestAt(4), Resolved: true}},
+			seconds: 180,
+		},
+		{
+			name:    "gap resets the pending alert",
+			values:  backtestValues(0, 1, 3, 4, 5, 6),
+			forDur:  2 * time.Minute,
+			end:     6,
+			periods: []models.FiringPeriod{{Start: backtestAt(5), End: backtestAt(6)}},
+			seconds: 120,
+		},
+		{
+			name:   "gap resolves a firing alert",
+			values: backtestValues(0, 1, 3),
+			end:    10,
+			periods: []models.FiringPeriod{
+				{Start: backtestAt(0), End: backtestAt(1), Resolved: true},
+				{Start: backtestAt(3), End: backtestAt(3), Resolved: true},
+			},
+			seconds: 180,
+		},
+		{
+			name:   "never reaches the for duration",
+			values: backtestValues(0, 1, 2, 3),
+			forDur: 5 * time.Minute,
+			end:    10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matrix := model.Matrix{{Metric: model.Metric{"instance": "a"}, Values: tt.values}}
+			series := Backtest(matrix, "Test", nil, tt.forDur, time.Minute, backtestAt(tt.end))
+
+			if len(tt.periods) == 0 {
+				if len(series) != 0 {
+					t.Fatalf("expected no firing series, got %+v", series)
+				}
+				return
+			}
+			if len(series) != 1 {
+				t.Fatalf("expected 1 series, got %d", len(series))
+			}
+			if !reflect.DeepEqual(series[0].Periods, tt.periods) {
+				t.Errorf("periods = %+v, want %+v", series[0].Periods, tt.periods)
+			}
+			if series[0].Count != len(tt.periods) {
+				t.Errorf("count = %d, want %d", series[0].Count, len(tt.periods))
+			}
+			if series[0].FiringSeconds != tt.seconds {
+				t.Errorf("firing seconds = %v, want %v", series[0].FiringSeconds, tt.seconds)
+			}
+		})
+	}
+}
+
+func TestBacktestLabelsAndOrder(t *testing.T) {
+	matrix := model.Matrix{
+		{Metric: model.Metric{"__name__": "up", "instance": "a", "severity": "low"}, Values: backtestValues(0)},
+		{Metric: model.Metric{"__name__": "up", "instance": "b", "severity": "low"}, Values: backtestValues(0, 2)},
+	}
+	series := Backtest(matrix, "InstanceDown", map[string]string{"severity": "critical"}, 0, time.Minute, backtestAt(5))
+
+	if len(series) != 2 {
+		t.Fatalf("expected 2 series, got %d", len(series))
+	}
+	// 触发次数多的排在前面
+	want := map[string]string{"alertname": "InstanceDown", "instance": "b", "severity": "critical"}
+	if !reflect.DeepEqual(series[0].Labels, want) {
+		t.Errorf("labels = %v, want %v", series[0].Labels, want)
+	}
+	if series[0].Count != 2 || series[1].Count != 1 {
+		t.Errorf("counts = %d, %d, want 2, 1", series[0].Count, series[1].Count)
+	}
+}
+
+// 返回固定响应的Prometheus，记录收到的 query_range 请求
+func newQueryRangeServer(t *testing.T, status int, body string, got *http.Request) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		*got = *r
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(ClientConfig{URL: server.URL, Username: "prom", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestQueryRange(t *testing.T) {
+	var req http.Request
+	client := newQueryRangeServer(t, http.StatusOK, `{"status": "success", "data": {"resultType": "matrix", "result": [
+		{"metric": {"__name__": "up", "instance": "a"}, "values": [[1704067200, "1"], [1704067260, "0"]]}
+	]}}`, &req)
+
+	matrix, err := client.QueryRange(context.Background(), "up == 0", backtestAt(0), backtestAt(60), 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Method != http.MethodPost || req.URL.Path != "/api/v1/query_range" {
+		t.Errorf("request = %s %s, want POST /api/v1/query_range", req.Method, req.URL.Path)
+	}
+	if user, password, _ := req.BasicAuth(); user != "prom" || password != "secret" {
+		t.Errorf("basic auth = %s:%s, want prom:secret", user, password)
+	}
+	wantForm := map[string]string{"query": "up == 0", "start": "1704067200", "end": "1704070800", "step": "30"}
+	for name, want := range wantForm {
+		if got := req.PostForm.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	want := model.Matrix{{
+		Metric: model.Metric{"__name__": "up", "instance": "a"},
+		Values: []model.SamplePair{{Timestamp: 1704067200000, Value: 1}, {Timestamp: 1704067260000, Value: 0}},
+	}}
+	if !reflect.DeepEqual(matrix, want) {
+		t.Errorf("matrix = %v, want %v", matrix, want)
+	}
+}
+
+func TestQueryRangeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantType string
+	}{
+		{"bad expression", http.StatusBadRequest, `{"status": "error", "errorType": "bad_data", "error": "parse error"}`, "bad_data"},
+		{"query timeout", http.StatusServiceUnavailable, `{"status": "error", "errorType": "timeout", "error": "query timed out"}`, "timeout"},
+		{"not a Prometheus response", http.StatusBadGateway, `upstream unavailable`, ""},
+		{"instant vector result", http.StatusOK, `{"status": "success", "data": {"resultType": "vector", "result": []}}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req http.Request
+			client := newQueryRangeServer(t, tt.status, tt.body, &req)
+
+			_, err := client.QueryRange(context.Background(), "up", backtestAt(0), backtestAt(60), time.Minute)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				if apiErr.StatusCode != tt.status || apiErr.Type != tt.wantType {
+					t.Errorf("error = %+v, want status %d and type %q", apiErr, tt.status, tt.wantType)
+				}
+			} else if tt.status != http.StatusOK {
+				t.Errorf("err = %v, want an APIError", err)
+			}
+		})
+	}
+}
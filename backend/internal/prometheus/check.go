@@ -0,0 +1,198 @@
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+	"promeconfig-backend/internal/models"
+)
+
+// CheckError 配置检查发现的一个问题，无法定位时 Line 为0
+type CheckError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e CheckError) String() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Message)
+}
+
+var (
+	// rulefmt 的错误以 "行:列: " 开头
+	lineColumnPattern = regexp.MustCompile(`^(\d+):(\d+): (?:\d+:\d+: )?(.*)$`)
+	// yaml 的错误中包含 "line N: "
+	yamlLinePattern = regexp.MustCompile(`line (\d+): (.*)$`)
+	quotedPattern   = regexp.MustCompile(`"([^"]+)"`)
+)
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// CheckConfig 使用Prometheus自身的解析和校验逻辑检查配置，等同于 promtool check config
+// ruleFiles 为规则文件名与内容，每个文件都必须被 prometheus.yml 的 rule_files 引用；
+// rule_files 中的其他pattern可以指向Prometheus上由其他方式管理的规则文件
+func CheckConfig(promConfig []byte, ruleFiles map[string][]byte) []CheckError {
+	var errs []CheckError
+
+	names := make([]string, 0, len(ruleFiles))
+	for name := range ruleFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cfg, err := config.Load(string(promConfig), discardLogger)
+	if err != nil {
+		errs = append(errs, NewCheckErrors(ConfigFileName, promConfig, err)...)
+	} else {
+		for _, name := range names {
+			if !referenced(name, cfg.RuleFiles) {
+				errs = append(errs, CheckError{
+					File:    ConfigFileName,
+					Line:    findLine(promConfig, "rule_files:"),
+					Message: fmt.Sprintf("rule file %q is not referenced by rule_files", name),
+				})
+			}
+		}
+	}
+
+	for _, name := range names {
+		errs = append(errs, CheckRules(name, ruleFiles[name])...)
+	}
+
+	return errs
+}
+
+// CheckGlobalConfig 检查全局配置，用不含scrape配置的prometheus.yml执行 CheckConfig
+func CheckGlobalConfig(global *models.GlobalConfig) []CheckError {
+	return checkSnapshot(models.ConfigSnapshot{Global: global})
+}
+
+// CheckRemoteStorage 检查远程存储配置，用只含该远程存储的prometheus.yml执行 CheckConfig
+func CheckRemoteStorage(remote *models.RemoteStorage) []CheckError {
+	return checkSnapshot(models.ConfigSnapshot{RemoteStorages: []models.RemoteStorage{*remote}})
+}
+
+// CheckAlertmanager 检查Alertmanager配置，用只含该Alertmanager的prometheus.yml执行 CheckConfig
+func CheckAlertmanager(am *models.Alertmanager) []CheckError {
+	return checkSnapshot(models.ConfigSnapshot{Alertmanagers: []models.Alertmanager{*am}})
+}
+
+func checkSnapshot(snapshot models.ConfigSnapshot) []CheckError {
+	content, err := RenderConfig(snapshot)
+	if err != nil {
+		return []CheckError{{File: ConfigFileName, Message: err.Error()}}
+	}
+	return CheckConfig(content, map[string][]byte{RulesFileName: []byte("groups: []\n")})
+}
+
+// CheckRules 检查规则文件，等同于 promtool check rules
+func CheckRules(name string, content []byte) []CheckError {
+	_, ruleErrs := rulefmt.Parse(content, false, model.UTF8Validation, parser.NewParser(parser.Options{}), discardLogger)
+
+	// 同一个问题可能被解析器报告多次
+	var errs []CheckError
+	for _, err := range ruleErrs {
+		for _, checkErr := range NewCheckErrors(name, content, err) {
+			if !containsCheckError(errs, checkErr) {
+				errs = append(errs, checkErr)
+			}
+		}
+	}
+	return errs
+}
+
+func referenced(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCheckErrors 把解析错误转换为带行号的检查结果
+// yaml 的错误可能一次包含多行，没有行号时按错误中引用的值在文件中查找
+func NewCheckErrors(file string, content []byte, err error) []CheckError {
+	var errs []CheckError
+
+	for _, message := range strings.Split(err.Error(), "\n") {
+		message = strings.TrimSpace(message)
+		if message == "" || message == "yaml: unmarshal errors:" {
+			continue
+		}
+
+		checkErr := CheckError{File: file, Message: message}
+		if m := lineColumnPattern.FindStringSubmatch(message); m != nil {
+			checkErr.Line, _ = strconv.Atoi(m[1])
+			checkErr.Column, _ = strconv.Atoi(m[2])
+			checkErr.Message = m[3]
+		} else if m := yamlLinePattern.FindStringSubmatch(message); m != nil {
+			checkErr.Line, _ = strconv.Atoi(m[1])
+			checkErr.Message = m[2]
+		} else {
+			// 优先使用较长的值，减少误匹配
+			quoted := quotedPattern.FindAllStringSubmatch(message, -1)
+			sort.SliceStable(quoted, func(i, j int) bool { return len(quoted[i][1]) > len(quoted[j][1]) })
+			for _, q := range quoted {
+				if line := findLine(content, q[1]); line > 0 {
+					checkErr.Line = line
+					break
+				}
+			}
+		}
+
+		if !containsCheckError(errs, checkErr) {
+			errs = append(errs, checkErr)
+		}
+	}
+
+	return errs
+}
+
+func containsCheckError(errs []CheckError, target CheckError) bool {
+	for _, e := range errs {
+		if e == target {
+			return true
+		}
+	}
+	return false
+}
+
+// 查找值第一次出现的行号，优先匹配作为YAML值出现的行，找不到时返回0
+func findLine(content []byte, value string) int {
+	lines := bytes.Split(content, []byte("\n"))
+	suffixes := []string{": " + value, `: "` + value + `"`, "- " + value, `- "` + value + `"`}
+
+	for i, line := range lines {
+		trimmed := string(bytes.TrimSpace(line))
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(trimmed, suffix) {
+				return i + 1
+			}
+		}
+	}
+
+	for i, line := range lines {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("#")) {
+			continue
+		}
+		if bytes.Contains(line, []byte(value)) {
+			return i + 1
+		}
+	}
+	return 0
+}
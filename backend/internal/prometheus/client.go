@@ -0,0 +1,331 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// ClientConfig 连接Prometheus HTTP API的配置
+// 设置 BearerToken 时优先于用户名密码；CACert 为PEM格式的CA证书，与 CAFile 二选一
+type ClientConfig struct {
+	URL                string
+	Username           string
+	Password           string
+	BearerToken        string
+	InsecureSkipVerify bool
+	CAFile             string
+	CACert             string
+	Timeout            time.Duration
+}
+
+// Client Prometheus HTTP API的客户端
+type Client struct {
+	baseURL     *url.URL
+	username    string
+	password    string
+	bearerToken string
+	httpClient  *http.Client
+}
+
+// APIError Prometheus返回的错误，Type 为 bad_data、timeout、execution 等
+type APIError struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("prometheus %s: %s", e.Type, e.Message)
+	}
+	return fmt.Sprintf("prometheus returned %d: %s", e.StatusCode, e.Message)
+}
+
+type apiResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+	Warnings  []string        `json:"warnings"`
+}
+
+func NewClient(cfg ClientConfig) (*Client, error) {
+	baseURL, err := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid prometheus url: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid prometheus url %q: scheme must be http or https", cfg.URL)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		caCert, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read prometheus CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	} else if cfg.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(cfg.CACert)) {
+			return nil, fmt.Errorf("no certificates found in CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+
+	return &Client{
+		baseURL:     baseURL,
+		username:    cfg.Username,
+		password:    cfg.Password,
+		bearerToken: cfg.BearerToken,
+		httpClient:  &http.Client{Transport: transport, Timeout: timeout},
+	}, nil
+}
+
+// Forward 请求 /api/v1/<endpoint> 并原样返回状态码和响应体，GET时参数放在URL中，POST时以表单提交
+func (c *Client) Forward(ctx context.Context, method, endpoint string, params url.Values) (int, []byte, error) {
+	return c.do(ctx, method, "/api/v1/"+endpoint, params)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, params url.Values) (int, []byte, error) {
+	u := *c.baseURL
+	u.Path += path
+
+	var body io.Reader
+	if method == http.MethodGet {
+		u.RawQuery = params.Encode()
+	} else {
+		body = strings.NewReader(params.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
+
+// 请求 /api/v1/<endpoint>，返回响应中的 data
+func (c *Client) call(ctx context.Context, method, endpoint string, params url.Values) (json.RawMessage, error) {
+	status, body, err := c.Forward(ctx, method, endpoint, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result apiResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, &APIError{StatusCode: status, Message: strings.TrimSpace(string(body))}
+	}
+	if result.Status != "success" {
+		return nil, &APIError{StatusCode: status, Type: result.ErrorType, Message: result.Error}
+	}
+
+	return result.Data, nil
+}
+
+// Query 执行即时查询，结果必须是instant vector
+func (c *Client) Query(ctx context.Context, query string, ts time.Time) (model.Vector, error) {
+	data, err := c.call(ctx, http.MethodPost, "query", url.Values{
+		"query": {query},
+		"time":  {formatTime(ts)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		ResultType model.ValueType `json:"resultType"`
+		Result     model.Vector    `json:"result"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("decode query result: %w", err)
+	}
+	if result.ResultType != model.ValVector {
+		return nil, fmt.Errorf("unexpected result type %q", result.ResultType)
+	}
+
+	return result.Result, nil
+}
+
+// QueryRange 执行区间查询，结果必须是range vector
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error) {
+	data, err := c.call(ctx, http.MethodPost, "query_range", url.Values{
+		"query": {query},
+		"start": {formatTime(start)},
+		"end":   {formatTime(end)},
+		"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		ResultType model.ValueType `json:"resultType"`
+		Result     model.Matrix    `json:"result"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("decode query_range result: %w", err)
+	}
+	if result.ResultType != model.ValMatrix {
+		return nil, fmt.Errorf("unexpected result type %q", result.ResultType)
+	}
+
+	return result.Result, nil
+}
+
+// BuildInfo /api/v1/status/buildinfo 的结果
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	GoVersion string `json:"goVersion"`
+}
+
+// RuntimeInfo /api/v1/status/runtimeinfo 的结果
+type RuntimeInfo struct {
+	StartTime           time.Time `json:"startTime"`
+	ReloadConfigSuccess bool      `json:"reloadConfigSuccess"`
+	LastConfigTime      time.Time `json:"lastConfigTime"`
+}
+
+func (c *Client) BuildInfo(ctx context.Context) (*BuildInfo, error) {
+	data, err := c.call(ctx, http.MethodGet, "status/buildinfo", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var info BuildInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("decode buildinfo: %w", err)
+	}
+	return &info, nil
+}
+
+func (c *Client) RuntimeInfo(ctx context.Context) (*RuntimeInfo, error) {
+	data, err := c.call(ctx, http.MethodGet, "status/runtimeinfo", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var info RuntimeInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("decode runtimeinfo: %w", err)
+	}
+	return &info, nil
+}
+
+// RuleGroupStatus /api/v1/rules 中的一个规则组，File 为Prometheus加载的规则文件路径
+type RuleGroupStatus struct {
+	Name           string              `json:"name"`
+	File           string              `json:"file"`
+	Rules          []AlertingRuleState `json:"rules"`
+	LastEvaluation time.Time           `json:"lastEvaluation"`
+	EvaluationTime float64             `json:"evaluationTime"`
+}
+
+// AlertingRuleState 告警规则的运行状态，State 为 inactive、pending 或 firing，Health 为 ok、err 或 unknown
+type AlertingRuleState struct {
+	Name           string            `json:"name"`
+	Query          string            `json:"query"`
+	Labels         map[string]string `json:"labels"`
+	State          string            `json:"state"`
+	Health         string            `json:"health"`
+	LastError      string            `json:"lastError"`
+	LastEvaluation time.Time         `json:"lastEvaluation"`
+	EvaluationTime float64           `json:"evaluationTime"`
+	Alerts         []Alert           `json:"alerts"`
+}
+
+// Alert 规则的一个活跃告警，与 /api/v1/alerts 中的相同
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	State       string            `json:"state"`
+	ActiveAt    *time.Time        `json:"activeAt"`
+	Value       string            `json:"value"`
+}
+
+// AlertingRules 请求 /api/v1/rules?type=alert，包含每个规则的活跃告警
+// groups 不为空时只返回这些规则组（旧版本Prometheus忽略该参数）
+func (c *Client) AlertingRules(ctx context.Context, groups []string) ([]RuleGroupStatus, error) {
+	data, err := c.call(ctx, http.MethodGet, "rules", url.Values{"type": {"alert"}, "rule_group[]": groups})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Groups []RuleGroupStatus `json:"groups"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("decode rules: %w", err)
+	}
+	return result.Groups, nil
+}
+
+// Healthy 请求 /-/healthy
+func (c *Client) Healthy(ctx context.Context) error {
+	return c.management(ctx, http.MethodGet, "/-/healthy")
+}
+
+// Reload 请求 /-/reload 重新加载配置，Prometheus需要以 --web.enable-lifecycle 启动
+func (c *Client) Reload(ctx context.Context) error {
+	return c.management(ctx, http.MethodPost, "/-/reload")
+}
+
+// 管理接口只返回纯文本，非2xx时把响应内容作为错误信息
+func (c *Client) management(ctx context.Context, method, path string) error {
+	status, body, err := c.do(ctx, method, path, nil)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return &APIError{StatusCode: status, Message: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', -1, 64)
+}
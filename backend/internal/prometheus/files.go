@@ -0,0 +1,143 @@
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteFiles 把渲染结果写入输出目录
+// 每个文件先写入临时文件再重命名，Prometheus不会读到写了一半的文件
+func WriteFiles(dir string, files map[string][]byte) error {
+	var staged StagedFiles
+	if err := staged.Stage(dir, files); err != nil {
+		return err
+	}
+	return staged.Commit()
+}
+
+// WriteSecretFiles 把密钥文件写入目录，只有属主可读，目录中其他不再引用的文件被删除
+func WriteSecretFiles(dir string, files map[string][]byte) error {
+	var staged StagedFiles
+	if err := staged.StageSecrets(dir, files); err != nil {
+		return err
+	}
+	return staged.Commit()
+}
+
+// StagedFiles 已写入临时文件、还未替换的输出文件
+// 数据库事务提交后调用 Commit 重命名为正式文件，事务回滚时调用 Discard 删除临时文件，
+// 这样事务失败时输出目录保持不变
+type StagedFiles struct {
+	renames []stagedRename
+	removes []string
+}
+
+type stagedRename struct {
+	tmp, path string
+}
+
+// Stage 把文件写入目录中的临时文件
+func (s *StagedFiles) Stage(dir string, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+	return s.stage(dir, files, 0o644)
+}
+
+// StageSecrets 把密钥文件写入目录中的临时文件，只有属主可读，Commit 时删除目录中其他不再引用的文件
+func (s *StagedFiles) StageSecrets(dir string, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create secrets dir: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read secrets dir: %w", err)
+	}
+	for _, entry := range entries {
+		if _, ok := files[entry.Name()]; ok || entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		s.removes = append(s.removes, filepath.Join(dir, entry.Name()))
+	}
+
+	return s.stage(dir, files, 0o600)
+}
+
+func (s *StagedFiles) stage(dir string, files map[string][]byte, perm fs.FileMode) error {
+	for name, content := range files {
+		tmp, err := writeTempFile(dir, name, content, perm)
+		if err != nil {
+			return err
+		}
+		s.renames = append(s.renames, stagedRename{tmp: tmp, path: filepath.Join(dir, name)})
+	}
+	return nil
+}
+
+// Commit 按暂存的顺序把临时文件重命名为正式文件，再删除不再引用的密钥文件
+func (s *StagedFiles) Commit() error {
+	defer s.Discard()
+	for len(s.renames) > 0 {
+		rename := s.renames[0]
+		if err := os.Rename(rename.tmp, rename.path); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filepath.Base(rename.path), err)
+		}
+		s.renames = s.renames[1:]
+	}
+	for _, path := range s.removes {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", filepath.Base(path), err)
+		}
+	}
+	s.removes = nil
+	return nil
+}
+
+// Discard 删除还未重命名的临时文件，Commit 之后调用没有影响
+func (s *StagedFiles) Discard() {
+	for _, rename := range s.renames {
+		os.Remove(rename.tmp)
+	}
+	s.renames = nil
+	s.removes = nil
+}
+
+func writeTempFile(dir, name string, content []byte, perm fs.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return tmp.Name(), nil
+}
+
+// ReadFiles 读取输出目录中的文件，不存在的文件内容为空
+func ReadFiles(dir string, names ...string) (map[string][]byte, error) {
+	files := make(map[string][]byte, len(names))
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files[name] = content
+	}
+	return files, nil
+}
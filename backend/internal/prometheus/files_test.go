@@ -0,0 +1,71 @@
+package prometheus
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func dirContents(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{}
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[entry.Name()] = string(content)
+	}
+	return contents
+}
+
+func TestStagedFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteSecretFiles(dir, map[string][]byte{"old": []byte("old"), "kept": []byte("v1")}); err != nil {
+		t.Fatal(err)
+	}
+	before := map[string]string{"old": "old", "kept": "v1"}
+
+	// 丢弃后目录保持不变，不留下临时文件
+	var discarded StagedFiles
+	if err := discarded.StageSecrets(dir, map[string][]byte{"kept": []byte("v2"), "new": []byte("new")}); err != nil {
+		t.Fatal(err)
+	}
+	discarded.Discard()
+	if got := dirContents(t, dir); !reflect.DeepEqual(got, before) {
+		t.Errorf("after discard = %v, want %v", got, before)
+	}
+
+	// 提交前正式文件不变，提交后替换并删除不再引用的文件
+	var staged StagedFiles
+	if err := staged.StageSecrets(dir, map[string][]byte{"kept": []byte("v2"), "new": []byte("new")}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "kept")); string(got) != "v1" {
+		t.Errorf("kept = %q before commit", got)
+	}
+	if err := staged.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dirContents(t, dir), map[string]string{"kept": "v2", "new": "new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after commit = %v, want %v", got, want)
+	}
+	info, err := os.Stat(filepath.Join(dir, "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// 提交后再丢弃没有影响
+	staged.Discard()
+	if got := dirContents(t, dir); len(got) != 2 {
+		t.Errorf("after discard = %v", got)
+	}
+}
@@ -0,0 +1,150 @@
+package prometheus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"promeconfig-backend/internal/models"
+)
+
+// 告警规则检查项的名称
+const (
+	LintExpr        = "expr"
+	LintSeverity    = "severity"
+	LintAnnotations = "annotations"
+	LintFor         = "for"
+	LintRateWindow  = "rate_window"
+	LintAggregation = "aggregation"
+)
+
+// LintOptions 告警规则的检查项，ScrapeInterval 为规则所在实例中最大的全局抓取间隔，
+// JobScrapeIntervals 为单独设置了抓取间隔的job，表达式按 job 标签精确匹配时使用
+type LintOptions struct {
+	AllowedSeverities   []string
+	RequiredAnnotations []string
+	CheckFor            bool
+	RateWindowScrapes   int
+	CheckAggregations   bool
+	ScrapeInterval      time.Duration
+	JobScrapeIntervals  map[string]time.Duration
+}
+
+// 区间需要覆盖多次抓取的函数
+var rateFunctions = map[string]bool{"rate": true, "increase": true}
+
+// LintRule 按团队约定检查告警规则，返回的问题级别都为 warning
+func LintRule(rule models.CreateAlertRuleRequest, opts LintOptions) []models.LintIssue {
+	issues := []models.LintIssue{}
+	warn := func(check, format string, args ...interface{}) {
+		issues = append(issues, models.LintIssue{Check: check, Level: "warning", Message: fmt.Sprintf(format, args...)})
+	}
+
+	var ruleLabels, annotations map[string]string
+	if err := unmarshalOptional(rule.Labels, &ruleLabels); err != nil {
+		warn(LintSeverity, "labels must be an object of strings: %v", err)
+	}
+	if err := unmarshalOptional(rule.Annotations, &annotations); err != nil {
+		warn(LintAnnotations, "annotations must be an object of strings: %v", err)
+	}
+
+	if len(opts.AllowedSeverities) > 0 {
+		severity, ok := ruleLabels["severity"]
+		switch {
+		case !ok || severity == "":
+			warn(LintSeverity, "severity label is required, allowed values: %v", opts.AllowedSeverities)
+		case !contains(opts.AllowedSeverities, severity):
+			warn(LintSeverity, "severity %q is not allowed, allowed values: %v", severity, opts.AllowedSeverities)
+		}
+	}
+	for _, name := range opts.RequiredAnnotations {
+		if annotations[name] == "" {
+			warn(LintAnnotations, "%s annotation is required", name)
+		}
+	}
+
+	expr, err := parser.NewParser(parser.Options{}).ParseExpr(rule.Expr)
+	if err != nil {
+		warn(LintExpr, "invalid expression: %v", err)
+		return issues
+	}
+
+	if opts.CheckFor && hasThreshold(expr) {
+		forDuration, err := model.ParseDuration(rule.ForDuration)
+		if rule.ForDuration == "" || (err == nil && forDuration == 0) {
+			warn(LintFor, "threshold alert without for fires on a single evaluation, set for to avoid flapping")
+		}
+	}
+
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.Call:
+			if opts.RateWindowScrapes <= 0 || !rateFunctions[n.Func.Name] || len(n.Args) == 0 {
+				return nil
+			}
+			matrix, ok := n.Args[0].(*parser.MatrixSelector)
+			if !ok {
+				return nil
+			}
+			interval := opts.ScrapeInterval
+			if vs, ok := matrix.VectorSelector.(*parser.VectorSelector); ok {
+				for _, m := range vs.LabelMatchers {
+					if m.Name == "job" && m.Type == labels.MatchEqual {
+						if jobInterval, ok := opts.JobScrapeIntervals[m.Value]; ok {
+							interval = jobInterval
+						}
+					}
+				}
+			}
+			minimum := interval * time.Duration(opts.RateWindowScrapes)
+			if matrix.Range < minimum {
+				warn(LintRateWindow, "%s() window [%s] is shorter than %d times the scrape interval %s, use at least [%s]",
+					n.Func.Name, model.Duration(matrix.Range), opts.RateWindowScrapes, model.Duration(interval), model.Duration(minimum))
+			}
+		case *parser.AggregateExpr:
+			if opts.CheckAggregations && !n.Without && len(n.Grouping) == 0 {
+				warn(LintAggregation, "%s without by (...) drops all labels, the alert cannot tell which service or instance is affected", n.Op)
+			}
+		}
+		return nil
+	})
+
+	return issues
+}
+
+// 表达式中是否有与常量比较的阈值过滤（不带 bool）
+func hasThreshold(expr parser.Expr) bool {
+	found := false
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		if b, ok := node.(*parser.BinaryExpr); ok && b.Op.IsComparisonOperator() && !b.ReturnBool {
+			if isNumber(b.LHS) || isNumber(b.RHS) {
+				found = true
+			}
+		}
+		return nil
+	})
+	return found
+}
+
+func isNumber(expr parser.Expr) bool {
+	switch e := expr.(type) {
+	case *parser.NumberLiteral:
+		return true
+	case *parser.ParenExpr:
+		return isNumber(e.Expr)
+	case *parser.UnaryExpr:
+		return isNumber(e.Expr)
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
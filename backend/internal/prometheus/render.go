@@ -0,0 +1,421 @@
+package prometheus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+	"promeconfig-backend/internal/models"
+)
+
+const (
+	ConfigFileName = "prometheus.yml"
+	RulesFileName  = "alerts.yml"
+
+	// 未指定规则组的告警规则归入默认规则组
+	DefaultRuleGroup = "default"
+
+	// 全局配置未设置时的采集和评估间隔
+	DefaultScrapeInterval     = "15s"
+	DefaultEvaluationInterval = "15s"
+
+	// 密钥文件所在的目录，相对于输出目录，Prometheus按配置文件所在目录解析相对路径
+	SecretsDir = "secrets"
+
+	generatedHeader = "# Generated by PromeConfig, do not edit manually\n"
+)
+
+type Config struct {
+	Global        GlobalConfig        `yaml:"global"`
+	RuleFiles     []string            `yaml:"rule_files,omitempty"`
+	ScrapeConfigs []ScrapeConfig      `yaml:"scrape_configs"`
+	Alerting      *AlertingConfig     `yaml:"alerting,omitempty"`
+	RemoteWrite   []RemoteWriteConfig `yaml:"remote_write,omitempty"`
+	RemoteRead    []RemoteReadConfig  `yaml:"remote_read,omitempty"`
+}
+
+type GlobalConfig struct {
+	ScrapeInterval     string            `yaml:"scrape_interval,omitempty"`
+	ScrapeTimeout      string            `yaml:"scrape_timeout,omitempty"`
+	EvaluationInterval string            `yaml:"evaluation_interval,omitempty"`
+	ExternalLabels     map[string]string `yaml:"external_labels,omitempty"`
+	QueryLogFile       string            `yaml:"query_log_file,omitempty"`
+}
+
+type ScrapeConfig struct {
+	JobName              string         `yaml:"job_name"`
+	ScrapeInterval       string         `yaml:"scrape_interval,omitempty"`
+	MetricsPath          string         `yaml:"metrics_path,omitempty"`
+	StaticConfigs        []StaticConfig `yaml:"static_configs"`
+	RelabelConfigs       []interface{}  `yaml:"relabel_configs,omitempty"`
+	MetricRelabelConfigs []interface{}  `yaml:"metric_relabel_configs,omitempty"`
+}
+
+type AlertingConfig struct {
+	AlertRelabelConfigs []interface{}        `yaml:"alert_relabel_configs,omitempty"`
+	Alertmanagers       []AlertmanagerConfig `yaml:"alertmanagers,omitempty"`
+}
+
+// AlertmanagerConfig 的服务发现配置以 SDConfigs 的键直接展开
+type AlertmanagerConfig struct {
+	Scheme           string `yaml:"scheme,omitempty"`
+	PathPrefix       string `yaml:"path_prefix,omitempty"`
+	APIVersion       string `yaml:"api_version,omitempty"`
+	Timeout          string `yaml:"timeout,omitempty"`
+	HTTPClientConfig `yaml:",inline"`
+	StaticConfigs    []StaticConfig         `yaml:"static_configs,omitempty"`
+	SDConfigs        map[string]interface{} `yaml:",inline"`
+	RelabelConfigs   []interface{}          `yaml:"relabel_configs,omitempty"`
+}
+
+// HTTPClientConfig 远程存储和Alertmanager的认证和TLS设置，密钥以文件引用
+type HTTPClientConfig struct {
+	BasicAuth     *BasicAuth     `yaml:"basic_auth,omitempty"`
+	Authorization *Authorization `yaml:"authorization,omitempty"`
+	TLSConfig     *TLSConfig     `yaml:"tls_config,omitempty"`
+}
+
+type BasicAuth struct {
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+}
+
+type Authorization struct {
+	CredentialsFile string `yaml:"credentials_file"`
+}
+
+type TLSConfig struct {
+	CA                 string `yaml:"ca,omitempty"`
+	Cert               string `yaml:"cert,omitempty"`
+	KeyFile            string `yaml:"key_file,omitempty"`
+	ServerName         string `yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+}
+
+type RemoteWriteConfig struct {
+	URL                 string            `yaml:"url"`
+	Name                string            `yaml:"name,omitempty"`
+	RemoteTimeout       string            `yaml:"remote_timeout,omitempty"`
+	Headers             map[string]string `yaml:"headers,omitempty"`
+	HTTPClientConfig    `yaml:",inline"`
+	WriteRelabelConfigs []interface{} `yaml:"write_relabel_configs,omitempty"`
+	QueueConfig         interface{}   `yaml:"queue_config,omitempty"`
+	MetadataConfig      interface{}   `yaml:"metadata_config,omitempty"`
+}
+
+type RemoteReadConfig struct {
+	URL              string            `yaml:"url"`
+	Name             string            `yaml:"name,omitempty"`
+	RemoteTimeout    string            `yaml:"remote_timeout,omitempty"`
+	Headers          map[string]string `yaml:"headers,omitempty"`
+	ReadRecent       bool              `yaml:"read_recent,omitempty"`
+	RequiredMatchers map[string]string `yaml:"required_matchers,omitempty"`
+	HTTPClientConfig `yaml:",inline"`
+}
+
+type StaticConfig struct {
+	Targets []string `yaml:"targets"`
+}
+
+type RuleGroups struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+type RuleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+type Rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// BuildConfig 根据实例的快照生成prometheus.yml的结构，包括targets、全局配置、Alertmanager和远程存储
+// snapshot.Global 为nil时使用默认值，生成的规则文件总是在 rule_files 的第一项
+func BuildConfig(snapshot models.ConfigSnapshot) (*Config, error) {
+	cfg := &Config{
+		Global: GlobalConfig{
+			ScrapeInterval:     DefaultScrapeInterval,
+			EvaluationInterval: DefaultEvaluationInterval,
+		},
+		RuleFiles:     []string{RulesFileName},
+		ScrapeConfigs: []ScrapeConfig{},
+	}
+
+	if global := snapshot.Global; global != nil {
+		if global.ScrapeInterval != "" {
+			cfg.Global.ScrapeInterval = global.ScrapeInterval
+		}
+		if global.EvaluationInterval != "" {
+			cfg.Global.EvaluationInterval = global.EvaluationInterval
+		}
+		cfg.Global.ScrapeTimeout = global.ScrapeTimeout
+		cfg.Global.ExternalLabels = global.ExternalLabels
+		cfg.Global.QueryLogFile = global.QueryLogFile
+
+		for _, pattern := range global.RuleFiles {
+			if !slices.Contains(cfg.RuleFiles, pattern) {
+				cfg.RuleFiles = append(cfg.RuleFiles, pattern)
+			}
+		}
+	}
+
+	for _, target := range snapshot.Targets {
+		scrape := ScrapeConfig{
+			JobName:        target.JobName,
+			ScrapeInterval: target.ScrapeInterval,
+			MetricsPath:    target.MetricsPath,
+		}
+
+		var addresses []string
+		if err := json.Unmarshal(target.Targets, &addresses); err != nil {
+			return nil, fmt.Errorf("job %q: invalid targets: %w", target.JobName, err)
+		}
+		scrape.StaticConfigs = []StaticConfig{{Targets: addresses}}
+
+		if err := unmarshalOptional(target.RelabelConfigs, &scrape.RelabelConfigs); err != nil {
+			return nil, fmt.Errorf("job %q: invalid relabel_configs: %w", target.JobName, err)
+		}
+		if err := unmarshalOptional(target.MetricRelabelConfigs, &scrape.MetricRelabelConfigs); err != nil {
+			return nil, fmt.Errorf("job %q: invalid metric_relabel_configs: %w", target.JobName, err)
+		}
+
+		cfg.ScrapeConfigs = append(cfg.ScrapeConfigs, scrape)
+	}
+
+	alerting, err := buildAlerting(snapshot.Global, snapshot.Alertmanagers)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Alerting = alerting
+
+	for _, remote := range snapshot.RemoteStorages {
+		client := buildHTTPClientConfig(remote.ID, &remote.ClientAuth)
+
+		if remote.Kind == "read" {
+			cfg.RemoteRead = append(cfg.RemoteRead, RemoteReadConfig{
+				URL:              remote.URL,
+				Name:             remote.Name,
+				RemoteTimeout:    remote.RemoteTimeout,
+				Headers:          remote.Headers,
+				ReadRecent:       remote.ReadRecent,
+				RequiredMatchers: remote.RequiredMatchers,
+				HTTPClientConfig: client,
+			})
+			continue
+		}
+
+		write := RemoteWriteConfig{
+			URL:              remote.URL,
+			Name:             remote.Name,
+			RemoteTimeout:    remote.RemoteTimeout,
+			Headers:          remote.Headers,
+			HTTPClientConfig: client,
+		}
+		if err := unmarshalOptional(remote.WriteRelabelConfigs, &write.WriteRelabelConfigs); err != nil {
+			return nil, fmt.Errorf("remote_write %q: invalid write_relabel_configs: %w", remote.Name, err)
+		}
+		if err := unmarshalOptional(remote.QueueConfig, &write.QueueConfig); err != nil {
+			return nil, fmt.Errorf("remote_write %q: invalid queue_config: %w", remote.Name, err)
+		}
+		if err := unmarshalOptional(remote.MetadataConfig, &write.MetadataConfig); err != nil {
+			return nil, fmt.Errorf("remote_write %q: invalid metadata_config: %w", remote.Name, err)
+		}
+		cfg.RemoteWrite = append(cfg.RemoteWrite, write)
+	}
+
+	return cfg, nil
+}
+
+// alerting 配置，没有Alertmanager和 alert_relabel_configs 时返回nil
+func buildAlerting(global *models.GlobalConfig, alertmanagers []models.Alertmanager) (*AlertingConfig, error) {
+	alerting := &AlertingConfig{}
+	if global != nil {
+		if err := unmarshalOptional(global.AlertRelabelConfigs, &alerting.AlertRelabelConfigs); err != nil {
+			return nil, fmt.Errorf("invalid alert_relabel_configs: %w", err)
+		}
+	}
+
+	for _, am := range alertmanagers {
+		config := AlertmanagerConfig{
+			Scheme:           am.Scheme,
+			PathPrefix:       am.PathPrefix,
+			APIVersion:       am.APIVersion,
+			Timeout:          am.Timeout,
+			HTTPClientConfig: buildHTTPClientConfig(am.ID, &am.ClientAuth),
+		}
+		if len(am.StaticTargets) > 0 {
+			config.StaticConfigs = []StaticConfig{{Targets: am.StaticTargets}}
+		}
+		if err := unmarshalOptional(am.SDConfigs, &config.SDConfigs); err != nil {
+			return nil, fmt.Errorf("alertmanager %q: invalid sd_configs: %w", am.Name, err)
+		}
+		if err := unmarshalOptional(am.RelabelConfigs, &config.RelabelConfigs); err != nil {
+			return nil, fmt.Errorf("alertmanager %q: invalid relabel_configs: %w", am.Name, err)
+		}
+		alerting.Alertmanagers = append(alerting.Alertmanagers, config)
+	}
+
+	if len(alerting.AlertRelabelConfigs) == 0 && len(alerting.Alertmanagers) == 0 {
+		return nil, nil
+	}
+	return alerting, nil
+}
+
+// 认证和TLS设置，密钥引用 SecretFiles 生成的文件
+func buildHTTPClientConfig(id uuid.UUID, auth *models.ClientAuth) HTTPClientConfig {
+	var client HTTPClientConfig
+	if auth.Username != "" || auth.Password != "" {
+		client.BasicAuth = &BasicAuth{Username: auth.Username}
+		if auth.Password != "" {
+			client.BasicAuth.PasswordFile = secretPath(id, "password")
+		}
+	}
+	if auth.BearerToken != "" {
+		client.Authorization = &Authorization{CredentialsFile: secretPath(id, "bearer_token")}
+	}
+
+	tls := TLSConfig{
+		CA:                 auth.TLSCACert,
+		Cert:               auth.TLSCert,
+		ServerName:         auth.TLSServerName,
+		InsecureSkipVerify: auth.InsecureSkipVerify,
+	}
+	if auth.TLSKey != "" {
+		tls.KeyFile = secretPath(id, "tls_key")
+	}
+	if tls != (TLSConfig{}) {
+		client.TLSConfig = &tls
+	}
+	return client
+}
+
+// 密钥文件名，按远程存储或Alertmanager的ID和字段区分
+func secretName(id uuid.UUID, field string) string {
+	return id.String() + "." + field
+}
+
+func secretPath(id uuid.UUID, field string) string {
+	return path.Join(SecretsDir, secretName(id, field))
+}
+
+// SecretFiles 远程存储和Alertmanager引用的密钥文件，文件名相对于 SecretsDir
+func SecretFiles(snapshot models.ConfigSnapshot) map[string][]byte {
+	files := map[string][]byte{}
+	add := func(id uuid.UUID, auth *models.ClientAuth) {
+		if auth.Password != "" {
+			files[secretName(id, "password")] = []byte(auth.Password)
+		}
+		if auth.BearerToken != "" {
+			files[secretName(id, "bearer_token")] = []byte(auth.BearerToken)
+		}
+		if auth.TLSKey != "" {
+			files[secretName(id, "tls_key")] = []byte(auth.TLSKey)
+		}
+	}
+	for _, remote := range snapshot.RemoteStorages {
+		add(remote.ID, &remote.ClientAuth)
+	}
+	for _, am := range snapshot.Alertmanagers {
+		add(am.ID, &am.ClientAuth)
+	}
+	return files
+}
+
+// BuildRules 根据告警规则生成规则文件的结构，按 group_name 分组，规则组按名称排序
+func BuildRules(rules []models.AlertRule) (*RuleGroups, error) {
+	groups := map[string]*RuleGroup{}
+
+	for _, rule := range rules {
+		name := rule.GroupName
+		if name == "" {
+			name = DefaultRuleGroup
+		}
+		group, ok := groups[name]
+		if !ok {
+			group = &RuleGroup{Name: name, Rules: []Rule{}}
+			groups[name] = group
+		}
+
+		r := Rule{
+			Alert: rule.AlertName,
+			Expr:  rule.Expr,
+			For:   rule.ForDuration,
+		}
+		if err := unmarshalOptional(rule.Labels, &r.Labels); err != nil {
+			return nil, fmt.Errorf("alert %q: invalid labels: %w", rule.AlertName, err)
+		}
+		if err := unmarshalOptional(rule.Annotations, &r.Annotations); err != nil {
+			return nil, fmt.Errorf("alert %q: invalid annotations: %w", rule.AlertName, err)
+		}
+		group.Rules = append(group.Rules, r)
+	}
+
+	// 没有规则时保留一个空的默认规则组，与之前的输出一致
+	if len(groups) == 0 {
+		groups[DefaultRuleGroup] = &RuleGroup{Name: DefaultRuleGroup, Rules: []Rule{}}
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := &RuleGroups{Groups: make([]RuleGroup, 0, len(names))}
+	for _, name := range names {
+		result.Groups = append(result.Groups, *groups[name])
+	}
+	return result, nil
+}
+
+// RenderConfig 生成prometheus.yml内容
+func RenderConfig(snapshot models.ConfigSnapshot) ([]byte, error) {
+	cfg, err := BuildConfig(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return marshal(cfg)
+}
+
+// RenderRules 生成告警规则文件内容
+func RenderRules(rules []models.AlertRule) ([]byte, error) {
+	groups, err := BuildRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	return marshal(groups)
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(generatedHeader)
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// JSONB字段可能为空或null
+func unmarshalOptional(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,69 @@
+package prometheus
+
+import (
+	"path/filepath"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// FindAlertingRule 在 /api/v1/rules 的结果中查找生成的规则文件里对应的告警规则
+// 优先匹配名称、标签和表达式都相同的规则；都不相同时，同名规则只有一个或标签相同的视为同一规则，
+// 此时 current 为false，表示Prometheus加载的还是修改前的规则
+func FindAlertingRule(groups []RuleGroupStatus, groupName, alertName, expr string, ruleLabels map[string]string) (rule *AlertingRuleState, current bool) {
+	var candidates []*AlertingRuleState
+	for i := range groups {
+		group := &groups[i]
+		if group.Name != groupName || filepath.Base(group.File) != RulesFileName {
+			continue
+		}
+		for j := range group.Rules {
+			if group.Rules[j].Name == alertName {
+				candidates = append(candidates, &group.Rules[j])
+			}
+		}
+	}
+
+	var sameLabels *AlertingRuleState
+	for _, candidate := range candidates {
+		if !equalLabels(candidate.Labels, ruleLabels) {
+			continue
+		}
+		if sameExpr(candidate.Query, expr) {
+			return candidate, true
+		}
+		if sameLabels == nil {
+			sameLabels = candidate
+		}
+	}
+	if sameLabels != nil {
+		return sameLabels, false
+	}
+	if len(candidates) == 1 {
+		return candidates[0], false
+	}
+	return nil, false
+}
+
+func equalLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if other, ok := b[name]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Prometheus返回的 query 是格式化后的表达式，比较前同样格式化
+func sameExpr(query, expr string) bool {
+	if query == expr {
+		return true
+	}
+	parsed, err := parser.NewParser(parser.Options{}).ParseExpr(expr)
+	if err != nil {
+		return false
+	}
+	return parsed.String() == query
+}
@@ -0,0 +1,328 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/promql/promqltest"
+	"github.com/prometheus/prometheus/rules"
+	"promeconfig-backend/internal/models"
+)
+
+const (
+	defaultRuleTestInterval = time.Minute
+	// 限制单个测试的评估次数，避免过长的eval_time占满CPU
+	maxRuleTestSteps = 10000
+	// 输入序列展开后的样本数上限，a+bxN 在解析时一次性展开，过大的N会占满内存
+	maxRuleTestSeriesSamples = 10000
+	maxRuleTestSamples       = 100000
+)
+
+// 序列值中的重复次数，如 1+2x10、_x5，x和次数之间可以有空格
+var seriesRepeatPattern = regexp.MustCompile(`(?i)x\s*(\d+)`)
+
+// 不解析地估算序列值展开后的样本数，超过 limit 时返回 limit+1
+// 每个重复项按 N+1 个样本计算，其余每项按1个计算，结果不小于实际的样本数
+func seriesSampleCount(values string, limit int) int {
+	count := len(strings.Fields(values))
+	for _, match := range seriesRepeatPattern.FindAllStringSubmatch(values, -1) {
+		times, err := strconv.Atoi(match[1])
+		if err != nil || times > limit {
+			return limit + 1
+		}
+		count += times + 1
+		if count > limit {
+			return limit + 1
+		}
+	}
+	return min(count, limit+1)
+}
+
+// 从内存中的规则文件加载规则组，替代 rules.FileLoader
+type memoryRuleLoader struct {
+	files  map[string][]byte
+	parser parser.Parser
+}
+
+func (l memoryRuleLoader) Load(identifier string, ignoreUnknownFields bool, nameValidationScheme model.ValidationScheme) (*rulefmt.RuleGroups, []error) {
+	content, ok := l.files[identifier]
+	if !ok {
+		return nil, []error{fmt.Errorf("rule file %q not found", identifier)}
+	}
+	return rulefmt.Parse(content, ignoreUnknownFields, nameValidationScheme, l.parser, discardLogger)
+}
+
+func (l memoryRuleLoader) Parse(query string) (parser.Expr, error) {
+	return l.parser.ParseExpr(query)
+}
+
+func parseOptionalDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := model.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration %q must be positive", value)
+	}
+	return time.Duration(d), nil
+}
+
+// 生成 promqltest 的 load 命令
+func seriesLoadingString(interval time.Duration, series []models.RuleTestSeries) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "load %s\n", model.Duration(interval))
+	for _, s := range series {
+		fmt.Fprintf(&b, "  %s %s\n", s.Series, s.Values)
+	}
+	return b.String()
+}
+
+// ValidateRuleTestSpec 检查测试的时间和输入序列格式，不执行测试
+func ValidateRuleTestSpec(spec models.RuleTestSpec) error {
+	if _, err := parseOptionalDuration(spec.Interval, defaultRuleTestInterval); err != nil {
+		return fmt.Errorf("invalid interval: %w", err)
+	}
+	if _, err := parseOptionalDuration(spec.EvaluationInterval, defaultRuleTestInterval); err != nil {
+		return fmt.Errorf("invalid evaluation_interval: %w", err)
+	}
+
+	// 解析前先检查展开后的样本数，series 只能是序列选择器，值都在 values 中
+	p := parser.NewParser(parser.Options{})
+	total := 0
+	for _, s := range spec.InputSeries {
+		if _, err := p.ParseMetric(s.Series); err != nil {
+			return fmt.Errorf("invalid input series %q: %w", s.Series, err)
+		}
+		count := seriesSampleCount(s.Values, maxRuleTestSeriesSamples)
+		if count > maxRuleTestSeriesSamples {
+			return fmt.Errorf("input series %q has too many samples, max is %d", s.Series, maxRuleTestSeriesSamples)
+		}
+		if total += count; total > maxRuleTestSamples {
+			return fmt.Errorf("input series have too many samples, max is %d", maxRuleTestSamples)
+		}
+	}
+
+	for _, s := range spec.InputSeries {
+		if _, _, err := p.ParseSeriesDesc(s.Series + " " + s.Values); err != nil {
+			return fmt.Errorf("invalid input series %q: %w", s.Series, err)
+		}
+	}
+
+	for _, tc := range spec.AlertRuleTests {
+		if tc.Alertname == "" {
+			return fmt.Errorf("alert_rule_test at eval_time %s misses alertname", tc.EvalTime)
+		}
+		if _, err := model.ParseDuration(tc.EvalTime); err != nil {
+			return fmt.Errorf("invalid eval_time %q: %w", tc.EvalTime, err)
+		}
+	}
+
+	return nil
+}
+
+// RunRuleTest 在内存存储中执行一个测试组，逻辑与 promtool test rules 一致
+// ruleFiles 为规则文件名与内容，groupName 不为空时只加载该规则组
+// 返回的错误表示测试本身无法执行（格式错误、规则求值失败），断言失败体现在结果中
+func RunRuleTest(ruleFiles map[string][]byte, groupName string, spec models.RuleTestSpec) ([]models.RuleTestCaseResult, error) {
+	if err := ValidateRuleTestSpec(spec); err != nil {
+		return nil, err
+	}
+	interval, _ := parseOptionalDuration(spec.Interval, defaultRuleTestInterval)
+	evalInterval, _ := parseOptionalDuration(spec.EvaluationInterval, defaultRuleTestInterval)
+
+	type evalCase struct {
+		at time.Duration
+		tc models.AlertRuleTestCase
+	}
+	cases := make([]evalCase, 0, len(spec.AlertRuleTests))
+	var maxEval time.Duration
+	for _, tc := range spec.AlertRuleTests {
+		d, _ := model.ParseDuration(tc.EvalTime)
+		cases = append(cases, evalCase{at: time.Duration(d), tc: tc})
+		maxEval = max(maxEval, time.Duration(d))
+	}
+	sort.SliceStable(cases, func(i, j int) bool { return cases[i].at < cases[j].at })
+
+	if maxEval/evalInterval > maxRuleTestSteps {
+		return nil, fmt.Errorf("too many evaluation steps, max is %d", maxRuleTestSteps)
+	}
+
+	suite, err := promqltest.NewLazyLoader(seriesLoadingString(interval, spec.InputSeries), promqltest.LazyLoaderOpts{
+		EnableAtModifier:     true,
+		EnableNegativeOffset: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer suite.Close()
+	suite.SubqueryInterval = evalInterval
+
+	p := parser.NewParser(parser.Options{})
+	manager := rules.NewManager(&rules.ManagerOptions{
+		QueryFunc:   rules.EngineQueryFunc(suite.QueryEngine(), suite.Storage()),
+		Appendable:  suite.Storage(),
+		Context:     context.Background(),
+		NotifyFunc:  func(context.Context, string, ...*rules.Alert) {},
+		Logger:      discardLogger,
+		GroupLoader: memoryRuleLoader{files: ruleFiles, parser: p},
+		Parser:      p,
+	})
+
+	names := make([]string, 0, len(ruleFiles))
+	for name := range ruleFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groupsMap, errs := manager.LoadGroups(interval, labels.FromMap(spec.ExternalLabels), "", nil, false, names...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	var groups []*rules.Group
+	for _, g := range groupsMap {
+		if groupName != "" && g.Name() != groupName {
+			continue
+		}
+		groups = append(groups, g)
+	}
+	if groupName != "" && len(groups) == 0 {
+		return nil, fmt.Errorf("rule group %q not found", groupName)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].File() != groups[j].File() {
+			return groups[i].File() < groups[j].File()
+		}
+		return groups[i].Name() < groups[j].Name()
+	})
+
+	// 标记为已恢复，评估时才会生成 ALERTS 序列
+	for _, g := range groups {
+		for _, r := range g.Rules() {
+			if alertRule, ok := r.(*rules.AlertingRule); ok {
+				alertRule.SetRestored(true)
+			}
+		}
+	}
+
+	results := make([]models.RuleTestCaseResult, 0, len(cases))
+	mint := time.Unix(0, 0).UTC()
+	maxt := mint.Add(maxEval)
+	curr := 0
+
+	for ts := mint; !ts.After(maxt); ts = ts.Add(evalInterval) {
+		var loadErr, evalErr error
+		suite.WithSamplesTill(ts, func(err error) {
+			if err != nil {
+				loadErr = err
+				return
+			}
+			for _, g := range groups {
+				g.Eval(suite.Context(), ts)
+				for _, r := range g.Rules() {
+					if r.LastError() != nil && evalErr == nil {
+						evalErr = fmt.Errorf("rule %s at %s: %w", r.Name(), ts.Sub(mint), r.LastError())
+					}
+				}
+			}
+		})
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		if evalErr != nil {
+			return nil, evalErr
+		}
+
+		// eval_time 落在 [ts, ts+evalInterval) 内的用例与本次评估结果比较
+		for curr < len(cases) && cases[curr].at < ts.Add(evalInterval).Sub(mint) {
+			results = append(results, compareAlerts(groups, cases[curr].tc))
+			curr++
+		}
+	}
+
+	return results, nil
+}
+
+// 比较某个告警在当前时间点的firing实例与期望值
+func compareAlerts(groups []*rules.Group, tc models.AlertRuleTestCase) models.RuleTestCaseResult {
+	got := []models.AlertSample{}
+	for _, g := range groups {
+		for _, r := range g.Rules() {
+			ar, ok := r.(*rules.AlertingRule)
+			if !ok || ar.Name() != tc.Alertname {
+				continue
+			}
+			for _, a := range ar.ActiveAlerts() {
+				if a.State == rules.StateFiring {
+					got = append(got, models.AlertSample{
+						Labels:      a.Labels.Map(),
+						Annotations: a.Annotations.Map(),
+					})
+				}
+			}
+		}
+	}
+
+	// 用户只写规则中的标签，alertname 由Prometheus在评估时加上
+	expected := []models.AlertSample{}
+	for _, exp := range tc.ExpAlerts {
+		lbls := map[string]string{labels.AlertName: tc.Alertname}
+		for k, v := range exp.ExpLabels {
+			lbls[k] = v
+		}
+		annotations := map[string]string{}
+		for k, v := range exp.ExpAnnotations {
+			annotations[k] = v
+		}
+		expected = append(expected, models.AlertSample{Labels: lbls, Annotations: annotations})
+	}
+
+	sortAlertSamples(got)
+	sortAlertSamples(expected)
+
+	result := models.RuleTestCaseResult{
+		Alertname: tc.Alertname,
+		EvalTime:  tc.EvalTime,
+		Expected:  expected,
+		Got:       got,
+	}
+
+	expText, gotText := alertSamplesText(expected), alertSamplesText(got)
+	result.Passed = expText == gotText
+	if !result.Passed {
+		result.Diff, _ = UnifiedDiff("expected", "got", []byte(expText), []byte(gotText))
+	}
+	return result
+}
+
+func alertSampleString(a models.AlertSample) string {
+	return fmt.Sprintf("labels: %s\nannotations: %s\n", labels.FromMap(a.Labels), labels.FromMap(a.Annotations))
+}
+
+func sortAlertSamples(samples []models.AlertSample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return alertSampleString(samples[i]) < alertSampleString(samples[j])
+	})
+}
+
+// 每个告警输出为两行，便于生成可读的diff
+func alertSamplesText(samples []models.AlertSample) string {
+	var b strings.Builder
+	for _, a := range samples {
+		b.WriteString(alertSampleString(a))
+	}
+	return b.String()
+}
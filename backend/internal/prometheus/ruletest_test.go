@@ -0,0 +1,79 @@
+package prometheus
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"promeconfig-backend/internal/models"
+)
+
+func TestSeriesSampleCount(t *testing.T) {
+	tests := []struct {
+		values string
+		want   int
+	}{
+		{"1 2 3", 3},
+		{"0+1x10", 12},
+		{"_x5 stale", 8},
+		{"1+1 x 10", 14},
+		{"0+1x99999999999999999999", 101},
+		{"0+1x60 0+1x60", 101},
+	}
+	for _, tt := range tests {
+		if got := seriesSampleCount(tt.values, 100); got != tt.want {
+			t.Errorf("seriesSampleCount(%q) = %d, want %d", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRuleTestSpec(t *testing.T) {
+	alertTest := []models.AlertRuleTestCase{{EvalTime: "5m", Alertname: "HighCPU"}}
+	// 每个序列都在上限内，合计超过上限
+	var manySeries []models.RuleTestSeries
+	for i := 0; i < 12; i++ {
+		manySeries = append(manySeries, models.RuleTestSeries{Series: fmt.Sprintf(`up{instance="%d"}`, i), Values: "0x9000"})
+	}
+	tests := []struct {
+		name    string
+		series  []models.RuleTestSeries
+		wantErr string
+	}{
+		{name: "valid", series: []models.RuleTestSeries{{Series: `up{job="node"}`, Values: "0+1x10"}}},
+		{
+			name:    "too many samples in a series",
+			series:  []models.RuleTestSeries{{Series: "up", Values: "0+1x100000000"}},
+			wantErr: "too many samples",
+		},
+		{
+			name:    "too many samples in total",
+			series:  manySeries,
+			wantErr: "input series have too many samples",
+		},
+		{
+			name:    "values in the series selector",
+			series:  []models.RuleTestSeries{{Series: "up 0+1x100000000", Values: "1"}},
+			wantErr: "invalid input series",
+		},
+		{
+			name:    "invalid values",
+			series:  []models.RuleTestSeries{{Series: "up", Values: "a b"}},
+			wantErr: "invalid input series",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRuleTestSpec(models.RuleTestSpec{InputSeries: tt.series, AlertRuleTests: alertTest})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package prometheus
+
+// 注册配置检查支持的服务发现机制，未注册的 *_sd_configs 会被当作未知字段
+import (
+	_ "github.com/prometheus/prometheus/discovery/dns"
+	_ "github.com/prometheus/prometheus/discovery/file"
+	_ "github.com/prometheus/prometheus/discovery/http"
+	_ "github.com/prometheus/prometheus/discovery/kubernetes"
+)
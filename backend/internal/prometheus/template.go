@@ -0,0 +1,147 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/template"
+)
+
+// 与Prometheus告警规则相同的模板变量
+var templateDefs = []string{
+	"{{$labels := .Labels}}",
+	"{{$externalLabels := .ExternalLabels}}",
+	"{{$externalURL := .ExternalURL}}",
+	"{{$value := .Value}}",
+}
+
+// TemplateSample 预览模板使用的样本，Labels 为表达式结果的标签，Value 为其值
+type TemplateSample struct {
+	Labels         map[string]string
+	Value          float64
+	ExternalLabels map[string]string
+	ExternalURL    string
+}
+
+// RenderedTemplates 模板的渲染结果，Errors 为渲染失败的标签或注解及其错误
+type RenderedTemplates struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	Errors      map[string]string `json:"errors"`
+}
+
+func newExpander(ctx context.Context, alertName, text string, data interface{}, queryFunc template.QueryFunc, externalURL *url.URL) *template.Expander {
+	return template.NewTemplateExpander(
+		ctx,
+		strings.Join(append(append([]string{}, templateDefs...), text), ""),
+		"__alert_"+alertName,
+		data,
+		model.TimeFromUnixNano(time.Now().UnixNano()),
+		queryFunc,
+		externalURL,
+		nil,
+	)
+}
+
+// CheckTemplates 用Prometheus的模板引擎和函数解析告警规则的标签和注解，与 promtool check rules 相同
+func CheckTemplates(alertName string, ruleLabels, annotations map[string]string) error {
+	data := template.AlertTemplateData(map[string]string{}, map[string]string{}, "", promql.Sample{})
+
+	var errs []string
+	check := func(kind string, values map[string]string) {
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := newExpander(context.Background(), alertName, values[name], data, nil, nil).ParseTest(); err != nil {
+				errs = append(errs, fmt.Sprintf("%s %q: %v", kind, name, err))
+			}
+		}
+	}
+	check("label", ruleLabels)
+	check("annotation", annotations)
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// RenderTemplates 按Prometheus触发告警时的方式渲染标签和注解：
+// $labels 为样本的标签，告警的标签为样本标签（去掉 __name__）加上渲染后的规则标签和 alertname
+// client 为nil时模板中的 query 函数返回错误，maxQueries 为 query 函数最多执行的次数，0表示不限制
+func RenderTemplates(ctx context.Context, client *Client, alertName string, ruleLabels, annotations map[string]string, sample TemplateSample, maxQueries int) (*RenderedTemplates, error) {
+	externalURL, err := url.Parse(sample.ExternalURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid external_url: %w", err)
+	}
+
+	sampleLabels := sample.Labels
+	if sampleLabels == nil {
+		sampleLabels = map[string]string{}
+	}
+	data := template.AlertTemplateData(sampleLabels, sample.ExternalLabels, sample.ExternalURL,
+		promql.Sample{Metric: labels.FromMap(sampleLabels), F: sample.Value})
+
+	queries := 0
+	queryFunc := func(ctx context.Context, q string, ts time.Time) (promql.Vector, error) {
+		if client == nil {
+			return nil, errors.New("no Prometheus instance is configured for query")
+		}
+		if maxQueries > 0 && queries >= maxQueries {
+			return nil, fmt.Errorf("too many query calls, max is %d", maxQueries)
+		}
+		queries++
+		vector, err := client.Query(ctx, q, ts)
+		if err != nil {
+			return nil, err
+		}
+		result := make(promql.Vector, 0, len(vector))
+		for _, s := range vector {
+			metric := make(map[string]string, len(s.Metric))
+			for name, value := range s.Metric {
+				metric[string(name)] = string(value)
+			}
+			result = append(result, promql.Sample{Metric: labels.FromMap(metric), F: float64(s.Value), T: int64(s.Timestamp)})
+		}
+		return result, nil
+	}
+
+	result := &RenderedTemplates{
+		Labels:      map[string]string{},
+		Annotations: map[string]string{},
+		Errors:      map[string]string{},
+	}
+	expand := func(key, text string) string {
+		out, err := newExpander(ctx, alertName, text, data, queryFunc, externalURL).Expand()
+		if err != nil {
+			result.Errors[key] = err.Error()
+		}
+		return out
+	}
+
+	for name, value := range sampleLabels {
+		if name != model.MetricNameLabel {
+			result.Labels[name] = value
+		}
+	}
+	for name, value := range ruleLabels {
+		result.Labels[name] = expand("labels."+name, value)
+	}
+	result.Labels[model.AlertNameLabel] = alertName
+	for name, value := range annotations {
+		result.Annotations[name] = expand("annotations."+name, value)
+	}
+
+	return result, nil
+}
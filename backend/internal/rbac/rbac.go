@@ -0,0 +1,76 @@
+package rbac
+
+// Role 组织内成员的角色
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleEditor   Role = "editor"
+	RoleApprover Role = "approver"
+	RoleAdmin    Role = "admin"
+)
+
+// Permission 某项操作所需的权限
+type Permission string
+
+const (
+	PermConfigRead       Permission = "config:read"
+	PermConfigRender     Permission = "config:render"
+	PermTargetsWrite     Permission = "targets:write"
+	PermRulesWrite       Permission = "rules:write"
+	PermPrometheusSync   Permission = "prometheus:sync"
+	PermPrometheusReload Permission = "prometheus:reload"
+	PermMembersManage    Permission = "members:manage"
+	PermSettingsManage   Permission = "settings:manage"
+	PermAuditRead        Permission = "audit:read"
+	PermChangesApprove   Permission = "changes:approve"
+)
+
+var viewerPermissions = []Permission{PermConfigRead, PermConfigRender}
+
+// 角色与权限的对应关系
+// approver 只负责审批变更、同步和重载，不能直接修改 targets 和规则
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:   viewerPermissions,
+	RoleEditor:   append([]Permission{PermTargetsWrite, PermRulesWrite}, viewerPermissions...),
+	RoleApprover: append([]Permission{PermChangesApprove, PermPrometheusSync, PermPrometheusReload}, viewerPermissions...),
+	RoleAdmin: {
+		PermConfigRead, PermConfigRender, PermTargetsWrite, PermRulesWrite,
+		PermPrometheusSync, PermPrometheusReload, PermMembersManage, PermSettingsManage,
+		PermAuditRead, PermChangesApprove,
+	},
+}
+
+// Valid 判断角色是否合法
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can 判断角色是否拥有某项权限
+func (r Role) Can(perm Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions 返回角色拥有的全部权限
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// 角色高低，用于从多个候选角色中选出权限最大的一个
+var roleRank = map[Role]int{
+	RoleViewer:   1,
+	RoleEditor:   2,
+	RoleApprover: 3,
+	RoleAdmin:    4,
+}
+
+// Higher 判断角色是否高于另一个角色
+func (r Role) Higher(other Role) bool {
+	return roleRank[r] > roleRank[other]
+}
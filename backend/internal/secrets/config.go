@@ -0,0 +1,28 @@
+package secrets
+
+import (
+	"errors"
+	"log"
+
+	"promeconfig-backend/internal/config"
+)
+
+// FromConfig 按配置创建主密钥，必须配置 SECRETS_KEYS；只有显式开启 SECRETS_INSECURE_DEV_KEY 的非生产环境才使用固定的开发密钥
+func FromConfig(cfg *config.Config) (*Keyring, error) {
+	if len(cfg.SecretsKeys) == 0 {
+		if !cfg.SecretsInsecureDevKey {
+			return nil, errors.New("SECRETS_KEYS is required (set SECRETS_INSECURE_DEV_KEY=true to use an insecure key for local development)")
+		}
+		if cfg.Environment == "production" {
+			return nil, errors.New("SECRETS_INSECURE_DEV_KEY cannot be used in production, configure SECRETS_KEYS")
+		}
+		log.Println("WARNING: SECRETS_KEYS not set, using the insecure development secrets key")
+		return NewKeyring(map[int][]byte{1: InsecureDevKey()}, 1)
+	}
+
+	keys, err := ParseKeys(cfg.SecretsKeys)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyring(keys, cfg.SecretsActiveKeyVersion)
+}
@@ -0,0 +1,179 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// 信封加密：每个值用随机生成的数据密钥（DEK）以AES-256-GCM加密，DEK再用配置中的主密钥（KEK）加密后与密文一起保存。
+// 密文格式为 v<主密钥版本>.<加密后的DEK>.<加密后的值>，后两段为base64。
+
+const keySize = 32
+
+// Keyring 按版本保存的主密钥，用当前版本加密，按密文中的版本解密
+type Keyring struct {
+	keys   map[int][]byte
+	active int
+}
+
+// NewKeyring active 为0时使用最大的版本
+func NewKeyring(keys map[int][]byte, active int) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no secret keys configured")
+	}
+	for version, key := range keys {
+		if version <= 0 {
+			return nil, fmt.Errorf("secret key version must be positive, got %d", version)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("secret key version %d must be %d bytes, got %d", version, keySize, len(key))
+		}
+	}
+	if active == 0 {
+		for version := range keys {
+			active = max(active, version)
+		}
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active secret key version %d is not configured", active)
+	}
+	return &Keyring{keys: keys, active: active}, nil
+}
+
+// ParseKeys 解析 版本=base64密钥 形式的主密钥
+func ParseKeys(values map[string]string) (map[int][]byte, error) {
+	keys := make(map[int][]byte, len(values))
+	for v, encoded := range values {
+		version, err := strconv.Atoi(strings.TrimPrefix(v, "v"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret key version %q", v)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("secret key version %d is not valid base64: %w", version, err)
+		}
+		keys[version] = key
+	}
+	return keys, nil
+}
+
+// InsecureDevKey 固定的开发密钥，源码公开，任何人都能解密，只能用于本地开发
+func InsecureDevKey() []byte {
+	sum := sha256.Sum256([]byte("promeconfig-insecure-development-key"))
+	return sum[:]
+}
+
+// AIAPIKeyAAD AI设置中API Key的附加数据，密文绑定到所属用户，复制到其他用户的设置中无法解密
+func AIAPIKeyAAD(userID uuid.UUID) []byte {
+	return []byte("ai_settings:" + userID.String())
+}
+
+// TOTPSecretAAD 两步验证密钥的附加数据，密文绑定到所属用户
+func TOTPSecretAAD(userID uuid.UUID) []byte {
+	return []byte("users.totp_secret:" + userID.String())
+}
+
+// ActiveVersion 加密新值时使用的主密钥版本
+func (k *Keyring) ActiveVersion() int {
+	return k.active
+}
+
+// Encrypt 用当前主密钥加密，aad 把密文绑定到所属的记录，解密时必须相同
+func (k *Keyring) Encrypt(plaintext string, aad []byte) (string, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	data, err := seal(dek, []byte(plaintext), aad)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.active], dek, aad)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("v%d.%s.%s", k.active,
+		base64.RawURLEncoding.EncodeToString(wrapped), base64.RawURLEncoding.EncodeToString(data)), nil
+}
+
+// Decrypt 用密文中记录的版本的主密钥解密
+func (k *Keyring) Decrypt(ciphertext string, aad []byte) (string, error) {
+	parts := strings.Split(ciphertext, ".")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "v") {
+		return "", errors.New("malformed secret")
+	}
+	version, err := strconv.Atoi(parts[0][1:])
+	if err != nil {
+		return "", errors.New("malformed secret")
+	}
+	kek, ok := k.keys[version]
+	if !ok {
+		return "", fmt.Errorf("secret key version %d is not configured", version)
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.New("malformed secret")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("malformed secret")
+	}
+
+	dek, err := open(kek, wrapped, aad)
+	if err != nil {
+		return "", fmt.Errorf("decrypt data key: %w", err)
+	}
+	plaintext, err := open(dek, data, aad)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Mask 只保留开头和末尾几个字符的提示，如 sk-...abcd，较短的值完全隐藏
+func Mask(secret string) string {
+	if len(secret) < 12 {
+		return "********"
+	}
+	return secret[:3] + "..." + secret[len(secret)-4:]
+}
+
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
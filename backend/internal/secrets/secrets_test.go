@@ -0,0 +1,210 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"promeconfig-backend/internal/config"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	keyring, err := NewKeyring(map[int][]byte{1: testKey(1)}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aad := []byte("ai_settings:user")
+
+	ciphertext, err := keyring.Encrypt("sk-secret-value", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ciphertext, "v1.") || strings.Contains(ciphertext, "sk-secret-value") {
+		t.Fatalf("unexpected ciphertext %q", ciphertext)
+	}
+
+	// 每次加密使用新的数据密钥和nonce
+	again, err := keyring.Encrypt("sk-secret-value", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == ciphertext {
+		t.Error("encrypting the same value twice produced the same ciphertext")
+	}
+
+	plaintext, err := keyring.Decrypt(ciphertext, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "sk-secret-value" {
+		t.Errorf("plaintext = %q", plaintext)
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	keyring, err := NewKeyring(map[int][]byte{1: testKey(1)}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aad := []byte("ai_settings:user")
+	ciphertext, err := keyring.Encrypt("sk-secret-value", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(ciphertext, ".")
+	tampered := []byte(parts[2])
+	tampered[len(tampered)/2] ^= 1
+
+	tests := []struct {
+		name       string
+		ciphertext string
+		aad        []byte
+	}{
+		{"other record", ciphertext, []byte("ai_settings:other")},
+		{"unknown version", "v2." + parts[1] + "." + parts[2], aad},
+		{"tampered data", parts[0] + "." + parts[1] + "." + string(tampered), aad},
+		{"missing parts", parts[0] + "." + parts[1], aad},
+		{"invalid version", "vx." + parts[1] + "." + parts[2], aad},
+		{"not base64", parts[0] + ".!!!." + parts[2], aad},
+		{"plaintext", "sk-secret-value", aad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := keyring.Decrypt(tt.ciphertext, tt.aad); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, err := NewKeyring(map[int][]byte{1: testKey(1)}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := old.Encrypt("sk-secret-value", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 添加新版本后默认用最大的版本加密，旧版本的密文仍能解密
+	rotated, err := NewKeyring(map[int][]byte{1: testKey(1), 2: testKey(2)}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.ActiveVersion() != 2 {
+		t.Fatalf("active version = %d, want 2", rotated.ActiveVersion())
+	}
+	plaintext, err := rotated.Decrypt(ciphertext, nil)
+	if err != nil || plaintext != "sk-secret-value" {
+		t.Fatalf("decrypt with rotated keyring = %q, %v", plaintext, err)
+	}
+	reencrypted, err := rotated.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(reencrypted, "v2.") {
+		t.Errorf("reencrypted with %q, want version 2", reencrypted)
+	}
+
+	// 删除旧版本后只能解密新版本的密文
+	current, err := NewKeyring(map[int][]byte{2: testKey(2)}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := current.Decrypt(ciphertext, nil); err == nil {
+		t.Error("expected an error decrypting with a removed key version")
+	}
+	if _, err := current.Decrypt(reencrypted, nil); err != nil {
+		t.Error(err)
+	}
+
+	// 可以指定旧版本继续加密
+	pinned, err := NewKeyring(map[int][]byte{1: testKey(1), 2: testKey(2)}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned.ActiveVersion() != 1 {
+		t.Errorf("active version = %d, want 1", pinned.ActiveVersion())
+	}
+}
+
+func TestNewKeyringErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   map[int][]byte
+		active int
+	}{
+		{"no keys", nil, 0},
+		{"non-positive version", map[int][]byte{0: testKey(1)}, 0},
+		{"short key", map[int][]byte{1: []byte("short")}, 0},
+		{"unknown active version", map[int][]byte{1: testKey(1)}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeyring(tt.keys, tt.active); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(testKey(1))
+	keys, err := ParseKeys(map[string]string{"1": encoded, "v2": encoded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || !bytes.Equal(keys[1], testKey(1)) || !bytes.Equal(keys[2], testKey(1)) {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	for _, values := range []map[string]string{
+		{"x": encoded},
+		{"1": "not base64!"},
+	} {
+		if _, err := ParseKeys(values); err == nil {
+			t.Errorf("expected an error parsing %v", values)
+		}
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(testKey(1))
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		wantErr bool
+	}{
+		{"configured keys", config.Config{Environment: "production", SecretsKeys: map[string]string{"1": encoded}}, false},
+		{"missing keys", config.Config{Environment: "development"}, true},
+		{"development key", config.Config{Environment: "development", SecretsInsecureDevKey: true}, false},
+		{"development key in production", config.Config{Environment: "production", SecretsInsecureDevKey: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromConfig(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := map[string]string{
+		"short":                  "********",
+		"sk-1234567890abcd":      "sk-...abcd",
+		"sk-ant-api03-xxxxxwxyz": "sk-...wxyz",
+	}
+	for secret, want := range tests {
+		if got := Mask(secret); got != want {
+			t.Errorf("Mask(%q) = %q, want %q", secret, got, want)
+		}
+	}
+}
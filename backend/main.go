@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"net/http"
 	"os"
 
 	"github.com/gin-contrib/cors"
@@ -11,6 +12,7 @@ import (
 	"promeconfig-backend/internal/database"
 	"promeconfig-backend/internal/handlers"
 	"promeconfig-backend/internal/middleware"
+	"promeconfig-backend/internal/secrets"
 )
 
 func main() {
@@ -33,6 +35,15 @@ func main() {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
+	// 加载加密保存密钥使用的主密钥，并用当前版本重新加密旧数据
+	keyring, err := secrets.FromConfig(cfg)
+	if err != nil {
+		log.Fatal("Failed to load secrets keys:", err)
+	}
+	if err := database.MigrateSecrets(db, keyring); err != nil {
+		log.Fatal("Failed to re-encrypt secrets:", err)
+	}
+
 	// 初始化Gin
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -40,17 +51,25 @@ func main() {
 
 	r := gin.Default()
 
+	// 审计日志中的IP取自 c.ClientIP()，只信任配置的代理转发的 X-Forwarded-For
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
 	// CORS配置
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173", "https://localhost:5173", "http://localhost:3000"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", middleware.OrgHeader},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 	}))
 
 	// 初始化处理器
-	h := handlers.New(db)
+	h, err := handlers.New(db, cfg, keyring)
+	if err != nil {
+		log.Fatal("Failed to initialize handlers:", err)
+	}
 
 	// 公共路由
 	public := r.Group("/api")
@@ -58,6 +77,14 @@ func main() {
 		public.POST("/auth/signup", h.SignUp)
 		public.POST("/auth/signin", h.SignIn)
 		public.POST("/auth/refresh", h.RefreshToken)
+		public.POST("/auth/mfa/verify", h.VerifyMFAChallenge)
+		public.POST("/auth/verify-email", h.VerifyEmail)
+		public.POST("/auth/resend-verification", h.ResendVerification)
+		public.POST("/auth/password/forgot", h.ForgotPassword)
+		public.POST("/auth/password/reset", h.ResetPassword)
+		public.GET("/auth/providers", h.GetAuthProviders)
+		public.GET("/auth/oidc/login", h.OIDCLogin)
+		public.GET("/auth/oidc/callback", h.OIDCCallback)
 	}
 
 	// 需要认证的路由
@@ -67,28 +94,158 @@ func main() {
 		// 用户相关
 		protected.GET("/user", h.GetUser)
 		protected.POST("/auth/signout", h.SignOut)
+		protected.GET("/orgs", h.GetOrganizations)
 
-		// Targets管理
-		protected.GET("/targets", h.GetTargets)
-		protected.POST("/targets", h.CreateTarget)
-		protected.PUT("/targets/:id", h.UpdateTarget)
-		protected.DELETE("/targets/:id", h.DeleteTarget)
-
-		// Alert Rules管理
-		protected.GET("/alert-rules", h.GetAlertRules)
-		protected.POST("/alert-rules", h.CreateAlertRule)
-		protected.PUT("/alert-rules/:id", h.UpdateAlertRule)
-		protected.DELETE("/alert-rules/:id", h.DeleteAlertRule)
+		// 两步验证
+		protected.GET("/auth/mfa", h.GetMFAStatus)
+		protected.POST("/auth/mfa/enroll", h.EnrollMFA)
+		protected.POST("/auth/mfa/activate", h.ActivateMFA)
+		protected.POST("/auth/mfa/disable", h.DisableMFA)
+		protected.POST("/auth/mfa/recovery-codes", h.RegenerateRecoveryCodes)
 
 		// AI Settings管理
 		protected.GET("/ai-settings", h.GetAISettings)
 		protected.POST("/ai-settings", h.SaveAISettings)
 		protected.DELETE("/ai-settings", h.DeleteAISettings)
+	}
+
+	// 组织范围内的路由，按角色鉴权
+	org := r.Group("/api")
+	org.Use(middleware.AuthMiddleware(), middleware.OrgMiddleware(db))
+	{
+		// 组织及成员管理
+		org.PUT("/org", h.UpdateOrganization)
+		org.GET("/org/members", h.GetMembers)
+		org.POST("/org/members", h.AddMember)
+		org.PUT("/org/members/:user_id", h.UpdateMember)
+		org.DELETE("/org/members/:user_id", h.RemoveMember)
+
+		// Targets管理
+		org.GET("/targets", h.GetTargets)
+		org.POST("/targets", h.CreateTarget)
+		org.PUT("/targets/:id", h.UpdateTarget)
+		org.DELETE("/targets/:id", h.DeleteTarget)
+
+		// Alert Rules管理
+		org.GET("/alert-rules", h.GetAlertRules)
+		org.POST("/alert-rules", h.CreateAlertRule)
+		org.PUT("/alert-rules/:id", h.UpdateAlertRule)
+		org.DELETE("/alert-rules/:id", h.DeleteAlertRule)
+		org.POST("/alert-rules/test", h.RunAlertRuleTests)
+		org.POST("/alert-rules/:id/backtest", h.BacktestAlertRule)
+		org.GET("/alert-rules/:id/state", h.GetAlertRuleState)
+		org.POST("/alert-rules/:id/annotations-preview", h.PreviewAlertRuleAnnotations)
+		org.POST("/alert-rules/lint", h.LintAlertRule)
+		org.GET("/alert-rules/lint-settings", h.GetRuleLintSettings)
+		org.PUT("/alert-rules/lint-settings", h.SaveRuleLintSettings)
+		org.POST("/ai/generate-rule", h.GenerateAlertRule)
+		org.POST("/ai-settings/test", h.TestAISettings)
+
+		// 规则组分配
+		org.GET("/rule-groups", h.GetRuleGroups)
+		org.PUT("/rule-groups/:name", h.UpdateRuleGroup)
+
+		// 告警规则单元测试
+		org.GET("/alert-rule-tests", h.GetAlertRuleTests)
+		org.POST("/alert-rule-tests", h.CreateAlertRuleTest)
+		org.PUT("/alert-rule-tests/:id", h.UpdateAlertRuleTest)
+		org.DELETE("/alert-rule-tests/:id", h.DeleteAlertRuleTest)
+
+		// 配置检查
+		org.POST("/config/check", h.CheckConfig)
+
+		// Prometheus查询代理，使用服务端保存的凭据
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			org.Handle(method, "/prometheus/query", h.PrometheusQuery)
+			org.Handle(method, "/prometheus/query_range", h.PrometheusQueryRange)
+			org.Handle(method, "/prometheus/series", h.PrometheusSeries)
+			org.Handle(method, "/prometheus/labels", h.PrometheusLabels)
+		}
+		org.GET("/prometheus/label/:name/values", h.PrometheusLabelValues)
 
 		// Prometheus配置管理
-		protected.POST("/prometheus/sync", h.SyncPrometheusConfig)
-		protected.POST("/prometheus/reload", h.ReloadPrometheusConfig)
-		protected.GET("/prometheus/status", h.GetPrometheusStatus)
+		org.POST("/prometheus/sync", h.SyncPrometheusConfig)
+		org.POST("/prometheus/reload", h.ReloadPrometheusConfig)
+		org.GET("/prometheus/status", h.GetPrometheusStatus)
+		org.GET("/prometheus/drift", h.GetPrometheusDrift)
+
+		// Prometheus实例
+		org.GET("/prometheus/instances", h.GetPrometheusInstances)
+		org.POST("/prometheus/instances", h.CreatePrometheusInstance)
+		org.GET("/prometheus/instances/:id", h.GetPrometheusInstance)
+		org.PUT("/prometheus/instances/:id", h.UpdatePrometheusInstance)
+		org.DELETE("/prometheus/instances/:id", h.DeletePrometheusInstance)
+		org.POST("/prometheus/instances/:id/sync", h.SyncPrometheusInstance)
+		org.POST("/prometheus/instances/:id/reload", h.ReloadPrometheusInstance)
+		org.GET("/prometheus/instances/:id/status", h.GetPrometheusInstanceStatus)
+		org.GET("/prometheus/instances/:id/drift", h.GetPrometheusInstanceDrift)
+		org.GET("/prometheus/instances/:id/global", h.GetGlobalConfig)
+		org.PUT("/prometheus/instances/:id/global", h.SaveGlobalConfig)
+
+		// Prometheus的 alerting.alertmanagers
+		org.GET("/prometheus/alertmanagers", h.GetAlertmanagers)
+		org.POST("/prometheus/alertmanagers", h.CreateAlertmanager)
+		org.GET("/prometheus/alertmanagers/:id", h.GetAlertmanager)
+		org.PUT("/prometheus/alertmanagers/:id", h.UpdateAlertmanager)
+		org.DELETE("/prometheus/alertmanagers/:id", h.DeleteAlertmanager)
+
+		// Alertmanager配置管理
+		org.GET("/alertmanager/settings", h.GetAlertmanagerSettings)
+		org.PUT("/alertmanager/settings", h.SaveAlertmanagerSettings)
+		org.GET("/alertmanager/global", h.GetAlertmanagerGlobal)
+		org.PUT("/alertmanager/global", h.SaveAlertmanagerGlobal)
+		org.GET("/alertmanager/route", h.GetAlertmanagerRoute)
+		org.PUT("/alertmanager/route", h.SaveAlertmanagerRoute)
+		org.GET("/alertmanager/inhibit-rules", h.GetInhibitRules)
+		org.PUT("/alertmanager/inhibit-rules", h.SaveInhibitRules)
+		org.GET("/alertmanager/time-intervals", h.GetTimeIntervals)
+		org.PUT("/alertmanager/time-intervals", h.SaveTimeIntervals)
+		org.GET("/alertmanager/receivers", h.GetAlertmanagerReceivers)
+		org.POST("/alertmanager/receivers", h.CreateAlertmanagerReceiver)
+		org.GET("/alertmanager/receivers/:id", h.GetAlertmanagerReceiver)
+		org.PUT("/alertmanager/receivers/:id", h.UpdateAlertmanagerReceiver)
+		org.DELETE("/alertmanager/receivers/:id", h.DeleteAlertmanagerReceiver)
+		org.POST("/alertmanager/route-test", h.TestAlertmanagerRoute)
+		org.GET("/alertmanager/config", h.GetAlertmanagerConfig)
+		org.POST("/alertmanager/sync", h.SyncAlertmanagerConfig)
+		org.POST("/alertmanager/reload", h.ReloadAlertmanagerConfig)
+		org.GET("/alertmanager/status", h.GetAlertmanagerStatus)
+		org.GET("/alertmanager/revisions", h.GetAlertmanagerRevisions)
+
+		// 代理Alertmanager的v2静默API
+		org.GET("/silences", h.GetSilences)
+		org.POST("/silences", h.CreateSilence)
+		org.GET("/silences/:id", h.GetSilence)
+		org.DELETE("/silences/:id", h.ExpireSilence)
+
+		// remote_write 和 remote_read，:kind 为 write 或 read
+		org.GET("/remote/:kind", h.GetRemoteStorages)
+		org.POST("/remote/:kind", h.CreateRemoteStorage)
+		org.GET("/remote/:kind/:id", h.GetRemoteStorage)
+		org.PUT("/remote/:kind/:id", h.UpdateRemoteStorage)
+		org.DELETE("/remote/:kind/:id", h.DeleteRemoteStorage)
+
+		// 配置版本
+		org.GET("/revisions", h.GetRevisions)
+		org.GET("/revisions/:id", h.GetRevision)
+		org.GET("/revisions/:id/diff", h.DiffRevision)
+		org.POST("/revisions/:id/rollback", h.RollbackRevision)
+
+		// 变更集审批
+		org.GET("/change-sets", h.GetChangeSets)
+		org.POST("/change-sets", h.CreateChangeSet)
+		org.GET("/change-sets/:id", h.GetChangeSet)
+		org.DELETE("/change-sets/:id", h.DeleteChangeSet)
+		org.POST("/change-sets/:id/items", h.AddChangeSetItem)
+		org.DELETE("/change-sets/:id/items/:item_id", h.RemoveChangeSetItem)
+		org.GET("/change-sets/:id/diff", h.DiffChangeSet)
+		org.POST("/change-sets/:id/submit", h.SubmitChangeSet)
+		org.POST("/change-sets/:id/approve", h.ApproveChangeSet)
+		org.POST("/change-sets/:id/reject", h.RejectChangeSet)
+		org.POST("/change-sets/:id/comments", h.AddChangeSetComment)
+
+		// 审计日志
+		org.GET("/audit", h.GetAuditLog)
 	}
 
 	// 健康检查